@@ -0,0 +1,455 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"text/tabwriter"
+	"time"
+
+	g2h "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+// parseFlags parses a command's flags, returning a non-negative exit code if
+// the command should stop.
+func (a *app) parseFlags(fs *flag.FlagSet, args []string, nargs int) int {
+	fs.SetOutput(a.stderr)
+
+	err := fs.Parse(args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+
+		return exitUsage
+	}
+
+	if fs.NArg() != nargs {
+		a.errorf("%v: expected %v argument(s), got %v", fs.Name(), nargs, fs.NArg())
+		return exitUsage
+	}
+
+	return -1
+}
+
+// loadConfig loads the config file, returning a non-negative exit code on
+// failure.
+func (a *app) loadConfig() (g2h.Config, int) {
+	c, err := g2h.LoadConfig(a.configFile)
+	if err != nil {
+		a.errorf("failed to load config: %v", err.Error())
+		return c, exitConfig
+	}
+
+	c.Logger = a.logger
+
+	return c, -1
+}
+
+// openDB connects to the configured database and loads everything that's
+// needed in order to render posts, other than the posts themselves.
+func (a *app) openDB(ctx context.Context, c *g2h.Config) (*sql.DB, int) {
+	db, err := c.OpenDB()
+	if err != nil {
+		a.errorf("failed to connect to db: %v", err.Error())
+		return nil, exitError
+	}
+
+	db.SetConnMaxLifetime(time.Minute * 3)
+	db.SetMaxOpenConns(10)
+	db.SetMaxIdleConns(10)
+
+	err = prepareDB(ctx, c, db)
+	if err != nil {
+		db.Close()
+		a.errorf("failed to prepare db: %v", err.Error())
+		return nil, exitError
+	}
+
+	if c.NewsletterMode == g2h.NewsletterModeArchive {
+		err = c.LoadNewsletters(ctx, db)
+		if err != nil {
+			db.Close()
+			a.errorf("%v", err.Error())
+			return nil, exitError
+		}
+	}
+
+	return db, -1
+}
+
+// skipFailures prints each post in err if it is a [g2h.PostErrors] that
+// doesn't exceed the failure threshold, returning true if the command can
+// carry on without them.
+func (a *app) skipFailures(err error) bool {
+	var errs g2h.PostErrors
+	if !errors.As(err, &errs) || errors.Is(err, g2h.ErrTooManyErrors) {
+		return false
+	}
+
+	for _, e := range errs {
+		a.errorf("skipped: %v", e.Error())
+	}
+
+	a.failures += len(errs)
+
+	return true
+}
+
+// loadPosts loads the config, connects to the database and reads every post.
+func (a *app) loadPosts(ctx context.Context) (g2h.Config, *sql.DB, []g2h.GhostPost, int) {
+	c, code := a.loadConfig()
+	if code >= 0 {
+		return c, nil, nil, code
+	}
+
+	db, code := a.openDB(ctx, &c)
+	if code >= 0 {
+		return c, nil, nil, code
+	}
+
+	posts, err := c.LoadPosts(ctx, db)
+	if err != nil && !a.skipFailures(err) {
+		db.Close()
+		a.errorf("failed to load posts: %v", err.Error())
+		return c, nil, nil, exitError
+	}
+
+	a.loadErr = err
+
+	return c, db, posts, -1
+}
+
+func runExport(ctx context.Context, a *app, args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "print a diff of what would change instead of writing anything")
+	workers := fs.Int("workers", 0, "number of posts to render concurrently, overriding workers in the config")
+	if code := a.parseFlags(fs, args, 0); code >= 0 {
+		return code
+	}
+
+	c, db, posts, code := a.loadPosts(ctx)
+	if code >= 0 {
+		return code
+	}
+
+	defer db.Close()
+
+	if *workers > 0 {
+		c.Workers = *workers
+	}
+
+	if *dryRun {
+		c.DryRun = true
+		c.DryRunOutput = a.stdout
+	}
+
+	if c.SiteConfigPath != "" && !c.DryRun {
+		s, err := g2h.LoadSiteSettings(ctx, db)
+		if err != nil {
+			a.errorf("%v", err.Error())
+			return exitError
+		}
+
+		err = c.WriteSiteConfig(s, c.SiteConfigPath)
+		if err != nil {
+			a.errorf("%v", err.Error())
+			return exitError
+		}
+	}
+
+	posts = c.Select(posts)
+
+	loadFailures := a.failures
+
+	err := c.RenderLoaded(ctx, posts, a.loadErr)
+	if err != nil && !a.skipFailures(err) {
+		a.errorf("%v", err.Error())
+		if errors.Is(err, g2h.ErrUnsafePublish) {
+			return exitUnsafe
+		}
+
+		return exitError
+	}
+
+	if !c.DryRun {
+		fmt.Fprintf(a.stdout, "rendered %v posts to %v\n", len(posts)-(a.failures-loadFailures), c.OutputPath)
+	}
+
+	if a.failures > 0 {
+		a.errorf("%v post(s) failed", a.failures)
+		return exitError
+	}
+
+	return exitOK
+}
+
+func runValidateConfig(ctx context.Context, a *app, args []string) int {
+	fs := flag.NewFlagSet("validate-config", flag.ContinueOnError)
+	ping := fs.Bool("ping", false, "also check that the database is reachable")
+	if code := a.parseFlags(fs, args, 0); code >= 0 {
+		return code
+	}
+
+	c, code := a.loadConfig()
+	if code >= 0 {
+		return code
+	}
+
+	if *ping {
+		db, err := c.OpenDB()
+		if err != nil {
+			a.errorf("failed to connect to db: %v", err.Error())
+			return exitError
+		}
+
+		defer db.Close()
+
+		err = db.PingContext(ctx)
+		if err != nil {
+			a.errorf("failed to ping db: %v", err.Error())
+			return exitError
+		}
+	}
+
+	fmt.Fprintf(a.stdout, "%v: ok\n", a.configFile)
+
+	return exitOK
+}
+
+func runList(ctx context.Context, a *app, args []string) int {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	skipped := fs.Bool("skipped", false, "only list posts that would be skipped")
+	if code := a.parseFlags(fs, args, 0); code >= 0 {
+		return code
+	}
+
+	c, db, posts, code := a.loadPosts(ctx)
+	if code >= 0 {
+		return code
+	}
+
+	defer db.Close()
+
+	w := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SELECTED\tSLUG\tTYPE\tSTATUS\tVISIBILITY\tOUTPUT")
+
+	for _, p := range posts {
+		d := c.Decide(p)
+		if d.Valid && *skipped {
+			continue
+		}
+
+		out := "-"
+		if d.Valid {
+			out = c.OutputFile(p)
+		} else {
+			out += " (" + d.String() + ")"
+		}
+
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", d.Valid, p.Slug, p.Type, p.Status, p.Visibility, out)
+	}
+
+	err := w.Flush()
+	if err != nil {
+		a.errorf("%v", err.Error())
+		return exitError
+	}
+
+	return exitOK
+}
+
+func runRender(ctx context.Context, a *app, args []string) int {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	if code := a.parseFlags(fs, args, 1); code >= 0 {
+		return code
+	}
+
+	slug := fs.Arg(0)
+
+	c, db, posts, code := a.loadPosts(ctx)
+	if code >= 0 {
+		return code
+	}
+
+	defer db.Close()
+
+	for _, p := range posts {
+		if p.Slug != slug {
+			continue
+		}
+
+		if d := c.Decide(p); !d.Valid {
+			a.errorf("warning: %v would be skipped by export: %v", slug, d.String())
+		}
+
+		s, err := c.RenderString(p)
+		if err != nil {
+			a.errorf("%v", err.Error())
+			return exitError
+		}
+
+		_, err = io.WriteString(a.stdout, s)
+		if err != nil {
+			a.errorf("%v", err.Error())
+			return exitError
+		}
+
+		return exitOK
+	}
+
+	a.errorf("no post with slug %q", slug)
+
+	return exitError
+}
+
+func runDiff(ctx context.Context, a *app, args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	quiet := fs.Bool("q", false, "only print the summary, not the diff")
+	if code := a.parseFlags(fs, args, 0); code >= 0 {
+		return code
+	}
+
+	c, db, posts, code := a.loadPosts(ctx)
+	if code >= 0 {
+		return code
+	}
+
+	defer db.Close()
+
+	cs, err := c.DiffContext(ctx, c.Select(posts))
+	if err != nil {
+		a.errorf("%v", err.Error())
+		return exitError
+	}
+
+	if *quiet {
+		_, err = fmt.Fprintln(a.stdout, cs.Summary())
+	} else {
+		err = cs.WriteDiff(a.stdout)
+	}
+
+	if err != nil {
+		a.errorf("%v", err.Error())
+		return exitError
+	}
+
+	if cs.HasChanges() {
+		return exitChanges
+	}
+
+	return exitOK
+}
+
+func runWatch(ctx context.Context, a *app, args []string) int {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	interval := fs.String("interval", "", "how often to poll for changes, overriding watchInterval in the config")
+	if code := a.parseFlags(fs, args, 0); code >= 0 {
+		return code
+	}
+
+	c, code := a.loadConfig()
+	if code >= 0 {
+		return code
+	}
+
+	if *interval != "" {
+		c.WatchInterval = *interval
+
+		err := c.Validate()
+		if err != nil {
+			a.errorf("%v", err.Error())
+			return exitUsage
+		}
+	}
+
+	db, code := a.openDB(ctx, &c)
+	if code >= 0 {
+		return code
+	}
+
+	defer db.Close()
+
+	w := g2h.Watcher{Config: &c, Source: &g2h.DBSource{Config: &c, DB: db}}
+
+	err := w.Run(ctx)
+	if err != nil {
+		a.errorf("%v", err.Error())
+		return exitError
+	}
+
+	return exitOK
+}
+
+func runServe(ctx context.Context, a *app, args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", "", "address to listen on, overriding webhookAddress in the config (default \":8080\")")
+	if code := a.parseFlags(fs, args, 0); code >= 0 {
+		return code
+	}
+
+	c, code := a.loadConfig()
+	if code >= 0 {
+		return code
+	}
+
+	if c.WebhookSecret == "" {
+		a.errorf("webhookSecret must be set in the config")
+		return exitConfig
+	}
+
+	if *addr != "" {
+		c.WebhookAddress = *addr
+	}
+
+	if c.WebhookAddress == "" {
+		c.WebhookAddress = ":8080"
+	}
+
+	db, code := a.openDB(ctx, &c)
+	if code >= 0 {
+		return code
+	}
+
+	defer db.Close()
+
+	ws := &g2h.WebhookServer{Config: &c, Source: &g2h.DBSource{Config: &c, DB: db}}
+	srv := &http.Server{
+		Addr:              c.WebhookAddress,
+		Handler:           ws,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() { errs <- srv.ListenAndServe() }()
+
+	fmt.Fprintf(a.stdout, "listening for webhooks on %v\n", c.WebhookAddress)
+
+	select {
+	case err := <-errs:
+		a.errorf("%v", err.Error())
+		return exitError
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		a.errorf("failed to shut down: %v", err.Error())
+	}
+
+	// export anything that's still waiting for the debounce window
+	err = ws.Flush(shutdownCtx)
+	if err != nil {
+		a.errorf("%v", err.Error())
+		return exitError
+	}
+
+	return exitOK
+}
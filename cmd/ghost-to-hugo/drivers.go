@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	g2h "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+
+	// Ghost recommends mysql. To support another database, import its driver
+	// here and set databaseDriver in the config.
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// prepareDB applies driver-specific session settings.
+func prepareDB(ctx context.Context, c *g2h.Config, db *sql.DB) error {
+	switch c.DatabaseDriver {
+	case "mysql":
+		// force read-only since this operation does not require write privileges
+		_, err := db.ExecContext(ctx, "SET SESSION TRANSACTION READ ONLY")
+		if err != nil {
+			return fmt.Errorf("failed to set read-only session: %w", err)
+		}
+	}
+
+	return nil
+}
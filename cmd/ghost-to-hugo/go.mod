@@ -0,0 +1,14 @@
+module github.com/charles-m-knox/ghost-to-hugo/cmd/ghost-to-hugo
+
+go 1.22.5
+
+require (
+	github.com/charles-m-knox/ghost-to-hugo v0.0.2
+	github.com/go-sql-driver/mysql v1.8.1
+)
+
+require filippo.io/edwards25519 v1.1.0 // indirect
+
+// The CLI is versioned alongside the library, so it always builds against the
+// library in this repository.
+replace github.com/charles-m-knox/ghost-to-hugo => ../..
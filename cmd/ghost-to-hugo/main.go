@@ -0,0 +1,176 @@
+// Command ghost-to-hugo renders Ghost posts to Hugo-compatible markdown files.
+//
+// Usage:
+//
+//	ghost-to-hugo [-f config.json] [-v] <command> [arguments]
+//
+// Run "ghost-to-hugo help" for a list of commands.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+)
+
+// Exit codes, which are consistent across every command so that the CLI can
+// be scripted from cron and CI.
+const (
+	// Everything succeeded. For diff, there were no changes.
+	exitOK = 0
+	// A runtime error occurred, such as a database or write failure.
+	exitError = 1
+	// The command line was invalid.
+	exitUsage = 2
+	// The config file could not be loaded or is invalid.
+	exitConfig = 3
+	// Rendered output failed verification because it contained non-public
+	// content, see ghosttohugo.ErrUnsafePublish.
+	exitUnsafe = 4
+	// diff found changes.
+	exitChanges = 5
+)
+
+// command is a single subcommand.
+type command struct {
+	usage   string
+	summary string
+	run     func(ctx context.Context, a *app, args []string) int
+}
+
+var commands = map[string]command{
+	"export": {
+		usage:   "export [-dry-run] [-workers n]",
+		summary: "render all selected posts to the output path",
+		run:     runExport,
+	},
+	"validate-config": {
+		usage:   "validate-config [-ping]",
+		summary: "load the config file and report any problems",
+		run:     runValidateConfig,
+	},
+	"list": {
+		usage:   "list [-skipped]",
+		summary: "list posts and whether they would be selected for export",
+		run:     runList,
+	},
+	"render": {
+		usage:   "render <slug>",
+		summary: "render a single post to stdout",
+		run:     runRender,
+	},
+	"diff": {
+		usage:   "diff [-q]",
+		summary: "show a unified diff of what an export would change",
+		run:     runDiff,
+	},
+	"serve": {
+		usage:   "serve [-addr :8080]",
+		summary: "receive Ghost webhooks and re-render the affected posts",
+		run:     runServe,
+	},
+	"watch": {
+		usage:   "watch [-interval 1m]",
+		summary: "poll for changes and re-render changed posts until stopped",
+		run:     runWatch,
+	},
+}
+
+// app holds state shared by every command.
+type app struct {
+	configFile string
+	stdout     io.Writer
+	stderr     io.Writer
+	logger     *slog.Logger
+	// The number of posts that failed and were skipped because
+	// continueOnError is set.
+	failures int
+	// The error from loading posts if some were skipped, which an export
+	// counts towards maxErrors, see [g2h.Config.RenderLoaded].
+	loadErr error
+}
+
+// errorf prints a message to stderr.
+func (a *app) errorf(format string, args ...any) {
+	fmt.Fprintf(a.stderr, "ghost-to-hugo: "+format+"\n", args...)
+}
+
+func usage(w io.Writer, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: ghost-to-hugo [-f config.json] [-v] <command> [arguments]\n\nCommands:\n")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-32v %v\n", commands[name].usage, commands[name].summary)
+	}
+
+	fmt.Fprintf(w, "\nFlags:\n")
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("ghost-to-hugo", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	a := &app{stdout: stdout, stderr: stderr}
+	fs.StringVar(&a.configFile, "f", "config.json", "json file to use for loading configuration")
+	verbose := fs.Bool("v", false, "log debug messages, such as every file that is written")
+
+	err := fs.Parse(args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			usage(stdout, fs)
+			return exitOK
+		}
+
+		a.errorf("%v", err.Error())
+		usage(stderr, fs)
+		return exitUsage
+	}
+
+	if fs.NArg() == 0 {
+		usage(stderr, fs)
+		return exitUsage
+	}
+
+	level := slog.LevelInfo
+	if *verbose {
+		level = slog.LevelDebug
+	}
+
+	a.logger = slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: level}))
+
+	name := fs.Arg(0)
+	if name == "help" {
+		usage(stdout, fs)
+		return exitOK
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		a.errorf("unknown command %q", name)
+		usage(stderr, fs)
+		return exitUsage
+	}
+
+	return cmd.run(ctx, a, fs.Args()[1:])
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
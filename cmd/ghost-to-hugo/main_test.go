@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeDriver is a minimal read-only database/sql driver that answers the
+// library's queries with two posts: "hello", which is public, and "secret",
+// which is for members only.
+type fakeDriver struct{}
+
+func init() {
+	sql.Register("ghost-to-hugo-fake", fakeDriver{})
+}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query: query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, fmt.Errorf("transactions unsupported") }
+
+type fakeStmt struct{ query string }
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+// fakePostColumns has one entry per field in QUERY_POSTS_FIELDS.
+var fakePostColumns = strings.Split("ID UUID Title Slug Mobiledoc Lexical HTML CommentID Plaintext FeatureImage Featured Type Status Locale Visibility EmailRecipientFilter CreatedAt CreatedBy UpdatedAt UpdatedBy PublishedAt PublishedBy CustomExcerpt CodeinjectionHead CodeinjectionFoot CustomTemplate CanonicalUrl NewsletterId ShowTitleAndFeatureImage", " ")
+
+// fakePostRow returns a row for a published post.
+func fakePostRow(id, slug, visibility string) []driver.Value {
+	return []driver.Value{
+		id, "uuid-" + id, "Post " + id, slug, nil, nil, "<p>Hello from " + slug + "</p>", nil, nil, nil,
+		false, "post", "published", nil, visibility, "all",
+		"2024-01-01 00:00:00", "1", "2024-01-02 00:00:00", nil, "2024-01-01 00:00:00", nil,
+		nil, nil, nil, nil, nil, nil, true,
+	}
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	switch {
+	case strings.Contains(s.query, "MAX(updated_at)"):
+		return &fakeRows{cols: []string{"UpdatedAt", "Count"}, rows: [][]driver.Value{{"2024-01-02 00:00:00", int64(2)}}}, nil
+	case strings.HasPrefix(s.query, "SELECT id FROM posts"):
+		return &fakeRows{cols: []string{"ID"}, rows: [][]driver.Value{{"1"}, {"2"}}}, nil
+	case strings.Contains(s.query, "FROM posts_meta"):
+		return &fakeRows{cols: []string{"PostID", "FeatureImageAlt", "FeatureImageCaption"}}, nil
+	case strings.Contains(s.query, "FROM posts"):
+		return &fakeRows{cols: fakePostColumns, rows: [][]driver.Value{
+			fakePostRow("1", "hello", "public"),
+			fakePostRow("2", "secret", "members"),
+		}}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected query: %v", s.query)
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+	i    int
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.rows) {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[r.i])
+	r.i++
+
+	return nil
+}
+
+// writeConfig writes a config that uses the fake driver, skips members-only
+// posts and renders to an output directory inside dir, returning the config's
+// path.
+func writeConfig(t *testing.T, dir string, extra map[string]any) string {
+	t.Helper()
+
+	c := map[string]any{
+		"databaseDriver": "ghost-to-hugo-fake",
+		"outputPath":     filepath.Join(dir, "content"),
+		"ghostUrl":       "https://example.com",
+		"postVisibilities": map[string]bool{
+			"public":  true,
+			"members": false,
+		},
+	}
+
+	for k, v := range extra {
+		c[k] = v
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Logf("failed to marshal config: %v", err.Error())
+		t.FailNow()
+	}
+
+	f := filepath.Join(dir, "config.json")
+
+	err = os.WriteFile(f, b, 0o644)
+	if err != nil {
+		t.Logf("failed to write config: %v", err.Error())
+		t.FailNow()
+	}
+
+	return f
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		// Extra config fields.
+		config map[string]any
+		// Commands to run first, which must succeed.
+		before [][]string
+		args   []string
+		// Cancel the context before running, for commands that run until
+		// they're stopped.
+		cancel bool
+		code   int
+		stdout []string
+		// Strings that must not be in stdout.
+		notStdout []string
+		stderr    []string
+		// Files that must exist in the output directory afterwards.
+		files []string
+		// Files that must not exist in the output directory afterwards.
+		noFiles []string
+	}{
+		{name: "no command", args: nil, code: exitUsage, stderr: []string{"Usage:"}},
+		{name: "help", args: []string{"help"}, code: exitOK, stdout: []string{"Commands:", "validate-config"}},
+		{name: "help flag", args: []string{"-h"}, code: exitOK, stdout: []string{"Commands:"}},
+		{name: "bad flag", args: []string{"-nope", "list"}, code: exitUsage, stderr: []string{"-nope"}},
+		{name: "unknown command", args: []string{"nope"}, code: exitUsage, stderr: []string{`unknown command "nope"`}},
+		{name: "validate-config", args: []string{"validate-config"}, code: exitOK, stdout: []string{": ok"}},
+		{name: "validate-config ping", args: []string{"validate-config", "-ping"}, code: exitOK, stdout: []string{": ok"}},
+		{
+			name:   "validate-config invalid",
+			config: map[string]any{"imageSrcset": "resize"},
+			args:   []string{"validate-config"},
+			code:   exitConfig,
+			stderr: []string{"failed to load config"},
+		},
+		{name: "validate-config extra argument", args: []string{"validate-config", "x"}, code: exitUsage},
+		{
+			name:   "list",
+			args:   []string{"list"},
+			code:   exitOK,
+			stdout: []string{"SELECTED", "true      hello", "false     secret"},
+		},
+		{name: "list skipped", args: []string{"list", "-skipped"}, code: exitOK, stdout: []string{"secret"}, notStdout: []string{"hello"}},
+		{name: "render", args: []string{"render", "hello"}, code: exitOK, stdout: []string{"slug: hello", "Hello from hello"}},
+		{name: "render skipped", args: []string{"render", "secret"}, code: exitOK, stdout: []string{"Hello from secret"}, stderr: []string{"would be skipped"}},
+		{name: "render missing", args: []string{"render", "missing"}, code: exitError, stderr: []string{`no post with slug "missing"`}},
+		{name: "render without slug", args: []string{"render"}, code: exitUsage},
+		{name: "diff", args: []string{"diff"}, code: exitChanges, stdout: []string{"+++", "Hello from hello"}, notStdout: []string{"secret"}},
+		{name: "diff quiet", args: []string{"diff", "-q"}, code: exitChanges, notStdout: []string{"+++"}},
+		{name: "diff unchanged", before: [][]string{{"export"}}, args: []string{"diff"}, code: exitOK},
+		{name: "export", args: []string{"export"}, code: exitOK, stdout: []string{"rendered 1 posts"}, files: []string{"hello"}},
+		{
+			name:    "export unsafe",
+			config:  map[string]any{"postVisibilities": map[string]bool{"public": true, "members": true}},
+			args:    []string{"export"},
+			code:    exitUnsafe,
+			stderr:  []string{"non-public content was rendered"},
+			files:   []string{"hello"},
+			noFiles: []string{"secret"},
+		},
+		{name: "export dry run", args: []string{"export", "-dry-run"}, code: exitOK, stdout: []string{"Hello from hello"}, notStdout: []string{"rendered"}},
+		{name: "export bad workers", args: []string{"export", "-workers", "x"}, code: exitUsage},
+		{name: "watch", args: []string{"watch"}, cancel: true, code: exitOK, files: []string{"hello"}},
+		{name: "watch bad interval", args: []string{"watch", "-interval", "-1s"}, code: exitUsage, stderr: []string{"watchInterval"}},
+		{name: "serve without secret", args: []string{"serve"}, code: exitConfig, stderr: []string{"webhookSecret"}},
+		{
+			name:   "serve",
+			config: map[string]any{"webhookSecret": "s3cret"},
+			args:   []string{"serve", "-addr", "127.0.0.1:0"},
+			cancel: true,
+			code:   exitOK,
+			stdout: []string{"listening for webhooks on 127.0.0.1:0"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			f := writeConfig(t, dir, test.config)
+
+			for _, args := range test.before {
+				var stderr bytes.Buffer
+
+				code := run(context.Background(), append([]string{"-f", f}, args...), io.Discard, &stderr)
+				if code != exitOK {
+					t.Logf("%v exited with %v: %v", args, code, stderr.String())
+					t.FailNow()
+				}
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if test.cancel {
+				cancel()
+			}
+
+			args := test.args
+			if len(args) > 0 {
+				args = append([]string{"-f", f}, args...)
+			}
+
+			var stdout, stderr bytes.Buffer
+
+			code := run(ctx, args, &stdout, &stderr)
+			if code != test.code {
+				t.Logf("got exit code %v, want %v\nstdout:\n%v\nstderr:\n%v", code, test.code, stdout.String(), stderr.String())
+				t.Fail()
+			}
+
+			for _, s := range test.stdout {
+				if !strings.Contains(stdout.String(), s) {
+					t.Logf("expected %q in stdout:\n%v", s, stdout.String())
+					t.Fail()
+				}
+			}
+
+			for _, s := range test.notStdout {
+				if strings.Contains(stdout.String(), s) {
+					t.Logf("did not expect %q in stdout:\n%v", s, stdout.String())
+					t.Fail()
+				}
+			}
+
+			for _, s := range test.stderr {
+				if !strings.Contains(stderr.String(), s) {
+					t.Logf("expected %q in stderr:\n%v", s, stderr.String())
+					t.Fail()
+				}
+			}
+
+			for _, name := range test.files {
+				matches, err := filepath.Glob(filepath.Join(dir, "content", "*"+name+"*"))
+				if err != nil || len(matches) == 0 {
+					t.Logf("expected a file for %v in the output directory", name)
+					t.Fail()
+				}
+			}
+
+			for _, name := range test.noFiles {
+				matches, err := filepath.Glob(filepath.Join(dir, "content", "*"+name+"*"))
+				if err != nil || len(matches) > 0 {
+					t.Logf("expected no file for %v in the output directory, got %v", name, matches)
+					t.Fail()
+				}
+			}
+		})
+	}
+}
@@ -8,3 +8,7 @@ require (
 )
 
 require filippo.io/edwards25519 v1.1.0 // indirect
+
+// Build against the library in this repository, so that the example always
+// uses its current API.
+replace github.com/charles-m-knox/ghost-to-hugo => ../..
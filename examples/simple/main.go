@@ -1,10 +1,12 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"flag"
-	"fmt"
 	"log"
+	"log/slog"
+	"os"
 	"time"
 
 	g2h "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
@@ -26,44 +28,131 @@ func main() {
 		log.Fatalf("failed to load config: %v", err.Error())
 	}
 
-	db, err := sql.Open("mysql", c.MySQLConnectionString)
+	// the library is silent by default; send its messages to the standard
+	// logger alongside the example's own
+	c.Logger = slog.Default()
+
+	db, err := c.OpenDB()
 	if err != nil {
 		log.Fatalf("failed to connect to db: %v", err.Error())
 	}
 
+	defer db.Close()
+
 	db.SetConnMaxLifetime(time.Minute * 3)
 	db.SetMaxOpenConns(10)
 	db.SetMaxIdleConns(10)
 
+	ctx := context.Background()
+
 	// force read-only since this operation does not require write privileges
-	_, err = db.Exec("SET SESSION TRANSACTION READ ONLY")
+	_, err = db.ExecContext(ctx, "SET SESSION TRANSACTION READ ONLY")
 	if err != nil {
 		log.Fatalf("failed to set read-only session: %v", err.Error())
 	}
 
-	rows, err := db.Query(fmt.Sprintf("SELECT %v FROM posts", g2h.QUERY_POSTS_FIELDS))
-	if err != nil {
-		log.Fatalf("failed to query posts from db: %v", err.Error())
-	}
+	if c.SiteConfigPath != "" {
+		s, err := g2h.LoadSiteSettings(ctx, db)
+		if err != nil {
+			log.Fatalf("failed to load site settings: %v", err.Error())
+		}
+
+		err = c.WriteSiteConfig(s, c.SiteConfigPath)
+		if err != nil {
+			log.Fatalf("failed to write site config: %v", err.Error())
+		}
 
-	defer rows.Close()
+		log.Printf("wrote site config to %v", c.SiteConfigPath)
+	}
 
-	for rows.Next() {
-		post, err := c.GetGhostPost(rows)
+	// archived newsletters include their newsletter's name and slug in front
+	// matter
+	if c.NewsletterMode == g2h.NewsletterModeArchive {
+		err = c.LoadNewsletters(ctx, db)
 		if err != nil {
-			log.Fatalf("failed to get ghost post from row: %v", err.Error())
+			log.Fatalf("failed to load newsletters: %v", err.Error())
 		}
+	}
 
-		if !c.IsValid(post) {
-			log.Printf("skipping post %v", post.Title)
-			continue
+	// with continueOnError set, broken posts are collected and reported at
+	// the end instead of stopping the whole export
+	var failed g2h.PostErrors
+
+	// LoadPosts also loads each post's metadata, and its tags if routes or
+	// template files need them
+	posts, err := c.LoadPosts(ctx, db)
+	if err != nil && (!errors.As(err, &failed) || errors.Is(err, g2h.ErrTooManyErrors)) {
+		log.Fatalf("failed to load posts: %v", err.Error())
+	}
+
+	for _, pe := range failed {
+		log.Printf("skipping broken post: %v", pe.Error())
+	}
+
+	fail := func(err error) {
+		var pe *g2h.PostError
+		if !c.ContinueOnError || !errors.As(err, &pe) {
+			log.Fatalf("failed to export post: %v", err.Error())
+		}
+
+		log.Printf("skipping broken post: %v", pe.Error())
+		failed = append(failed, pe)
+		if c.MaxErrors > 0 && len(failed) > c.MaxErrors {
+			log.Fatalf("%v: %v", g2h.ErrTooManyErrors, failed.Error())
 		}
+	}
+
+	var files []g2h.RenderedFile
 
+	// Select counts every post in the run report and records why any were
+	// skipped
+	for _, post := range c.Select(posts) {
 		n, f, err := c.RenderOne(post)
 		if err != nil {
-			log.Fatalf("failed to render post in main loop: %v", err.Error())
+			fail(err)
+			continue
 		}
 
 		log.Printf("wrote %v to %v (%v)", n, f, post.Title)
+		files = append(files, g2h.RenderedFile{Path: f, Post: post})
+	}
+
+	r, err := c.Verify(files)
+	if err != nil {
+		for _, e := range r.Entries {
+			if !e.Allowed {
+				log.Printf("unsafe: %v (%v): %v", e.Path, e.Title, e.Reason)
+
+				// don't leave non-public content behind for the site build
+				err := os.Remove(e.Path)
+				if err != nil {
+					log.Printf("failed to remove %v: %v", e.Path, err.Error())
+				}
+			}
+		}
+
+		log.Fatalf("failed to verify rendered posts: %v", err.Error())
+	}
+
+	if c.RedirectsOutputPath != "" {
+		posts := make([]g2h.GhostPost, 0, len(files))
+		for _, f := range files {
+			posts = append(posts, f.Post)
+		}
+
+		err = c.WriteRedirects(posts)
+		if err != nil {
+			log.Fatalf("failed to write redirects: %v", err.Error())
+		}
+	}
+
+	// writes reportPath/metricsPath, if either is set in the config
+	err = c.WriteReport()
+	if err != nil {
+		log.Fatalf("failed to write run report: %v", err.Error())
+	}
+
+	if len(failed) > 0 {
+		log.Fatalf("%v", failed.Error())
 	}
 }
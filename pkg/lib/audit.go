@@ -0,0 +1,211 @@
+package ghosttohugo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// ErrUnsafePublish is returned by [Config.Verify] when one or more rendered
+// files came from a post that should not be on a public site, such as a paid
+// post or an email-only newsletter.
+var ErrUnsafePublish = errors.New("non-public content was rendered")
+
+// Ghost values that the audit treats as safe to publish.
+const (
+	GhostPostStatusPublished  = "published"
+	GhostPostStatusSent       = "sent"
+	GhostPostVisibilityPublic = "public"
+	GhostPostTypePost         = "post"
+	GhostPostTypePage         = "page"
+)
+
+// RenderedFile pairs a file that was written to disk with the post it was
+// rendered from.
+type RenderedFile struct {
+	Path string
+	Post GhostPost
+}
+
+// AuditEntry describes a single rendered file and why it was or was not
+// allowed to be published.
+type AuditEntry struct {
+	Path                 string `json:"path"`
+	PostID               string `json:"postId"`
+	UUID                 string `json:"uuid"`
+	Slug                 string `json:"slug"`
+	Title                string `json:"title"`
+	Type                 string `json:"type"`
+	Status               string `json:"status"`
+	Visibility           string `json:"visibility"`
+	EmailRecipientFilter string `json:"emailRecipientFilter"`
+	// True if this file is safe to publish.
+	Allowed bool `json:"allowed"`
+	// True if the post would have been rejected but is present in
+	// [Config.PublishAllowlist].
+	Allowlisted bool `json:"allowlisted"`
+	// Human-readable explanation of the decision.
+	Reason string `json:"reason"`
+}
+
+// AuditReport is the machine-readable result of [Config.Verify].
+type AuditReport struct {
+	GeneratedAt time.Time    `json:"generatedAt"`
+	Entries     []AuditEntry `json:"entries"`
+	Violations  int          `json:"violations"`
+}
+
+// isAllowlisted returns true if the post's ID, UUID or slug is present in the
+// user-configured allowlist.
+func (c *Config) isAllowlisted(p GhostPost) bool {
+	for _, a := range c.PublishAllowlist {
+		if a == "" {
+			continue
+		}
+
+		if a == p.ID || a == p.UUID || a == p.Slug {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unsafeReason returns a non-empty explanation if the post should never be
+// published without being explicitly allowlisted. The email recipient filter
+// only says who a post was emailed to, and a published post is on the web for
+// everyone regardless, so it is only checked as part of rejecting email-only
+// posts.
+func (c *Config) unsafeReason(p GhostPost) string {
+	if p.Type != GhostPostTypePost && p.Type != GhostPostTypePage {
+		return fmt.Sprintf("type %q is not a post or page", p.Type)
+	}
+
+	if p.Visibility != GhostPostVisibilityPublic {
+		return fmt.Sprintf("visibility %q is not public", p.Visibility)
+	}
+
+	// archived newsletters have been explicitly opted in to via NewsletterMode
+	if c.NewsletterMode == NewsletterModeArchive && p.IsNewsletter() {
+		return ""
+	}
+
+	if p.Status == GhostPostStatusSent {
+		return fmt.Sprintf("email-only post (status %q, email recipient filter %q)", p.Status, p.EmailRecipientFilter)
+	}
+
+	if p.Status != GhostPostStatusPublished && !p.IsDraft && !c.PublishDrafts {
+		return fmt.Sprintf("status %q is not published but the post is not marked as a draft", p.Status)
+	}
+
+	return ""
+}
+
+// AuditPost determines whether a single rendered file is safe to publish.
+func (c *Config) AuditPost(f RenderedFile) AuditEntry {
+	p := f.Post
+	e := AuditEntry{
+		Path:                 f.Path,
+		PostID:               p.ID,
+		UUID:                 p.UUID,
+		Slug:                 p.Slug,
+		Title:                p.Title,
+		Type:                 p.Type,
+		Status:               p.Status,
+		Visibility:           p.Visibility,
+		EmailRecipientFilter: p.EmailRecipientFilter,
+	}
+
+	reason := c.unsafeReason(p)
+	switch {
+	case reason == "":
+		e.Allowed = true
+		e.Reason = fmt.Sprintf("%v post with visibility %q", p.Status, p.Visibility)
+	case c.isAllowlisted(p):
+		e.Allowed = true
+		e.Allowlisted = true
+		e.Reason = fmt.Sprintf("allowlisted: %v", reason)
+	default:
+		e.Reason = reason
+	}
+
+	return e
+}
+
+// Verify checks every rendered file against its source post's visibility,
+// status, type and email recipient filter. If any file came from a
+// non-public or email-only post that hasn't been allowlisted via
+// [Config.PublishAllowlist], an error wrapping [ErrUnsafePublish] is returned.
+//
+// Verify only checks the files; it doesn't remove them. [Config.RenderAll]
+// and [Watcher] remove the files that fail verification before returning the
+// error, so that a site build that ignores it can't publish them.
+//
+// If [Config.AuditReportPath] is set, the report is written there as JSON
+// regardless of the outcome, unless DryRun is set.
+func (c *Config) Verify(files []RenderedFile) (AuditReport, error) {
+	r := AuditReport{
+		GeneratedAt: time.Now(),
+		Entries:     make([]AuditEntry, 0, len(files)),
+	}
+
+	for _, f := range files {
+		e := c.AuditPost(f)
+		if !e.Allowed {
+			r.Violations++
+			c.postLogger(f.Post).Error("non-public content was rendered", slog.String("path", f.Path), slog.String("reason", e.Reason))
+		}
+
+		r.Entries = append(r.Entries, e)
+	}
+
+	if c.AuditReportPath != "" && !c.DryRun {
+		err := c.WriteAuditReport(r)
+		if err != nil {
+			return r, err
+		}
+	}
+
+	if r.Violations > 0 {
+		return r, fmt.Errorf("%w: %v file(s) failed verification", ErrUnsafePublish, r.Violations)
+	}
+
+	return r, nil
+}
+
+// removeUnsafe removes every file in r that wasn't allowed to be published.
+func (c *Config) removeUnsafe(r AuditReport) error {
+	for _, e := range r.Entries {
+		if e.Allowed {
+			continue
+		}
+
+		c.logger().Warn("removing non-public file", slog.String("post_id", e.PostID), slog.String("slug", e.Slug), slog.String("path", e.Path))
+
+		err := removeFile(e.Path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteAuditReport writes the report as indented JSON to
+// [Config.AuditReportPath].
+func (c *Config) WriteAuditReport(r AuditReport) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit report: %w", err)
+	}
+
+	err = os.WriteFile(c.AuditReportPath, b, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write audit report to %v: %w", c.AuditReportPath, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package ghosttohugo_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"testing"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+func TestAuditPost(t *testing.T) {
+	t.Parallel()
+
+	public := ghosttohugo.GhostPost{
+		ID:         "1",
+		Slug:       "public-post",
+		Type:       "post",
+		Status:     "published",
+		Visibility: "public",
+	}
+
+	paid := public
+	paid.ID = "2"
+	paid.Slug = "paid-post"
+	paid.Visibility = "paid"
+
+	sent := public
+	sent.ID = "3"
+	sent.Slug = "newsletter"
+	sent.Status = "sent"
+	sent.EmailRecipientFilter = "status:-free"
+
+	scheduled := public
+	scheduled.ID = "4"
+	scheduled.Status = "scheduled"
+
+	draft := public
+	draft.ID = "5"
+	draft.Status = "draft"
+	draft.IsDraft = true
+
+	page := public
+	page.ID = "6"
+	page.Type = "page"
+
+	unknown := public
+	unknown.ID = "7"
+	unknown.Slug = "unknown-type"
+	unknown.Type = "custom"
+
+	tests := []struct {
+		c           ghosttohugo.Config
+		p           ghosttohugo.GhostPost
+		allowed     bool
+		allowlisted bool
+	}{
+		{ghosttohugo.Config{}, public, true, false},
+		{ghosttohugo.Config{}, paid, false, false},
+		{ghosttohugo.Config{}, sent, false, false},
+		{ghosttohugo.Config{}, scheduled, false, false},
+		{ghosttohugo.Config{PublishDrafts: true}, scheduled, true, false},
+		{ghosttohugo.Config{}, draft, true, false},
+		{ghosttohugo.Config{PublishAllowlist: []string{"paid-post"}}, paid, true, true},
+		{ghosttohugo.Config{PublishAllowlist: []string{"3"}}, sent, true, true},
+		{ghosttohugo.Config{PublishAllowlist: []string{"", "paid-post"}}, sent, false, false},
+		{ghosttohugo.Config{}, page, true, false},
+		{ghosttohugo.Config{}, unknown, false, false},
+		{ghosttohugo.Config{PublishAllowlist: []string{"unknown-type"}}, unknown, true, true},
+	}
+
+	for i, test := range tests {
+		got := test.c.AuditPost(ghosttohugo.RenderedFile{Path: "x.md", Post: test.p})
+		if got.Allowed != test.allowed {
+			t.Logf("test %v failed: got Allowed=%v, want %v (%v)", i, got.Allowed, test.allowed, got.Reason)
+			t.Fail()
+		}
+
+		if got.Allowlisted != test.allowlisted {
+			t.Logf("test %v failed: got Allowlisted=%v, want %v", i, got.Allowlisted, test.allowlisted)
+			t.Fail()
+		}
+
+		if got.Reason == "" {
+			t.Logf("test %v failed: empty reason", i)
+			t.Fail()
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	c := ghosttohugo.Config{AuditReportPath: path.Join(dir, "audit.json")}
+
+	files := []ghosttohugo.RenderedFile{
+		{Path: "a.md", Post: ghosttohugo.GhostPost{ID: "a", Type: "post", Status: "published", Visibility: "public"}},
+		{Path: "b.md", Post: ghosttohugo.GhostPost{ID: "b", Type: "post", Status: "published", Visibility: "members"}},
+	}
+
+	r, err := c.Verify(files)
+	if !errors.Is(err, ghosttohugo.ErrUnsafePublish) {
+		t.Logf("expected ErrUnsafePublish, got %v", err)
+		t.Fail()
+	}
+
+	if r.Violations != 1 || len(r.Entries) != 2 {
+		t.Logf("unexpected report: %v violations, %v entries", r.Violations, len(r.Entries))
+		t.Fail()
+	}
+
+	b, err := os.ReadFile(c.AuditReportPath)
+	if err != nil {
+		t.Logf("failed to read audit report: %v", err.Error())
+		t.FailNow()
+	}
+
+	var got ghosttohugo.AuditReport
+	err = json.Unmarshal(b, &got)
+	if err != nil {
+		t.Logf("failed to unmarshal audit report: %v", err.Error())
+		t.FailNow()
+	}
+
+	if got.Violations != 1 || got.Entries[1].PostID != "b" || got.Entries[1].Allowed {
+		t.Logf("unexpected audit report contents: %v", string(b))
+		t.Fail()
+	}
+
+	_, err = c.Verify(files[:1])
+	if err != nil {
+		t.Logf("expected no error for public-only files, got %v", err.Error())
+		t.Fail()
+	}
+}
+
+func TestRenderAllRemovesUnsafe(t *testing.T) {
+	t.Parallel()
+
+	c := ghosttohugo.Config{OutputPath: t.TempDir()}
+	c.ApplyDefaults()
+	c.Process()
+
+	// only the selection would normally keep paid posts out
+	c.PostVisibilities["paid"] = true
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	public := ghosttohugo.GhostPost{
+		ID:         "1",
+		Slug:       "public-post",
+		Type:       "post",
+		Status:     "published",
+		Visibility: "public",
+		HTML:       sql.NullString{String: "<p>public</p>", Valid: true},
+	}
+
+	paid := public
+	paid.ID = "2"
+	paid.Slug = "paid-post"
+	paid.Visibility = "paid"
+
+	err = c.RenderAll([]ghosttohugo.GhostPost{public, paid})
+	if !errors.Is(err, ghosttohugo.ErrUnsafePublish) {
+		t.Logf("expected ErrUnsafePublish, got %v", err)
+		t.Fail()
+	}
+
+	_, err = os.Stat(c.OutputFile(paid))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Logf("expected the paid post's file to be removed, got %v", err)
+		t.Fail()
+	}
+
+	_, err = os.Stat(c.OutputFile(public))
+	if err != nil {
+		t.Logf("expected the public post's file to be kept, got %v", err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,205 @@
+package ghosttohugo
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// shortcodes are the shortcodes that a post's cards were converted to, in
+// order. Each one's place in the HTML is marked by a placeholder comment
+// until the HTML has been processed, since processing would escape it. The
+// placeholders include a nonce that is random for each render, so that a
+// comment written by the post's author can't be mistaken for one.
+type shortcodes struct {
+	nonce string
+	list  []string
+}
+
+// placeholder returns the comment that marks where the i'th shortcode goes.
+func (s *shortcodes) placeholder(i int) string {
+	return fmt.Sprintf("<!--ghost-to-hugo:shortcode:%v:%v-->", s.nonce, i)
+}
+
+// cardConverter converts a Ghost card, given its start tag and inner HTML,
+// passing problems that were worked around to warn. It returns the HTML to
+// put in the card's place and, if the card becomes a shortcode, the
+// shortcode, which goes before the HTML. If ok is false, the card is kept as
+// it is.
+type cardConverter func(st xml.StartElement, inner string, warn func(msg string, err error)) (h string, shortcode string, ok bool)
+
+// card is a kind of Ghost card that the config converts.
+type card struct {
+	// The element and class that the card's outermost element has. If
+	// class is empty, any element with the name matches.
+	element, class string
+	convert        cardConverter
+}
+
+// matches returns true if st is the outermost element of the card.
+func (k card) matches(st xml.StartElement) bool {
+	return st.Name.Local == k.element && (k.class == "" || hasClass(st, k.class))
+}
+
+// cards returns the cards that the config converts, in the order that they
+// are matched.
+func (c *Config) cards() []card {
+	var cards []card
+
+	for _, mode := range c.Embeds {
+		if mode != "" && mode != EmbedKeep {
+			cards = append(cards, card{"figure", embedCardClass, c.convertEmbed})
+			break
+		}
+	}
+
+	if c.GalleryMode == GalleryModeShortcode || c.GalleryMode == GalleryModeFigures {
+		cards = append(cards, card{"figure", galleryCardClass, c.convertGallery})
+	}
+
+	if c.ImageShortcode != "" {
+		cards = append(cards, card{"figure", imageCardClass, c.convertImage})
+	}
+
+	if c.CodeBlocks == CodeBlocksFenced || c.CodeBlocks == CodeBlocksHighlight {
+		cards = append(cards,
+			card{"figure", codeCardClass, c.convertCodeCard},
+			card{"pre", "", c.convertCode},
+		)
+	}
+
+	return cards
+}
+
+// hasClass returns true if st's class attribute contains class.
+func hasClass(st xml.StartElement, class string) bool {
+	for _, a := range st.Attr {
+		if a.Name.Local == "class" {
+			for _, c := range strings.Fields(a.Value) {
+				if c == class {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// convertCards replaces the Ghost cards in h (mostly figures such as
+// <figure class="kg-card kg-embed-card">) that the config converts. Cards
+// that become shortcodes are replaced with placeholder comments, and the
+// shortcodes are returned so that [Config.expandShortcodes] can put them in
+// place once the HTML has been processed.
+func (c *Config) convertCards(h string, warn func(msg string, err error)) (string, shortcodes, error) {
+	var sc shortcodes
+
+	cards := c.cards()
+	if len(cards) == 0 {
+		return h, sc, nil
+	}
+
+	nonce := make([]byte, 8)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", sc, fmt.Errorf("failed to generate shortcode nonce: %w", err)
+	}
+
+	sc.nonce = hex.EncodeToString(nonce)
+
+	x := xml.NewDecoder(strings.NewReader(h))
+	x.Strict = false
+	x.AutoClose = xml.HTMLAutoClose
+	x.Entity = xml.HTMLEntity
+
+	var o bytes.Buffer
+	xe := xml.NewEncoder(&o)
+
+	for {
+		start := x.InputOffset()
+
+		t, err := x.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return "", sc, fmt.Errorf("token parsing error: %w", err)
+		}
+
+		var convert cardConverter
+
+		st, ok := t.(xml.StartElement)
+		if ok {
+			for _, k := range cards {
+				if k.matches(st) {
+					convert = k.convert
+					break
+				}
+			}
+		}
+
+		if convert == nil {
+			err = xe.EncodeToken(xml.CopyToken(t))
+			if err != nil {
+				return "", sc, fmt.Errorf("failed to re-encode token: %w", err)
+			}
+
+			continue
+		}
+
+		var el struct {
+			Inner string `xml:",innerxml"`
+		}
+
+		err = x.DecodeElement(&el, &st)
+		if err != nil {
+			return "", sc, fmt.Errorf("failed to decode card: %w", err)
+		}
+
+		out, shortcode, ok := convert(st, el.Inner, warn)
+		switch {
+		case !ok:
+			out = h[start:x.InputOffset()]
+		case shortcode != "":
+			out = sc.placeholder(len(sc.list)) + out
+			sc.list = append(sc.list, shortcode)
+		}
+
+		err = xe.Flush()
+		if err != nil {
+			return "", sc, fmt.Errorf("failed to flush token re-encoder: %w", err)
+		}
+
+		o.WriteString(out)
+	}
+
+	err = xe.Flush()
+	if err != nil {
+		return "", sc, fmt.Errorf("failed to flush token re-encoder: %w", err)
+	}
+
+	return o.String(), sc, nil
+}
+
+// expandShortcodes replaces the placeholders left by [Config.convertCards]
+// with their shortcodes, closing the raw HTML shortcode around each one so
+// that Hugo renders it.
+func (c *Config) expandShortcodes(h string, sc shortcodes) string {
+	for i, s := range sc.list {
+		h = strings.Replace(h, sc.placeholder(i), c.RawShortcodeEnd+"\n"+s+"\n"+c.RawShortcodeStart, 1)
+	}
+
+	return h
+}
+
+// shortcodeParam quotes s as a shortcode parameter value.
+func shortcodeParam(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
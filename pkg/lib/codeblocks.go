@@ -0,0 +1,268 @@
+package ghosttohugo
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Values for [Config.CodeBlocks].
+const (
+	// Code blocks are left as HTML. This is the default.
+	CodeBlocksKeep = "keep"
+	// Code blocks are replaced with fenced Markdown code blocks, e.g.
+	// ```go {linenos=table}, outside of the raw HTML shortcode, so that
+	// Hugo highlights them.
+	CodeBlocksFenced = "fenced"
+	// Code blocks are replaced with Hugo's highlight shortcode, e.g.
+	// {{< highlight go "linenos=table" >}}, outside of the raw HTML
+	// shortcode.
+	CodeBlocksHighlight = "highlight"
+)
+
+// codeCardClass is the class of the figure that Ghost wraps code blocks with
+// captions in. Code blocks without captions are bare <pre> elements.
+const codeCardClass = "kg-code-card"
+
+var (
+	// languageRegexp matches the language in a class such as language-go,
+	// which is what Ghost (and Prism) use, or lang-go.
+	languageRegexp = regexp.MustCompile(`^(?:language|lang)-([\w#+.-]+)$`)
+	// shortcodeInCodeRegexp matches shortcode syntax in code, which Hugo
+	// would otherwise try to render.
+	shortcodeInCodeRegexp = regexp.MustCompile(`\{\{([<%])(.*?)([>%])\}\}`)
+	// lineRangeRegexp matches a line number or range in Prism's data-line
+	// attribute, such as 4 or 6-8.
+	lineRangeRegexp = regexp.MustCompile(`^\d+(?:-\d+)?$`)
+)
+
+// codeBlock is the content of a Ghost code card.
+type codeBlock struct {
+	Code     string
+	Language string
+	// Whether the block asks for line numbers with Prism's line-numbers
+	// class.
+	LineNumbers bool
+	// The first line number, from Prism's data-start attribute.
+	LineStart int
+	// Highlighted lines and ranges, from Prism's data-line attribute.
+	Lines []string
+	// The card's caption, as HTML, if any.
+	Caption string
+}
+
+// readAttributes reads a code block's attributes from its <pre> or <code>
+// start tag.
+func (b *codeBlock) readAttributes(st xml.StartElement) {
+	for _, class := range strings.Fields(attr(st, "class")) {
+		if m := languageRegexp.FindStringSubmatch(class); m != nil {
+			b.Language = strings.ToLower(m[1])
+		}
+
+		if class == "line-numbers" {
+			b.LineNumbers = true
+		}
+	}
+
+	if n, err := strconv.Atoi(attr(st, "data-start")); err == nil && n > 0 {
+		b.LineStart = n
+	}
+
+	for _, l := range strings.Split(attr(st, "data-line"), ",") {
+		l = strings.TrimSpace(l)
+		if lineRangeRegexp.MatchString(l) {
+			b.Lines = append(b.Lines, l)
+		}
+	}
+}
+
+// parseCodeBlock reads a <pre> element, given its start tag and inner HTML,
+// returning false if it doesn't contain a <code> element.
+func parseCodeBlock(pre xml.StartElement, inner string) (codeBlock, bool, error) {
+	var b codeBlock
+	b.readAttributes(pre)
+
+	x := xml.NewDecoder(strings.NewReader(inner))
+	x.Strict = false
+	x.AutoClose = xml.HTMLAutoClose
+	x.Entity = xml.HTMLEntity
+
+	var code strings.Builder
+	// how deep we are inside the <code> element, whose text (including that
+	// of any elements inside it, such as highlighting spans) is the code
+	depth := 0
+	found := false
+
+	for {
+		t, err := x.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return b, false, fmt.Errorf("token parsing error: %w", err)
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			if depth > 0 {
+				depth++
+			} else if t.Name.Local == "code" && !found {
+				b.readAttributes(t)
+				depth = 1
+				found = true
+			}
+		case xml.EndElement:
+			if depth > 0 {
+				depth--
+			}
+		case xml.CharData:
+			if depth > 0 {
+				code.Write(t)
+			}
+		}
+	}
+
+	b.Code = strings.TrimSuffix(code.String(), "\n")
+
+	return b, found, nil
+}
+
+// codeOptions returns the block's Hugo highlighting options.
+func (c *Config) codeOptions(b codeBlock, fenced bool) []string {
+	var opts []string
+
+	if c.CodeLineNumbers || b.LineNumbers || b.LineStart > 0 {
+		opts = append(opts, "linenos=table")
+	}
+
+	if b.LineStart > 0 {
+		opts = append(opts, fmt.Sprintf("linenostart=%v", b.LineStart))
+	}
+
+	if len(b.Lines) > 0 {
+		if !fenced {
+			opts = append(opts, "hl_lines="+strings.Join(b.Lines, " "))
+		} else {
+			lines := make([]string, len(b.Lines))
+			for i, l := range b.Lines {
+				lines[i] = l
+				if strings.Contains(l, "-") {
+					lines[i] = `"` + l + `"`
+				}
+			}
+
+			opts = append(opts, "hl_lines=["+strings.Join(lines, ",")+"]")
+		}
+	}
+
+	return opts
+}
+
+// codeShortcode returns the fenced code block or highlight shortcode for b.
+func (c *Config) codeShortcode(b codeBlock) string {
+	// shortcodes in code are commented out, which Hugo renders as the
+	// shortcode itself rather than calling it
+	code := shortcodeInCodeRegexp.ReplaceAllString(b.Code, "{{$1/*$2*/$3}}")
+
+	if c.CodeBlocks == CodeBlocksHighlight {
+		language := b.Language
+		if language == "" {
+			language = "text"
+		}
+
+		s := "{{< highlight " + language
+		if opts := c.codeOptions(b, false); len(opts) > 0 {
+			s += ` "` + strings.Join(opts, ",") + `"`
+		}
+
+		return s + " >}}\n" + code + "\n{{< /highlight >}}"
+	}
+
+	// the fence has to be longer than any run of backticks in the code
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+
+	s := fence + b.Language
+	if opts := c.codeOptions(b, true); len(opts) > 0 {
+		if b.Language == "" {
+			s += "text"
+		}
+
+		s += " {" + strings.Join(opts, ",") + "}"
+	}
+
+	return s + "\n" + code + "\n" + fence
+}
+
+// convertCode is the [cardConverter] for bare <pre> elements.
+func (c *Config) convertCode(st xml.StartElement, inner string, warn func(string, error)) (string, string, bool) {
+	b, ok, err := parseCodeBlock(st, inner)
+	if err != nil {
+		warn("failed to parse code block", err)
+		return "", "", false
+	}
+
+	if !ok {
+		return "", "", false
+	}
+
+	return "", c.codeShortcode(b), true
+}
+
+// convertCodeCard is the [cardConverter] for code cards with captions. The
+// caption follows the code block.
+func (c *Config) convertCodeCard(_ xml.StartElement, inner string, warn func(string, error)) (string, string, bool) {
+	x := xml.NewDecoder(strings.NewReader(inner))
+	x.Strict = false
+	x.AutoClose = xml.HTMLAutoClose
+	x.Entity = xml.HTMLEntity
+
+	for {
+		t, err := x.Token()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				warn("failed to parse code card", err)
+			}
+
+			return "", "", false
+		}
+
+		st, ok := t.(xml.StartElement)
+		if !ok || st.Name.Local != "pre" {
+			continue
+		}
+
+		var el struct {
+			Inner string `xml:",innerxml"`
+		}
+
+		err = x.DecodeElement(&el, &st)
+		if err != nil {
+			warn("failed to parse code card", err)
+			return "", "", false
+		}
+
+		b, ok, err := parseCodeBlock(st, el.Inner)
+		if err != nil || !ok {
+			return "", "", false
+		}
+
+		if m := figcaptionRegexp.FindStringSubmatch(inner); m != nil {
+			b.Caption = strings.TrimSpace(m[1])
+		}
+
+		h := ""
+		if b.Caption != "" {
+			h = `<p class="kg-code-caption">` + b.Caption + "</p>"
+		}
+
+		return h, c.codeShortcode(b), true
+	}
+}
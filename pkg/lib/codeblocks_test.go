@@ -0,0 +1,100 @@
+package ghosttohugo_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+func TestCodeBlocks(t *testing.T) {
+	t.Parallel()
+
+	bare := `<pre><code class="language-go">func main() {
+	if a &lt; b &amp;&amp; ok {
+		fmt.Println("{{&lt; youtube x &gt;}}")
+	}
+}
+</code></pre>`
+	card := `<figure class="kg-card kg-code-card"><pre class="line-numbers" data-start="10" data-line="2, 4-5">` +
+		"<code class=\"language-Python\">print(\"```\")</code></pre><figcaption>Printing <b>fences</b></figcaption></figure>"
+	plain := `<pre><code>plain text</code></pre>`
+	html := "<p>before</p>" + bare + card + plain + "<p>after</p>"
+
+	tests := []struct {
+		c       ghosttohugo.Config
+		want    []string
+		notWant []string
+	}{
+		{
+			ghosttohugo.Config{},
+			[]string{`<pre><code class="language-go">func main() {`, "kg-code-card"},
+			[]string{"```go"},
+		},
+		{
+			ghosttohugo.Config{CodeBlocks: ghosttohugo.CodeBlocksFenced},
+			[]string{
+				"<p>before</p>{{</ rawhtml >}}\n```go\nfunc main() {\n\tif a < b && ok {\n\t\tfmt.Println(\"{{</* youtube x */>}}\")\n\t}\n}\n```\n{{< rawhtml >}}",
+				"{{</ rawhtml >}}\n````python {linenos=table,linenostart=10,hl_lines=[2,\"4-5\"]}\nprint(\"```\")\n````\n{{< rawhtml >}}<p class=\"kg-code-caption\">Printing <b>fences</b></p>",
+				"{{</ rawhtml >}}\n```\nplain text\n```\n{{< rawhtml >}}<p>after</p>",
+			},
+			[]string{"<pre>", "kg-code-card"},
+		},
+		{
+			ghosttohugo.Config{CodeBlocks: ghosttohugo.CodeBlocksHighlight, CodeLineNumbers: true},
+			[]string{
+				"{{< highlight go \"linenos=table\" >}}\nfunc main() {",
+				"}\n{{< /highlight >}}",
+				"{{< highlight python \"linenos=table,linenostart=10,hl_lines=2 4-5\" >}}\nprint(\"```\")\n{{< /highlight >}}",
+				"{{< highlight text \"linenos=table\" >}}\nplain text\n{{< /highlight >}}",
+			},
+			[]string{"<pre>"},
+		},
+	}
+
+	for i, test := range tests {
+		c := test.c
+		c.ApplyDefaults()
+		c.Process()
+
+		err := c.Validate()
+		if err != nil {
+			t.Logf("test %v failed to validate: %v", i, err.Error())
+			t.FailNow()
+		}
+
+		err = c.ParseTemplate()
+		if err != nil {
+			t.Logf("test %v failed to parse template: %v", i, err.Error())
+			t.FailNow()
+		}
+
+		got, err := c.RenderString(ghosttohugo.GhostPost{Slug: "post", HTML: sql.NullString{String: html, Valid: true}})
+		if err != nil {
+			t.Logf("test %v failed to render: %v", i, err.Error())
+			t.Fail()
+			continue
+		}
+
+		for _, want := range test.want {
+			if !strings.Contains(got, want) {
+				t.Logf("test %v failed: expected %q in:\n%v", i, want, got)
+				t.Fail()
+			}
+		}
+
+		for _, notWant := range test.notWant {
+			if strings.Contains(got, notWant) {
+				t.Logf("test %v failed: didn't expect %q in:\n%v", i, notWant, got)
+				t.Fail()
+			}
+		}
+	}
+
+	c := ghosttohugo.Config{CodeBlocks: "markdown"}
+	if c.Validate() == nil {
+		t.Logf("expected an error for an invalid codeBlocks")
+		t.Fail()
+	}
+}
@@ -0,0 +1,119 @@
+package ghosttohugo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Values for [Config.CodeInjection].
+const (
+	// Per-post code injection is dropped. This is the default.
+	CodeInjectionStrip = "strip"
+	// Code injection is written to front matter (see
+	// [FrontMatterConfig.CodeInjectionHead]) so that theme partials can
+	// output it, e.g. {{ .Params.codeinjectionHead | safeHTML }} in the
+	// head partial.
+	CodeInjectionParams = "params"
+	// The head injection is placed before the post's HTML and the foot
+	// injection after it, inside the raw HTML shortcode.
+	CodeInjectionInline = "inline"
+)
+
+// Default front matter keys for code injection, see [FrontMatterConfig].
+const (
+	DefaultFrontMatterCodeInjectionHead = "codeinjectionHead"
+	DefaultFrontMatterCodeInjectionFoot = "codeinjectionFoot"
+)
+
+// codeInjectionWrapper wraps code injection while it's sanitized, since the
+// parser can't handle a void element such as <link> or <meta> at the very end
+// of its input.
+const codeInjectionWrapper = "ghost-to-hugo-code-injection"
+
+// stripScripts removes scripts, event handlers, javascript: URLs and the like
+// from code injection s using [codeInjectionPolicy], returning the result and
+// a count of what was removed, see [Config.SanitizeHTML].
+func stripScripts(s string) (string, map[string]int, error) {
+	h, removed, err := newSanitizer(codeInjectionPolicy()).sanitizeHTML("<" + codeInjectionWrapper + ">" + s + "</" + codeInjectionWrapper + ">")
+	if err != nil {
+		return "", removed, err
+	}
+
+	h = strings.TrimPrefix(h, "<"+codeInjectionWrapper+">")
+	h = strings.TrimSuffix(h, "</"+codeInjectionWrapper+">")
+
+	return h, removed, nil
+}
+
+// codeInjection returns the post's head and foot code injection according to
+// [Config.CodeInjection], with __GHOST_URL__ replaced and, if
+// CodeInjectionNoScript is set, scripts removed, see [stripScripts]. If code
+// injection can't be parsed well enough to remove its scripts, it's dropped
+// with a warning. Both are empty if code injection is stripped.
+func (c *Config) codeInjection(p GhostPost) (string, string) {
+	if c.CodeInjection == "" || c.CodeInjection == CodeInjectionStrip {
+		return "", ""
+	}
+
+	prepare := func(field string, s string) string {
+		s = strings.TrimSpace(strings.ReplaceAll(s, ghostUrl, c.GhostURL))
+		if !c.CodeInjectionNoScript || s == "" {
+			return s
+		}
+
+		s, removed, err := stripScripts(s)
+		if err != nil {
+			msg := "dropped code injection that couldn't be parsed to remove scripts"
+			c.postLogger(p).Warn(msg, "field", field, "error", err)
+			c.Report.addWarning(p, fmt.Sprintf("%v: %v: %v", msg, field, err))
+
+			return ""
+		}
+
+		if len(removed) > 0 {
+			c.postLogger(p).Debug("removed scripts from code injection", "field", field, "removed", describeRemoved(removed))
+		}
+
+		return strings.TrimSpace(s)
+	}
+
+	return prepare("codeinjection_head", p.CodeinjectionHead.String),
+		prepare("codeinjection_foot", p.CodeinjectionFoot.String)
+}
+
+// codeInjectionFrontMatter renders the head and foot code injection as YAML
+// front matter if CodeInjection is "params", without a trailing newline.
+func (c *Config) codeInjectionFrontMatter(head, foot string) string {
+	if c.CodeInjection != CodeInjectionParams {
+		return ""
+	}
+
+	var lines []string
+	if head != "" {
+		lines = append(lines, fmt.Sprintf("%v: %v", c.FrontMatter.CodeInjectionHead, yamlString(head)))
+	}
+
+	if foot != "" {
+		lines = append(lines, fmt.Sprintf("%v: %v", c.FrontMatter.CodeInjectionFoot, yamlString(foot)))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// inlineCodeInjection wraps h with the head and foot code injection if
+// CodeInjection is "inline".
+func (c *Config) inlineCodeInjection(h, head, foot string) string {
+	if c.CodeInjection != CodeInjectionInline {
+		return h
+	}
+
+	if head != "" {
+		h = head + "\n" + h
+	}
+
+	if foot != "" {
+		h = h + "\n" + foot
+	}
+
+	return h
+}
@@ -0,0 +1,186 @@
+package ghosttohugo_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+func TestCodeInjection(t *testing.T) {
+	t.Parallel()
+
+	post := ghosttohugo.GhostPost{
+		Slug: "post",
+		HTML: sql.NullString{String: "<p>Body</p>", Valid: true},
+		CodeinjectionHead: sql.NullString{
+			String: `<style>.x { background: url(__GHOST_URL__/a.png) }</style>` +
+				`<script>alert(1)</script>` +
+				`<script type="application/ld+json">{"@type":"Article"}</script>`,
+			Valid: true,
+		},
+		CodeinjectionFoot: sql.NullString{String: "<SCRIPT src=\"x.js\"></SCRIPT>\n<p>foot</p>", Valid: true},
+	}
+
+	tests := []struct {
+		mode     string
+		noScript bool
+		want     []string
+		notWant  []string
+	}{
+		{"", false, nil, []string{"<style>", "foot", "codeinjection"}},
+		{
+			ghosttohugo.CodeInjectionParams,
+			false,
+			[]string{
+				`codeinjectionHead: "<style>.x { background: url(https://example.com/a.png) }</style><script>alert(1)</script><script type=\"application/ld+json\">{\"@type\":\"Article\"}</script>"`,
+				`codeinjectionFoot: "<SCRIPT src=\"x.js\"></SCRIPT>\n<p>foot</p>"`,
+			},
+			nil,
+		},
+		{
+			ghosttohugo.CodeInjectionParams,
+			true,
+			[]string{
+				`codeinjectionHead: "<style>.x { background: url(https://example.com/a.png) }</style><script type=\"application/ld+json\">{\"@type\":\"Article\"}</script>"`,
+				`codeinjectionFoot: "<p>foot</p>"`,
+			},
+			[]string{"alert", "x.js"},
+		},
+		{
+			ghosttohugo.CodeInjectionInline,
+			true,
+			[]string{
+				"{{< rawhtml >}}\n<style>.x { background: url(https://example.com/a.png) }</style>" +
+					`<script type="application/ld+json">{"@type":"Article"}</script>` +
+					"\n<p>Body</p>\n<p>foot</p>\n{{</ rawhtml >}}",
+			},
+			[]string{"alert", "x.js", "codeinjection"},
+		},
+	}
+
+	for i, test := range tests {
+		c := ghosttohugo.Config{
+			GhostURL:              "https://example.com",
+			CodeInjection:         test.mode,
+			CodeInjectionNoScript: test.noScript,
+		}
+		c.ApplyDefaults()
+		c.Process()
+
+		err := c.Validate()
+		if err != nil {
+			t.Logf("test %v failed to validate: %v", i, err.Error())
+			t.FailNow()
+		}
+
+		err = c.ParseTemplate()
+		if err != nil {
+			t.Logf("test %v failed to parse template: %v", i, err.Error())
+			t.FailNow()
+		}
+
+		got, err := c.RenderString(post)
+		if err != nil {
+			t.Logf("test %v failed to render: %v", i, err.Error())
+			t.Fail()
+			continue
+		}
+
+		for _, want := range test.want {
+			if !strings.Contains(got, want) {
+				t.Logf("test %v failed: expected %q in:\n%v", i, want, got)
+				t.Fail()
+			}
+		}
+
+		for _, notWant := range test.notWant {
+			if strings.Contains(got, notWant) {
+				t.Logf("test %v failed: didn't expect %q in:\n%v", i, notWant, got)
+				t.Fail()
+			}
+		}
+	}
+
+	c := ghosttohugo.Config{CodeInjection: "head"}
+	if c.Validate() == nil {
+		t.Logf("expected an error for an invalid codeInjection")
+		t.Fail()
+	}
+}
+
+func TestCodeInjectionNoScript(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		head    string
+		want    string
+		warning bool
+	}{
+		{
+			"<meta name=\"x\" content=\"y\">\n<link rel=\"stylesheet\" href=\"/a.css\">",
+			`codeinjectionHead: "<meta name=\"x\" content=\"y\"></meta>\n<link rel=\"stylesheet\" href=\"/a.css\"></link>"`,
+			false,
+		},
+		{
+			`<img src="x" onerror="alert(1)"><a href=" javascript:alert(1)">x</a>`,
+			`codeinjectionHead: "<img src=\"x\"></img><a>x</a>"`,
+			false,
+		},
+		{
+			`<iframe srcdoc="&lt;script&gt;alert(1)&lt;/script&gt;"></iframe><p>ok</p>`,
+			`codeinjectionHead: "<p>ok</p>"`,
+			false,
+		},
+		{
+			// a script split around another one, which a single pass of a
+			// regular expression would have joined back together
+			`<scr<script></script>ipt>alert(1)</script>`,
+			"",
+			true,
+		},
+	}
+
+	for i, test := range tests {
+		c := ghosttohugo.Config{
+			CodeInjection:         ghosttohugo.CodeInjectionParams,
+			CodeInjectionNoScript: true,
+			Report:                ghosttohugo.NewRunReport(),
+		}
+		c.ApplyDefaults()
+		c.Process()
+
+		err := c.ParseTemplate()
+		if err != nil {
+			t.Logf("test %v failed to parse template: %v", i, err.Error())
+			t.FailNow()
+		}
+
+		got, err := c.RenderString(ghosttohugo.GhostPost{
+			Slug:              "post",
+			HTML:              sql.NullString{String: "<p>Body</p>", Valid: true},
+			CodeinjectionHead: sql.NullString{String: test.head, Valid: true},
+		})
+		if err != nil {
+			t.Logf("test %v failed to render: %v", i, err.Error())
+			t.Fail()
+			continue
+		}
+
+		if !strings.Contains(got, test.want) {
+			t.Logf("test %v failed: expected %q in:\n%v", i, test.want, got)
+			t.Fail()
+		}
+
+		if strings.Contains(got, "alert") || strings.Contains(got, "onerror") {
+			t.Logf("test %v failed: script left in:\n%v", i, got)
+			t.Fail()
+		}
+
+		if test.warning != (len(c.Report.Warnings) == 1) {
+			t.Logf("test %v failed: unexpected warnings %+v", i, c.Report.Warnings)
+			t.Fail()
+		}
+	}
+}
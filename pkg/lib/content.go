@@ -0,0 +1,90 @@
+package ghosttohugo
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DefaultExcerptWords is the number of words in a generated excerpt if
+// [Config.ExcerptWords] is not set.
+const DefaultExcerptWords = 50
+
+// Heading is an entry in a post's table of contents.
+type Heading struct {
+	// 1 for <h1>, 2 for <h2>, and so on.
+	Level int
+	// The heading's id attribute, which Ghost sets on every heading, or a
+	// slug of its text if it doesn't have one.
+	ID   string
+	Text string
+}
+
+var (
+	headingRegexp = regexp.MustCompile(`(?is)<h([1-6])\b([^>]*)>(.*?)</h[1-6]\s*>`)
+	idAttrRegexp  = regexp.MustCompile(`(?i)\bid\s*=\s*["']([^"']*)["']`)
+	imgSrcRegexp  = regexp.MustCompile(`(?is)<img\b[^>]*?\bsrc\s*=\s*["']([^"']*)["']`)
+)
+
+// Excerpt returns the post's custom excerpt if it has one, or otherwise the
+// first ExcerptWords words of its plain text, followed by an ellipsis if
+// anything was cut off.
+func (c *Config) Excerpt(p GhostPost) string {
+	if p.CustomExcerpt.Valid && strings.TrimSpace(p.CustomExcerpt.String) != "" {
+		return strings.TrimSpace(p.CustomExcerpt.String)
+	}
+
+	if !p.Plaintext.Valid {
+		return ""
+	}
+
+	n := c.ExcerptWords
+	if n <= 0 {
+		n = DefaultExcerptWords
+	}
+
+	words := strings.Fields(p.Plaintext.String)
+	if len(words) <= n {
+		return strings.Join(words, " ")
+	}
+
+	return strings.Join(words[:n], " ") + "…"
+}
+
+// tableOfContents returns the headings in HTML, in document order.
+func tableOfContents(h string) []Heading {
+	var toc []Heading
+
+	for _, m := range headingRegexp.FindAllStringSubmatch(h, -1) {
+		text := strings.Join(strings.Fields(plainify(m[3])), " ")
+
+		id := slugify(text)
+		if a := idAttrRegexp.FindStringSubmatch(m[2]); a != nil {
+			id = a[1]
+		}
+
+		toc = append(toc, Heading{Level: int(m[1][0] - '0'), ID: id, Text: text})
+	}
+
+	return toc
+}
+
+// firstImage returns the src of the first <img> in HTML, or "".
+func firstImage(h string) string {
+	m := imgSrcRegexp.FindStringSubmatch(h)
+	if m == nil {
+		return ""
+	}
+
+	return m[1]
+}
+
+// readingTime estimates the minutes needed to read a post whose HTML is h.
+// Like Ghost, the feature image counts as an image.
+func readingTime(p GhostPost, h string) int {
+	images := countImages(h)
+	if p.FeatureImage.Valid && p.FeatureImage.String != "" {
+		images++
+	}
+
+	return ReadingTime(countWords(h), images)
+}
@@ -0,0 +1,147 @@
+package ghosttohugo_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+func TestExcerpt(t *testing.T) {
+	t.Parallel()
+
+	c := ghosttohugo.Config{ExcerptWords: 3}
+
+	tests := []struct {
+		p    ghosttohugo.GhostPost
+		want string
+	}{
+		{ghosttohugo.GhostPost{}, ""},
+		{
+			ghosttohugo.GhostPost{
+				CustomExcerpt: sql.NullString{String: " Custom excerpt ", Valid: true},
+				Plaintext:     sql.NullString{String: "one two three four", Valid: true},
+			},
+			"Custom excerpt",
+		},
+		{ghosttohugo.GhostPost{Plaintext: sql.NullString{String: "one\ntwo  three", Valid: true}}, "one two three"},
+		{ghosttohugo.GhostPost{Plaintext: sql.NullString{String: "one two three four", Valid: true}}, "one two three…"},
+	}
+
+	for i, test := range tests {
+		got := c.Excerpt(test.p)
+		if got != test.want {
+			t.Logf("test %v failed: got %q, want %q", i, got, test.want)
+			t.Fail()
+		}
+	}
+}
+
+func TestDerivedFields(t *testing.T) {
+	t.Parallel()
+
+	c := ghosttohugo.Config{
+		GhostURL: "https://example.com",
+		Template: `{{ .WordCount }} {{ .ReadingTime }} {{ .FirstImage }}
+{{ range .TableOfContents }}{{ .Level }} {{ .ID }} {{ .Text }}
+{{ end }}`,
+	}
+	c.ApplyDefaults()
+	c.Process()
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	words := strings.Repeat("word ", 400)
+
+	got, err := c.RenderString(ghosttohugo.GhostPost{
+		HTML: sql.NullString{
+			String: `<h2 id="intro">Intro &amp; <em>setup</em></h2><p>` + words + `</p>` +
+				`<img src="__GHOST_URL__/a.png"><h3>No ID here</h3><img src="b.png"><p>end</p>`,
+			Valid: true,
+		},
+		FeatureImage: sql.NullString{String: "__GHOST_URL__/feature.png", Valid: true},
+	})
+	if err != nil {
+		t.Logf("failed to render: %v", err.Error())
+		t.FailNow()
+	}
+
+	// 406 words take 89 seconds, and three images (including the feature
+	// image) add another 33 seconds
+	want := `406 2 https://example.com/a.png
+2 intro Intro & setup
+3 no-id-here No ID here
+`
+	if got != want {
+		t.Logf("got %q, want %q", got, want)
+		t.Fail()
+	}
+
+	// cards that are converted to shortcodes still count
+	c.ImageBaseURL = "/images"
+	c.ImageShortcode = "figure"
+	c.Template = "{{ .WordCount }} {{ .FirstImage }}"
+
+	err = c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	got, err = c.RenderString(ghosttohugo.GhostPost{
+		HTML: sql.NullString{
+			String: `<figure class="kg-card kg-image-card"><img src="__GHOST_URL__/content/images/cat.png" alt="Cat">` +
+				`<figcaption>A cat</figcaption></figure><p>end</p>`,
+			Valid: true,
+		},
+	})
+	if err != nil {
+		t.Logf("failed to render: %v", err.Error())
+		t.FailNow()
+	}
+
+	want = "3 /images/cat.png"
+	if got != want {
+		t.Logf("got %q, want %q", got, want)
+		t.Fail()
+	}
+}
+
+func TestDefaultTemplateExcerpt(t *testing.T) {
+	t.Parallel()
+
+	c := ghosttohugo.Config{}
+	c.ApplyDefaults()
+	c.Process()
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	got, err := c.RenderString(ghosttohugo.GhostPost{
+		Slug:          "post",
+		HTML:          sql.NullString{String: "<p>Body</p>", Valid: true},
+		CustomExcerpt: sql.NullString{String: `A "quoted": excerpt`, Valid: true},
+	})
+	if err != nil {
+		t.Logf("failed to render: %v", err.Error())
+		t.FailNow()
+	}
+
+	for _, want := range []string{
+		"\nsummary: \"A \\\"quoted\\\": excerpt\"\n",
+		"\ndescription: \"A \\\"quoted\\\": excerpt\"\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Logf("expected output to contain %q, got:\n%v", want, got)
+			t.Fail()
+		}
+	}
+}
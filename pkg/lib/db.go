@@ -0,0 +1,235 @@
+package ghosttohugo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// DefaultDatabaseDriver is the database/sql driver name that is used if
+// [Config.DatabaseDriver] is not set.
+const DefaultDatabaseDriver = "mysql"
+
+// ConnectionString returns [Config.DatabaseConnectionString], falling back to
+// [Config.MySQLConnectionString] for older configurations.
+func (c *Config) ConnectionString() string {
+	if c.DatabaseConnectionString != "" {
+		return c.DatabaseConnectionString
+	}
+
+	return c.MySQLConnectionString
+}
+
+// OpenDB opens a database handle using the configured driver and connection
+// string. The driver must have been registered by importing it, since this
+// module does not depend on any specific driver.
+func (c *Config) OpenDB() (*sql.DB, error) {
+	db, err := sql.Open(c.DatabaseDriver, c.ConnectionString())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %v database: %w", c.DatabaseDriver, err)
+	}
+
+	return db, nil
+}
+
+// LoadPosts reads every post from the posts table, along with its feature
+// image alt text and caption from posts_meta. If routes or template files are
+// configured, each post's tags are loaded as well.
+func (c *Config) LoadPosts(ctx context.Context, db *sql.DB) ([]GhostPost, error) {
+	return c.queryPosts(ctx, db, "")
+}
+
+// queryPosts reads posts matching the optional where clause, along with their
+// tags if they're needed, see [Config.NeedsTags]. If ContinueOnError is set,
+// rows that fail to load are skipped and returned as [PostErrors] alongside
+// the other posts.
+func (c *Config) queryPosts(ctx context.Context, db *sql.DB, where string, args ...any) ([]GhostPost, error) {
+	var tags map[string][]GhostTag
+	if c.NeedsTags() {
+		var err error
+		tags, err = LoadPostTags(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	meta, err := LoadPostsMeta(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	q := fmt.Sprintf("SELECT %v FROM posts", QUERY_POSTS_FIELDS)
+	if where != "" {
+		q += " WHERE " + where
+	}
+
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query posts from db: %w", err)
+	}
+
+	defer rows.Close()
+
+	var posts []GhostPost
+	var errs PostErrors
+
+	for rows.Next() {
+		post, err := c.GetGhostPost(rows)
+		if err != nil {
+			var pe *PostError
+			if !c.ContinueOnError || !errors.As(err, &pe) {
+				return posts, fmt.Errorf("failed to get ghost post from row: %w", err)
+			}
+
+			c.logger().Warn("skipping post that failed to load", slog.String("post_id", pe.PostID), slog.String("slug", pe.Slug),
+				slog.String("stage", string(pe.Stage)), slog.Any("error", pe.Err))
+
+			c.Report.addFailure(pe)
+			errs = append(errs, pe)
+			if c.tooManyErrors(len(errs)) {
+				return posts, c.postErrors(errs, 0)
+			}
+
+			continue
+		}
+
+		post.Tags = tags[post.ID]
+		post.SetMeta(meta[post.ID])
+		posts = append(posts, post)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return posts, fmt.Errorf("failed to iterate over posts: %w", err)
+	}
+
+	c.logger().Debug("loaded posts", slog.Int("posts", len(posts)), slog.Int("failed", len(errs)))
+
+	return posts, c.postErrors(errs, 0)
+}
+
+// NeedsTags returns true if rendering depends on posts' tags: routes filter
+// on them, and template files can be selected by primary tag. [Config.LoadPosts]
+// loads tags if it returns true; callers that read posts themselves should
+// load them with [LoadPostTags].
+func (c *Config) NeedsTags() bool {
+	return c.routes != nil || c.templateFS() != nil
+}
+
+// LoadPostTags reads every post's tags, keyed on post ID.
+func LoadPostTags(ctx context.Context, db *sql.DB) (map[string][]GhostTag, error) {
+	rows, err := db.QueryContext(ctx, QUERY_POST_TAGS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags from db: %w", err)
+	}
+
+	defer rows.Close()
+
+	tags := make(map[string][]GhostTag)
+
+	for rows.Next() {
+		postID, tag, err := GetPostTag(rows)
+		if err != nil {
+			return tags, err
+		}
+
+		tags[postID] = append(tags[postID], tag)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return tags, fmt.Errorf("failed to iterate over tags: %w", err)
+	}
+
+	return tags, nil
+}
+
+// LoadPostsMeta reads every post's metadata from posts_meta, keyed on post ID.
+func LoadPostsMeta(ctx context.Context, db *sql.DB) (map[string]PostMeta, error) {
+	rows, err := db.QueryContext(ctx, QUERY_POSTS_META)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query posts_meta from db: %w", err)
+	}
+
+	defer rows.Close()
+
+	meta := make(map[string]PostMeta)
+
+	for rows.Next() {
+		postID, m, err := GetPostMeta(rows)
+		if err != nil {
+			return meta, err
+		}
+
+		meta[postID] = m
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return meta, fmt.Errorf("failed to iterate over posts_meta: %w", err)
+	}
+
+	return meta, nil
+}
+
+// LoadNewsletters reads the newsletters table and passes its contents to
+// [Config.SetNewsletters].
+func (c *Config) LoadNewsletters(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT %v FROM newsletters", QUERY_NEWSLETTERS_FIELDS))
+	if err != nil {
+		return fmt.Errorf("failed to query newsletters from db: %w", err)
+	}
+
+	defer rows.Close()
+
+	var newsletters []Newsletter
+
+	for rows.Next() {
+		n, err := GetNewsletter(rows)
+		if err != nil {
+			return err
+		}
+
+		newsletters = append(newsletters, n)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("failed to iterate over newsletters: %w", err)
+	}
+
+	c.SetNewsletters(newsletters)
+
+	return nil
+}
+
+// LoadSiteSettings reads the settings table and parses it into
+// [SiteSettings].
+func LoadSiteSettings(ctx context.Context, db *sql.DB) (SiteSettings, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT %v FROM settings", QUERY_SETTINGS_FIELDS))
+	if err != nil {
+		return SiteSettings{}, fmt.Errorf("failed to query settings from db: %w", err)
+	}
+
+	defer rows.Close()
+
+	var settings []Setting
+
+	for rows.Next() {
+		s, err := GetSetting(rows)
+		if err != nil {
+			return SiteSettings{}, err
+		}
+
+		settings = append(settings, s)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return SiteSettings{}, fmt.Errorf("failed to iterate over settings: %w", err)
+	}
+
+	return ParseSiteSettings(settings)
+}
@@ -0,0 +1,234 @@
+package ghosttohugo_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+// fakeQueryFunc returns the columns and rows for a query, or an error.
+type fakeQueryFunc func(query string, args []driver.Value) ([]string, [][]driver.Value, error)
+
+// fakeDriver is a minimal database/sql driver that answers queries using a
+// per-DSN function, so that the database helpers can be tested without a real
+// database.
+type fakeDriver struct {
+	mu       sync.Mutex
+	handlers map[string]fakeQueryFunc
+}
+
+var testDriver = &fakeDriver{handlers: make(map[string]fakeQueryFunc)}
+
+func init() {
+	sql.Register("ghosttohugo-fake", testDriver)
+}
+
+// newFakeDB returns a database handle that answers every query with f.
+func newFakeDB(t *testing.T, f fakeQueryFunc) *sql.DB {
+	t.Helper()
+
+	testDriver.mu.Lock()
+	testDriver.handlers[t.Name()] = f
+	testDriver.mu.Unlock()
+
+	db, err := sql.Open("ghosttohugo-fake", t.Name())
+	if err != nil {
+		t.Logf("failed to open fake db: %v", err.Error())
+		t.FailNow()
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	f, ok := d.handlers[name]
+	if !ok {
+		return nil, fmt.Errorf("no fake db registered for %v", name)
+	}
+
+	return &fakeConn{f: f}, nil
+}
+
+type fakeConn struct{ f fakeQueryFunc }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{f: c.f, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, fmt.Errorf("transactions unsupported") }
+
+type fakeStmt struct {
+	f     fakeQueryFunc
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	cols, rows, err := s.f(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fakeRows{cols: cols, rows: rows}, nil
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+	i    int
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.rows) {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[r.i])
+	r.i++
+
+	return nil
+}
+
+// postColumns has one entry per field in QUERY_POSTS_FIELDS.
+var postColumns = strings.Split("ID UUID Title Slug Mobiledoc Lexical HTML CommentID Plaintext FeatureImage Featured Type Status Locale Visibility EmailRecipientFilter CreatedAt CreatedBy UpdatedAt UpdatedBy PublishedAt PublishedBy CustomExcerpt CodeinjectionHead CodeinjectionFoot CustomTemplate CanonicalUrl NewsletterId ShowTitleAndFeatureImage", " ")
+
+var postsMetaColumns = []string{"PostID", "FeatureImageAlt", "FeatureImageCaption"}
+
+// fakePostRow returns a row for a published, public post.
+func fakePostRow(id, slug, html, updatedAt string) []driver.Value {
+	return []driver.Value{
+		id, "uuid-" + id, "Post " + id, slug, nil, nil, html, nil, nil, nil,
+		false, "post", "published", nil, "public", "all",
+		"2024-01-01 00:00:00", "1", updatedAt, nil, "2024-01-01 00:00:00", nil,
+		nil, nil, nil, nil, nil, nil, true,
+	}
+}
+
+func TestLoadPosts(t *testing.T) {
+	t.Parallel()
+
+	db := newFakeDB(t, func(q string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		switch {
+		case strings.Contains(q, "FROM posts_tags"):
+			return []string{"PostID", "ID", "Name", "Slug", "Visibility"}, [][]driver.Value{
+				{"2", "t1", "Podcast", "podcast", "public"},
+			}, nil
+		case strings.Contains(q, "FROM posts_meta"):
+			return postsMetaColumns, [][]driver.Value{{"2", "A cat", nil}}, nil
+		case strings.Contains(q, "FROM posts"):
+			return postColumns, [][]driver.Value{
+				fakePostRow("1", "first", "<p>1</p>", "2024-01-02 00:00:00"),
+				fakePostRow("2", "second", "<p>2</p>", "2024-01-03 00:00:00"),
+			}, nil
+		case strings.Contains(q, "FROM newsletters"):
+			return []string{"ID", "Name", "Slug"}, [][]driver.Value{{"n1", "Weekly", "weekly"}}, nil
+		case strings.Contains(q, "FROM settings"):
+			return []string{"SettingKey", "Value"}, [][]driver.Value{
+				{"title", "My Blog"},
+				{"navigation", `[{"label":"Home","url":"/"}]`},
+				{"icon", nil},
+			}, nil
+		}
+
+		return nil, nil, fmt.Errorf("unexpected query: %v", q)
+	})
+
+	c := ghosttohugo.Config{}
+	c.ApplyDefaults()
+	c.SetRoutes(ghosttohugo.Routes{})
+
+	ctx := context.Background()
+
+	posts, err := c.LoadPosts(ctx, db)
+	if err != nil {
+		t.Logf("failed to load posts: %v", err.Error())
+		t.FailNow()
+	}
+
+	if len(posts) != 2 || posts[1].Slug != "second" || posts[0].UpdatedAt.Day() != 2 {
+		t.Logf("unexpected posts: %+v", posts)
+		t.FailNow()
+	}
+
+	if len(posts[0].Tags) != 0 || len(posts[1].Tags) != 1 || posts[1].Tags[0].Slug != "podcast" {
+		t.Logf("unexpected tags: %v, %v", posts[0].Tags, posts[1].Tags)
+		t.Fail()
+	}
+
+	if posts[0].FeatureImageAlt.Valid || posts[1].FeatureImageAlt.String != "A cat" || posts[1].FeatureImageCaption.Valid {
+		t.Logf("unexpected posts_meta: %+v, %+v", posts[0], posts[1])
+		t.Fail()
+	}
+
+	// template files can be selected by primary tag, so they need tags too
+	withTemplates := ghosttohugo.Config{TemplateDir: "testdata/templates"}
+	withTemplates.ApplyDefaults()
+
+	posts, err = withTemplates.LoadPosts(ctx, db)
+	if err != nil || len(posts) != 2 || len(posts[1].Tags) != 1 {
+		t.Logf("expected tags to be loaded for template files: %+v, %v", posts, err)
+		t.Fail()
+	}
+
+	err = c.LoadNewsletters(ctx, db)
+	if err != nil {
+		t.Logf("failed to load newsletters: %v", err.Error())
+		t.Fail()
+	}
+
+	s, err := ghosttohugo.LoadSiteSettings(ctx, db)
+	if err != nil {
+		t.Logf("failed to load settings: %v", err.Error())
+		t.FailNow()
+	}
+
+	if s.Title != "My Blog" || len(s.Navigation) != 1 {
+		t.Logf("unexpected settings: %+v", s)
+		t.Fail()
+	}
+}
+
+func TestLoadPostsError(t *testing.T) {
+	t.Parallel()
+
+	db := newFakeDB(t, func(q string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		if strings.Contains(q, "FROM posts_meta") {
+			return postsMetaColumns, nil, nil
+		}
+
+		row := fakePostRow("1", "first", "<p>1</p>", "2024-01-02 00:00:00")
+		row[16] = "not a date"
+		return postColumns, [][]driver.Value{row}, nil
+	})
+
+	c := ghosttohugo.Config{}
+
+	_, err := c.LoadPosts(context.Background(), db)
+	if err == nil {
+		t.Log("expected an error for an invalid created_at date")
+		t.Fail()
+	}
+}
@@ -0,0 +1,233 @@
+package ghosttohugo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change in a
+// unified diff.
+const diffContext = 3
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// splitLines splits s into lines, keeping each line's trailing newline so
+// that a missing newline at the end of the file can be detected.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// maxDiffTrace limits the memory used by diffLines to this many trace
+// entries. Files that need too many edits to fit are diffed as a replacement
+// of every line instead.
+const maxDiffTrace = 1 << 22
+
+// diffLines computes the shortest edit script between a and b using Myers'
+// algorithm.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	offset := n + m
+	if offset == 0 {
+		return nil
+	}
+
+	v := make([]int, 2*offset+2)
+
+	// only the diagonals that can have been reached after d edits are kept,
+	// so the trace grows with the square of the number of edits rather than
+	// with the size of the files
+	var trace [][]int
+	size := 0
+
+	for d := 0; d <= offset; d++ {
+		size += 2*d + 1
+		if size > maxDiffTrace {
+			return replaceLines(a, b)
+		}
+
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrackDiff(a, b, trace)
+			}
+		}
+	}
+
+	return nil
+}
+
+// replaceLines is the edit script that removes every line of a and then adds
+// every line of b.
+func replaceLines(a, b []string) []diffOp {
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, l := range a {
+		ops = append(ops, diffOp{'-', l})
+	}
+
+	for _, l := range b {
+		ops = append(ops, diffOp{'+', l})
+	}
+
+	return ops
+}
+
+// backtrackDiff walks the trace recorded by diffLines backwards to produce
+// the edit script. trace[d][k+d] is how far along diagonal k the path had
+// reached before the d-th edit.
+func backtrackDiff(a, b []string, trace [][]int) []diffOp {
+	var ops []diffOp
+
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[k-1+d] < v[k+1+d]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := v[prevK+d]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{' ', a[x]})
+		}
+
+		if x == prevX {
+			y--
+			ops = append(ops, diffOp{'+', b[y]})
+		} else {
+			x--
+			ops = append(ops, diffOp{'-', a[x]})
+		}
+	}
+
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, diffOp{' ', a[x]})
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+
+	return ops
+}
+
+// writeDiffLine writes a single line of a unified diff, noting when the line
+// doesn't end with a newline.
+func writeDiffLine(b *strings.Builder, op diffOp) {
+	b.WriteByte(op.kind)
+	b.WriteString(op.line)
+	if !strings.HasSuffix(op.line, "\n") {
+		b.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// hunkRange formats the line range of a hunk as used in its header.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%v,0", start)
+	}
+
+	if count == 1 {
+		return fmt.Sprintf("%v", start+1)
+	}
+
+	return fmt.Sprintf("%v,%v", start+1, count)
+}
+
+// UnifiedDiff returns a unified diff between the old and new contents of a
+// file, or an empty string if they are identical.
+func UnifiedDiff(oldName, newName, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+
+	ops := diffLines(splitLines(oldText), splitLines(newText))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %v\n+++ %v\n", oldName, newName)
+
+	// positions of each op in the old and new files
+	oldLine := make([]int, len(ops)+1)
+	newLine := make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if op.kind != '+' {
+			oldLine[i+1]++
+		}
+		if op.kind != '-' {
+			newLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// extend the hunk until there are more than 2*diffContext unchanged
+		// lines in a row
+		start := max(i-diffContext, 0)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+				continue
+			}
+
+			if j-end >= 2*diffContext {
+				break
+			}
+		}
+		end = min(end+diffContext, len(ops))
+
+		fmt.Fprintf(&b, "@@ -%v +%v @@\n",
+			hunkRange(oldLine[start], oldLine[end]-oldLine[start]),
+			hunkRange(newLine[start], newLine[end]-newLine[start]))
+
+		for _, op := range ops[start:end] {
+			writeDiffLine(&b, op)
+		}
+
+		i = end
+	}
+
+	return b.String()
+}
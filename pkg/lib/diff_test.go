@@ -0,0 +1,83 @@
+package ghosttohugo_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		oldText string
+		newText string
+		want    string
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{
+			"",
+			"a\nb\n",
+			"--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			"a\nb\n",
+			"",
+			"--- old\n+++ new\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n",
+			"1\n2\n3\nfour\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n",
+			"--- old\n+++ new\n@@ -1,7 +1,7 @@\n 1\n 2\n 3\n-4\n+four\n 5\n 6\n 7\n@@ -13,3 +13,4 @@\n 13\n 14\n 15\n+16\n",
+		},
+		{
+			// nearby changes are merged into a single hunk
+			"1\n2\n3\n4\n5\n6\n7\n8\n",
+			"1\nX\n3\n4\n5\n6\nY\n8\n",
+			"--- old\n+++ new\n@@ -1,8 +1,8 @@\n 1\n-2\n+X\n 3\n 4\n 5\n 6\n-7\n+Y\n 8\n",
+		},
+		{
+			"a\nb",
+			"a\nc",
+			"--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+		},
+	}
+
+	for i, test := range tests {
+		got := ghosttohugo.UnifiedDiff("old", "new", test.oldText, test.newText)
+		if got != test.want {
+			t.Logf("test %v failed: got\n%v\nwant\n%v", i, got, test.want)
+			t.Fail()
+		}
+	}
+}
+
+func TestUnifiedDiffLarge(t *testing.T) {
+	t.Parallel()
+
+	var a, b strings.Builder
+	a.WriteString("same\n")
+	b.WriteString("same\n")
+	for i := 0; i < 3000; i++ {
+		fmt.Fprintf(&a, "a%v\n", i)
+		fmt.Fprintf(&b, "b%v\n", i)
+	}
+
+	// too many edits to diff line by line, so the whole file is replaced
+	got := ghosttohugo.UnifiedDiff("old", "new", a.String(), b.String())
+	want := "--- old\n+++ new\n@@ -1,3001 +1,3001 @@\n-same\n-a0\n"
+	if !strings.HasPrefix(got, want) || !strings.HasSuffix(got, "+b2999\n") {
+		t.Logf("expected the whole file to be replaced, got:\n%v", got[:min(len(got), 200)])
+		t.Fail()
+	}
+
+	// a small change to a large file is still diffed line by line
+	got = ghosttohugo.UnifiedDiff("old", "new", a.String(), strings.Replace(a.String(), "a1500\n", "c1500\n", 1))
+	want = "--- old\n+++ new\n@@ -1499,7 +1499,7 @@\n a1497\n a1498\n a1499\n-a1500\n+c1500\n a1501\n a1502\n a1503\n"
+	if got != want {
+		t.Logf("got\n%v\nwant\n%v", got, want)
+		t.Fail()
+	}
+}
@@ -0,0 +1,186 @@
+package ghosttohugo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ChangeKind describes how a file would change if posts were rendered.
+type ChangeKind string
+
+const (
+	ChangeNew     ChangeKind = "new"
+	ChangeChanged ChangeKind = "changed"
+	// A markdown file in OutputPath that no post would be rendered to. An
+	// export doesn't remove these, so they are reported but aren't changes.
+	ChangeStale     ChangeKind = "stale"
+	ChangeUnchanged ChangeKind = "unchanged"
+)
+
+// FileChange is a single file in a [Changeset].
+type FileChange struct {
+	Path string
+	Kind ChangeKind
+	// The current contents of the file on disk, if it exists.
+	Old string
+	// The rendered contents of the file, unless it's stale.
+	New string
+}
+
+// Changeset describes what rendering a set of posts would do to OutputPath.
+type Changeset struct {
+	Changes []FileChange
+}
+
+// Count returns the number of changes of the given kind.
+func (cs Changeset) Count(k ChangeKind) int {
+	var n int
+	for _, c := range cs.Changes {
+		if c.Kind == k {
+			n++
+		}
+	}
+
+	return n
+}
+
+// HasChanges returns true if any file would be created or changed. Stale
+// files don't count, since an export leaves them alone.
+func (cs Changeset) HasChanges() bool {
+	return cs.Count(ChangeNew)+cs.Count(ChangeChanged) > 0
+}
+
+// Summary returns a one-line summary of the changeset.
+func (cs Changeset) Summary() string {
+	return fmt.Sprintf("%v new, %v changed, %v unchanged, %v stale (not removed)",
+		cs.Count(ChangeNew), cs.Count(ChangeChanged), cs.Count(ChangeUnchanged), cs.Count(ChangeStale))
+}
+
+// WriteDiff writes a unified diff of every new and changed file to w, a line
+// for each stale file, and then the summary.
+func (cs Changeset) WriteDiff(w io.Writer) error {
+	for _, c := range cs.Changes {
+		var d string
+		switch c.Kind {
+		case ChangeNew:
+			d = UnifiedDiff("/dev/null", c.Path, "", c.New)
+		case ChangeChanged:
+			d = UnifiedDiff(c.Path, c.Path, c.Old, c.New)
+		case ChangeStale:
+			d = fmt.Sprintf("stale, not removed: %v\n", c.Path)
+		}
+
+		if d == "" {
+			continue
+		}
+
+		_, err := io.WriteString(w, d)
+		if err != nil {
+			return fmt.Errorf("failed to write diff: %w", err)
+		}
+	}
+
+	_, err := fmt.Fprintln(w, cs.Summary())
+	if err != nil {
+		return fmt.Errorf("failed to write diff summary: %w", err)
+	}
+
+	return nil
+}
+
+// Diff renders the posts into memory and compares them against the files that
+// currently exist in OutputPath, without writing anything. Markdown files in
+// OutputPath that wouldn't be rendered are reported as stale, except for
+// files whose names start with an underscore, such as Hugo's _index.md; an
+// export doesn't remove them, so they may need to be removed by hand.
+func (c *Config) Diff(posts []GhostPost) (Changeset, error) {
+	return c.DiffContext(context.Background(), posts)
+}
+
+// DiffContext is [Config.Diff] with cancellation. Posts are rendered by up to
+// Workers goroutines; the changes are in the same order as the posts,
+// followed by stale files sorted by path.
+func (c *Config) DiffContext(ctx context.Context, posts []GhostPost) (Changeset, error) {
+	var cs Changeset
+
+	changes := make([]FileChange, len(posts))
+
+	err := forEach(ctx, c.workers(), len(posts), func(i int) error {
+		p := posts[i]
+
+		s, err := c.RenderString(p)
+		if err != nil {
+			return fmt.Errorf("failed to render post %v: %w", p.UUID, err)
+		}
+
+		f := c.OutputFile(p)
+
+		b, err := os.ReadFile(f)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			changes[i] = FileChange{Path: f, Kind: ChangeNew, New: s}
+		case err != nil:
+			return fmt.Errorf("failed to read existing post %v: %w", f, err)
+		case string(b) != s:
+			changes[i] = FileChange{Path: f, Kind: ChangeChanged, Old: string(b), New: s}
+		default:
+			changes[i] = FileChange{Path: f, Kind: ChangeUnchanged, Old: s, New: s}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return cs, err
+	}
+
+	cs.Changes = changes
+
+	rendered := make(map[string]bool, len(posts))
+	for _, ch := range changes {
+		rendered[filepath.Clean(ch.Path)] = true
+	}
+
+	if c.OutputPath == "" {
+		return cs, nil
+	}
+
+	var stale []FileChange
+
+	err = filepath.WalkDir(c.OutputPath, func(f string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		if d.IsDir() || filepath.Ext(f) != ".md" || strings.HasPrefix(d.Name(), "_") || rendered[filepath.Clean(f)] {
+			return nil
+		}
+
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+
+		stale = append(stale, FileChange{Path: f, Kind: ChangeStale, Old: string(b)})
+
+		return nil
+	})
+	if err != nil {
+		return cs, fmt.Errorf("failed to scan output path %v: %w", c.OutputPath, err)
+	}
+
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Path < stale[j].Path })
+	cs.Changes = append(cs.Changes, stale...)
+
+	return cs, nil
+}
@@ -0,0 +1,122 @@
+package ghosttohugo_test
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	var out bytes.Buffer
+	c := ghosttohugo.Config{
+		OutputPath:      dir,
+		AuditReportPath: path.Join(dir, "audit.json"),
+		DryRun:          true,
+		DryRunOutput:    &out,
+	}
+	c.ApplyDefaults()
+	c.Process()
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	post := func(slug, html string) ghosttohugo.GhostPost {
+		return ghosttohugo.GhostPost{
+			Slug:       slug,
+			Type:       "post",
+			Status:     "published",
+			Visibility: "public",
+			HTML:       sql.NullString{String: html, Valid: true},
+		}
+	}
+
+	same := post("same", "<p>same</p>")
+	changed := post("changed", "<p>new</p>")
+	added := post("added", "<p>added</p>")
+
+	s, err := c.RenderString(same)
+	if err != nil {
+		t.Logf("failed to render: %v", err.Error())
+		t.FailNow()
+	}
+
+	existing := map[string]string{
+		"same.md":    s,
+		"changed.md": strings.ReplaceAll(s, "same", "changed"),
+		"stale.md":   "stale\n",
+		"_index.md":  "section\n",
+	}
+
+	for f, contents := range existing {
+		err = os.WriteFile(path.Join(dir, f), []byte(contents), 0o644)
+		if err != nil {
+			t.Logf("failed to write %v: %v", f, err.Error())
+			t.FailNow()
+		}
+	}
+
+	posts := []ghosttohugo.GhostPost{same, changed, added}
+
+	cs, err := c.Diff(posts)
+	if err != nil {
+		t.Logf("failed to diff: %v", err.Error())
+		t.FailNow()
+	}
+
+	if got, want := cs.Summary(), "1 new, 1 changed, 1 unchanged, 1 stale (not removed)"; got != want {
+		t.Logf("got summary %v, want %v", got, want)
+		t.Fail()
+	}
+
+	err = c.RenderAll(posts)
+	if err != nil {
+		t.Logf("failed to render all: %v", err.Error())
+		t.FailNow()
+	}
+
+	diff := out.String()
+	for _, want := range []string{
+		"--- /dev/null\n+++ " + path.Join(dir, "added.md") + "\n",
+		"-<p>changed</p>\n+<p>new</p>\n",
+		"stale, not removed: " + path.Join(dir, "stale.md") + "\n",
+		"1 new, 1 changed, 1 unchanged, 1 stale (not removed)\n",
+	} {
+		if !strings.Contains(diff, want) {
+			t.Logf("expected diff to contain %q, got:\n%v", want, diff)
+			t.Fail()
+		}
+	}
+
+	// nothing should have been written
+	for _, f := range []string{"added.md", "audit.json"} {
+		_, err := os.Stat(path.Join(dir, f))
+		if !os.IsNotExist(err) {
+			t.Logf("expected %v not to exist in dry run mode: %v", f, err)
+			t.Fail()
+		}
+	}
+
+	b, err := os.ReadFile(path.Join(dir, "changed.md"))
+	if err != nil || string(b) != existing["changed.md"] {
+		t.Logf("changed.md should not have been modified: %v", err)
+		t.Fail()
+	}
+
+	n, f, err := c.RenderOne(added)
+	if err != nil || n == 0 || f != path.Join(dir, "added.md") {
+		t.Logf("unexpected dry run RenderOne result: %v, %v, %v", n, f, err)
+		t.Fail()
+	}
+}
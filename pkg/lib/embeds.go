@@ -0,0 +1,181 @@
+package ghosttohugo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Embed providers, the keys of [Config.Embeds].
+const (
+	EmbedProviderYouTube = "youtube"
+	EmbedProviderVimeo   = "vimeo"
+	EmbedProviderX       = "x"
+	EmbedProviderSpotify = "spotify"
+)
+
+// Values for [Config.Embeds].
+const (
+	// The embed card is left as it is. This is the default.
+	EmbedKeep = "keep"
+	// The embed card is replaced with a Hugo shortcode, outside of the raw
+	// HTML shortcode: {{< youtube ID >}}, {{< vimeo ID >}} or
+	// {{< x user="USER" id="ID" >}}, which are built into Hugo, or
+	// {{< spotify type="TYPE" id="ID" >}}, which isn't, so your site needs
+	// to provide layouts/shortcodes/spotify.html.
+	EmbedShortcode = "shortcode"
+	// The embed card is replaced with a static link to the embedded content,
+	// with a thumbnail where the provider has one that doesn't need an API
+	// call (currently only YouTube).
+	EmbedLink = "link"
+)
+
+// embedCardClass is the class of the figure that Ghost wraps embeds in.
+const embedCardClass = "kg-embed-card"
+
+var (
+	youtubeRegexp = regexp.MustCompile(`(?:youtube(?:-nocookie)?\.com/(?:embed/|watch\?v=|shorts/)|youtu\.be/)([\w-]{11})`)
+	vimeoRegexp   = regexp.MustCompile(`vimeo\.com/(?:video/)?(\d+)`)
+	xRegexp       = regexp.MustCompile(`(?:twitter|x)\.com/(\w+)/status(?:es)?/(\d+)`)
+	spotifyRegexp = regexp.MustCompile(`open\.spotify\.com/(?:embed/)?(track|album|playlist|episode|show|artist)/(\w+)`)
+
+	figcaptionRegexp  = regexp.MustCompile(`(?is)<figcaption[^>]*>(.*?)</figcaption>`)
+	iframeTitleRegexp = regexp.MustCompile(`(?is)<iframe\b[^>]*\btitle\s*=\s*"([^"]*)"`)
+)
+
+// embed is an embed card's content, recognized by [parseEmbed].
+type embed struct {
+	Provider string
+	// The provider's IDs for the content, in the order that its shortcode
+	// takes them.
+	IDs []string
+	// The title from the embed's iframe, as HTML, if any.
+	Title string
+	// The card's caption, as HTML, if any.
+	Caption string
+}
+
+// parseEmbed recognizes the provider of an embed card from its inner HTML,
+// returning false if it isn't one that's supported.
+func parseEmbed(inner string) (embed, bool) {
+	var e embed
+
+	switch {
+	case youtubeRegexp.MatchString(inner):
+		e.Provider = EmbedProviderYouTube
+		e.IDs = youtubeRegexp.FindStringSubmatch(inner)[1:]
+	case vimeoRegexp.MatchString(inner):
+		e.Provider = EmbedProviderVimeo
+		e.IDs = vimeoRegexp.FindStringSubmatch(inner)[1:]
+	case xRegexp.MatchString(inner):
+		e.Provider = EmbedProviderX
+		e.IDs = xRegexp.FindStringSubmatch(inner)[1:]
+	case spotifyRegexp.MatchString(inner):
+		e.Provider = EmbedProviderSpotify
+		e.IDs = spotifyRegexp.FindStringSubmatch(inner)[1:]
+	default:
+		return e, false
+	}
+
+	if m := iframeTitleRegexp.FindStringSubmatch(inner); m != nil {
+		e.Title = m[1]
+	}
+
+	if m := figcaptionRegexp.FindStringSubmatch(inner); m != nil {
+		e.Caption = strings.TrimSpace(m[1])
+	}
+
+	return e, true
+}
+
+// Shortcode returns the Hugo shortcode for the embed.
+func (e embed) Shortcode() string {
+	switch e.Provider {
+	case EmbedProviderX:
+		return fmt.Sprintf(`{{< x user="%v" id="%v" >}}`, e.IDs[0], e.IDs[1])
+	case EmbedProviderSpotify:
+		return fmt.Sprintf(`{{< spotify type="%v" id="%v" >}}`, e.IDs[0], e.IDs[1])
+	}
+
+	return fmt.Sprintf("{{< %v %v >}}", e.Provider, e.IDs[0])
+}
+
+// URL returns the address of the embedded content on the provider's site.
+func (e embed) URL() string {
+	switch e.Provider {
+	case EmbedProviderYouTube:
+		return "https://www.youtube.com/watch?v=" + e.IDs[0]
+	case EmbedProviderVimeo:
+		return "https://vimeo.com/" + e.IDs[0]
+	case EmbedProviderX:
+		return fmt.Sprintf("https://x.com/%v/status/%v", e.IDs[0], e.IDs[1])
+	}
+
+	return fmt.Sprintf("https://open.spotify.com/%v/%v", e.IDs[0], e.IDs[1])
+}
+
+// Link returns static HTML that links to the embedded content, for
+// [EmbedLink].
+func (e embed) Link() string {
+	label := map[string]string{
+		EmbedProviderYouTube: "Watch on YouTube",
+		EmbedProviderVimeo:   "Watch on Vimeo",
+		EmbedProviderX:       "View on X",
+		EmbedProviderSpotify: "Listen on Spotify",
+	}[e.Provider]
+
+	text := label
+	if e.Title != "" {
+		text = e.Title + " (" + label + ")"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<figure class="kg-card %v kg-embed-link">`, embedCardClass)
+
+	if e.Provider == EmbedProviderYouTube {
+		fmt.Fprintf(&b, `<img src="https://i.ytimg.com/vi/%v/hqdefault.jpg" alt="%v">`, e.IDs[0], html.EscapeString(html.UnescapeString(text)))
+	}
+
+	fmt.Fprintf(&b, `<figcaption><a href="%v">%v</a>`, e.URL(), html.EscapeString(html.UnescapeString(text)))
+
+	if e.Caption != "" {
+		b.WriteString(" " + e.Caption)
+	}
+
+	b.WriteString("</figcaption></figure>")
+
+	return b.String()
+}
+
+// embedMode returns how embeds from the provider are converted.
+func (c *Config) embedMode(provider string) string {
+	if m := c.Embeds[provider]; m != "" {
+		return m
+	}
+
+	return EmbedKeep
+}
+
+// convertEmbed is the [cardConverter] for embed cards.
+func (c *Config) convertEmbed(_ xml.StartElement, inner string, _ func(string, error)) (string, string, bool) {
+	e, ok := parseEmbed(inner)
+	if !ok {
+		return "", "", false
+	}
+
+	switch c.embedMode(e.Provider) {
+	case EmbedShortcode:
+		h := ""
+		if e.Caption != "" {
+			h = `<p class="kg-embed-caption">` + e.Caption + "</p>"
+		}
+
+		return h, e.Shortcode(), true
+	case EmbedLink:
+		return e.Link(), "", true
+	}
+
+	return "", "", false
+}
@@ -0,0 +1,141 @@
+package ghosttohugo_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+const (
+	youtubeCard = `<figure class="kg-card kg-embed-card kg-card-hascaption"><iframe width="200" height="113" src="https://www.youtube.com/embed/dQw4w9WgXcQ?feature=oembed" frameborder="0" allowfullscreen title="Never &amp; Gonna"></iframe><figcaption>A <b>classic</b></figcaption></figure>`
+	vimeoCard   = `<figure class="kg-card kg-embed-card"><iframe src="https://player.vimeo.com/video/76979871?app_id=122963" width="426" height="240"></iframe></figure>`
+	xCard       = `<figure class="kg-card kg-embed-card"><blockquote class="twitter-tweet"><p lang="en">Hello</p>&mdash; Someone (@someone) <a href="https://twitter.com/someone/status/1453110110599868418?ref_src=twsrc%5Etfw">October 26, 2021</a></blockquote><script async src="https://platform.twitter.com/widgets.js" charset="utf-8"></script></figure>`
+	spotifyCard = `<figure class="kg-card kg-embed-card"><iframe src="https://open.spotify.com/embed/track/4uLU6hMCjMI75M1A2tKUQC?utm_source=oembed"></iframe></figure>`
+	otherCard   = `<figure class="kg-card kg-embed-card"><iframe src="https://codepen.io/x/embed/y"></iframe></figure>`
+)
+
+func TestEmbeds(t *testing.T) {
+	t.Parallel()
+
+	html := "<p>start</p>" + youtubeCard + vimeoCard + "<div>" + xCard + "</div>" + spotifyCard + otherCard + "<p>end</p>"
+
+	tests := []struct {
+		embeds  map[string]string
+		want    []string
+		notWant []string
+	}{
+		{
+			nil,
+			[]string{"youtube.com/embed/dQw4w9WgXcQ", "player.vimeo.com", "twitter-tweet", "open.spotify.com/embed", "codepen.io"},
+			[]string{"{{< youtube"},
+		},
+		{
+			map[string]string{
+				ghosttohugo.EmbedProviderYouTube: ghosttohugo.EmbedShortcode,
+				ghosttohugo.EmbedProviderVimeo:   ghosttohugo.EmbedShortcode,
+				ghosttohugo.EmbedProviderX:       ghosttohugo.EmbedShortcode,
+				ghosttohugo.EmbedProviderSpotify: ghosttohugo.EmbedShortcode,
+			},
+			[]string{
+				"<p>start</p>{{</ rawhtml >}}\n{{< youtube dQw4w9WgXcQ >}}\n{{< rawhtml >}}<p class=\"kg-embed-caption\">A <b>classic</b></p>",
+				"{{</ rawhtml >}}\n{{< vimeo 76979871 >}}\n{{< rawhtml >}}",
+				"<div>{{</ rawhtml >}}\n{{< x user=\"someone\" id=\"1453110110599868418\" >}}\n{{< rawhtml >}}</div>",
+				"{{</ rawhtml >}}\n{{< spotify type=\"track\" id=\"4uLU6hMCjMI75M1A2tKUQC\" >}}\n{{< rawhtml >}}",
+				"codepen.io",
+			},
+			[]string{"youtube.com/embed", "player.vimeo.com", "twitter-tweet", "spotify.com/embed", "ghost-to-hugo:shortcode"},
+		},
+		{
+			map[string]string{
+				ghosttohugo.EmbedProviderYouTube: ghosttohugo.EmbedLink,
+				ghosttohugo.EmbedProviderX:       ghosttohugo.EmbedLink,
+				ghosttohugo.EmbedProviderVimeo:   ghosttohugo.EmbedKeep,
+			},
+			[]string{
+				`<img src="https://i.ytimg.com/vi/dQw4w9WgXcQ/hqdefault.jpg" alt="Never &amp; Gonna (Watch on YouTube)"`,
+				`<figcaption><a href="https://www.youtube.com/watch?v=dQw4w9WgXcQ">Never &amp; Gonna (Watch on YouTube)</a> A <b>classic</b></figcaption>`,
+				`<a href="https://x.com/someone/status/1453110110599868418">View on X</a>`,
+				"player.vimeo.com",
+				"open.spotify.com/embed",
+			},
+			[]string{"youtube.com/embed", "twitter-tweet", "{{< youtube", "{{< x"},
+		},
+	}
+
+	for i, test := range tests {
+		c := ghosttohugo.Config{Embeds: test.embeds}
+		c.ApplyDefaults()
+		c.Process()
+
+		err := c.Validate()
+		if err != nil {
+			t.Logf("test %v failed to validate: %v", i, err.Error())
+			t.FailNow()
+		}
+
+		err = c.ParseTemplate()
+		if err != nil {
+			t.Logf("test %v failed to parse template: %v", i, err.Error())
+			t.FailNow()
+		}
+
+		got, err := c.RenderString(ghosttohugo.GhostPost{Slug: "post", HTML: sql.NullString{String: html, Valid: true}})
+		if err != nil {
+			t.Logf("test %v failed to render: %v", i, err.Error())
+			t.Fail()
+			continue
+		}
+
+		for _, want := range test.want {
+			if !strings.Contains(got, want) {
+				t.Logf("test %v failed: expected %q in:\n%v", i, want, got)
+				t.Fail()
+			}
+		}
+
+		for _, notWant := range test.notWant {
+			if strings.Contains(got, notWant) {
+				t.Logf("test %v failed: didn't expect %q in:\n%v", i, notWant, got)
+				t.Fail()
+			}
+		}
+	}
+
+	for _, embeds := range []map[string]string{{"soundcloud": "link"}, {"youtube": "iframe"}} {
+		c := ghosttohugo.Config{Embeds: embeds}
+		if c.Validate() == nil {
+			t.Logf("expected an error for embeds %v", embeds)
+			t.Fail()
+		}
+	}
+}
+
+func TestEmbedsBeforeSanitizer(t *testing.T) {
+	t.Parallel()
+
+	c := ghosttohugo.Config{
+		Sanitizer: ghosttohugo.SanitizerNoJS,
+		Embeds:    map[string]string{ghosttohugo.EmbedProviderYouTube: ghosttohugo.EmbedShortcode},
+	}
+	c.ApplyDefaults()
+	c.Process()
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	got, err := c.RenderString(ghosttohugo.GhostPost{Slug: "post", HTML: sql.NullString{String: youtubeCard + vimeoCard, Valid: true}})
+	if err != nil {
+		t.Logf("failed to render: %v", err.Error())
+		t.FailNow()
+	}
+
+	if !strings.Contains(got, "{{< youtube dQw4w9WgXcQ >}}") || strings.Contains(got, "vimeo") {
+		t.Logf("expected the youtube shortcode and the vimeo iframe to be removed in:\n%v", got)
+		t.Fail()
+	}
+}
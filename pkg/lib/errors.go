@@ -0,0 +1,160 @@
+package ghosttohugo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Stage is the step of exporting a post at which it failed.
+type Stage string
+
+const (
+	// Reading the post's row from the database.
+	StageScan Stage = "scan"
+	// Processing the post's fields or HTML.
+	StageProcess Stage = "process"
+	// Executing the template.
+	StageTemplate Stage = "template"
+	// Writing the rendered post to disk.
+	StageWrite Stage = "write"
+)
+
+var (
+	// ErrTooManyErrors is returned when more than [Config.MaxErrors] posts
+	// fail while [Config.ContinueOnError] is set.
+	ErrTooManyErrors = errors.New("too many posts failed")
+
+	// ErrEmptyPost is returned when a post's HTML is null and
+	// [Config.ForbidEmptyPosts] is set.
+	ErrEmptyPost = errors.New("html is null, cannot render")
+)
+
+// DateParseError is returned when one of a post's datetime columns can't be
+// parsed.
+type DateParseError struct {
+	PostID string
+	// The [GhostPost] field being parsed, such as "CreatedAt".
+	Field string
+	// The value from the database.
+	Value string
+	Err   error
+}
+
+func (e *DateParseError) Error() string {
+	return fmt.Sprintf("failed to parse %v datetime %q of post %v: %v", e.Field, e.Value, e.PostID, e.Err)
+}
+
+func (e *DateParseError) Unwrap() error {
+	return e.Err
+}
+
+// TemplateError is returned when the template fails to execute for a post,
+// usually because it refers to a field that doesn't exist.
+type TemplateError struct {
+	PostID string
+	// The name of the template that failed.
+	Template string
+	Err      error
+}
+
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("failed to execute template %v for post %v: %v", e.Template, e.PostID, e.Err)
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// WriteError is returned when a rendered post can't be written to disk.
+type WriteError struct {
+	PostID string
+	// The file or directory that couldn't be written.
+	Path string
+	Err  error
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("failed to write post %v to %v: %v", e.PostID, e.Path, e.Err)
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// PostError is a failure to export a single post.
+type PostError struct {
+	PostID string
+	Slug   string
+	Stage  Stage
+	Err    error
+}
+
+func (e *PostError) Error() string {
+	return fmt.Sprintf("post %v (%v) failed at %v stage: %v", e.PostID, e.Slug, e.Stage, e.Err)
+}
+
+func (e *PostError) Unwrap() error {
+	return e.Err
+}
+
+// PostErrors is every post that failed while [Config.ContinueOnError] was
+// set, in the order that the posts were given. Use [errors.As] to get at it
+// or at an individual [*PostError].
+type PostErrors []*PostError
+
+func (e PostErrors) Error() string {
+	switch len(e) {
+	case 0:
+		return "no posts failed"
+	case 1:
+		return e[0].Error()
+	}
+
+	return fmt.Sprintf("%v posts failed, first: %v", len(e), e[0].Error())
+}
+
+func (e PostErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+
+	return errs
+}
+
+// skippable returns the failures in err if the export can carry on without
+// them: err is nil, or it's [PostErrors] that didn't exceed MaxErrors.
+func skippable(err error) (PostErrors, bool) {
+	if err == nil {
+		return nil, true
+	}
+
+	var errs PostErrors
+	if !errors.As(err, &errs) || errors.Is(err, ErrTooManyErrors) {
+		return nil, false
+	}
+
+	return errs, true
+}
+
+// tooManyErrors returns true if n failures exceeds MaxErrors.
+func (c *Config) tooManyErrors(n int) bool {
+	return c.MaxErrors > 0 && n > c.MaxErrors
+}
+
+// postErrors returns the collected failures as an error, or nil if there were
+// none. prior is the number of failures from an earlier step of the same
+// export, such as loading the posts, which count towards MaxErrors but
+// aren't included in the error. If there were too many, the error also wraps
+// [ErrTooManyErrors].
+func (c *Config) postErrors(errs PostErrors, prior int) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	if c.tooManyErrors(prior + len(errs)) {
+		return fmt.Errorf("%w: %w", ErrTooManyErrors, errs)
+	}
+
+	return errs
+}
@@ -0,0 +1,293 @@
+package ghosttohugo_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+func TestContinueOnError(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	c := ghosttohugo.Config{
+		OutputPath:       dir,
+		ForbidEmptyPosts: true,
+		ContinueOnError:  true,
+		Workers:          2,
+	}
+	c.ApplyDefaults()
+	c.Process()
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	// a file where a section directory should be
+	err = os.WriteFile(path.Join(dir, "blocked"), nil, 0o644)
+	if err != nil {
+		t.Logf("failed to write file: %v", err.Error())
+		t.FailNow()
+	}
+
+	var posts []ghosttohugo.GhostPost
+	for i := 0; i < 5; i++ {
+		posts = append(posts, ghosttohugo.GhostPost{
+			ID:         fmt.Sprint(i),
+			Slug:       fmt.Sprintf("post-%v", i),
+			Type:       "post",
+			Status:     "published",
+			Visibility: "public",
+			HTML:       sql.NullString{String: "<p>ok</p>", Valid: true},
+		})
+	}
+
+	posts[1].HTML.Valid = false
+	posts[3].Slug = "blocked/post-3"
+
+	files, err := c.RenderPosts(context.Background(), posts)
+
+	var errs ghosttohugo.PostErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Logf("expected 2 post errors, got %v", err)
+		t.FailNow()
+	}
+
+	if errs[0].PostID != "1" || errs[0].Slug != "post-1" || errs[0].Stage != ghosttohugo.StageProcess {
+		t.Logf("unexpected first error: %+v", errs[0])
+		t.Fail()
+	}
+
+	if errs[1].PostID != "3" || errs[1].Stage != ghosttohugo.StageWrite {
+		t.Logf("unexpected second error: %+v", errs[1])
+		t.Fail()
+	}
+
+	if len(files) != 3 || files[0].Post.ID != "0" || files[1].Post.ID != "2" || files[2].Post.ID != "4" {
+		t.Logf("unexpected files: %+v", files)
+		t.Fail()
+	}
+
+	var pe *ghosttohugo.PostError
+	if !errors.As(err, &pe) || pe.PostID != "1" {
+		t.Logf("expected errors.As to find the first PostError, got %v", pe)
+		t.Fail()
+	}
+
+	// RenderAll still finishes the export before returning the failures
+	err = c.RenderAll(posts)
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Logf("expected 2 post errors from RenderAll, got %v", err)
+		t.Fail()
+	}
+
+	c.MaxErrors = 1
+
+	_, err = c.RenderPosts(context.Background(), posts)
+	if !errors.Is(err, ghosttohugo.ErrTooManyErrors) {
+		t.Logf("expected ErrTooManyErrors, got %v", err)
+		t.Fail()
+	}
+
+	// failures to load count towards the same limit as failures to render
+	c.MaxErrors = 2
+	loadErr := ghosttohugo.PostErrors{{PostID: "9", Slug: "post-9", Stage: ghosttohugo.StageScan, Err: errors.New("bad row")}}
+
+	err = c.RenderLoaded(context.Background(), posts, nil)
+	if !errors.As(err, &errs) || len(errs) != 2 || errors.Is(err, ghosttohugo.ErrTooManyErrors) {
+		t.Logf("expected 2 post errors from RenderLoaded, got %v", err)
+		t.Fail()
+	}
+
+	err = c.RenderLoaded(context.Background(), posts, loadErr)
+	if !errors.Is(err, ghosttohugo.ErrTooManyErrors) {
+		t.Logf("expected ErrTooManyErrors with a load failure, got %v", err)
+		t.Fail()
+	}
+
+	err = c.RenderLoaded(context.Background(), posts, errors.New("db is down"))
+	if err == nil || errors.As(err, &errs) {
+		t.Logf("expected the load error to be returned, got %v", err)
+		t.Fail()
+	}
+
+	// without ContinueOnError, the first failure stops the export
+	c.ContinueOnError = false
+
+	_, err = c.RenderPosts(context.Background(), posts)
+	if !errors.As(err, &pe) || pe.PostID != "1" || errors.As(err, &errs) {
+		t.Logf("expected a single PostError for post 1, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestContinueOnErrorStages(t *testing.T) {
+	t.Parallel()
+
+	c := ghosttohugo.Config{
+		ContinueOnError: true,
+		Template:        "{{ .Post.Missing }}",
+	}
+	c.ApplyDefaults()
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	c.DryRun = true
+
+	_, err = c.RenderPosts(context.Background(), []ghosttohugo.GhostPost{{ID: "1", Slug: "one"}})
+
+	var pe *ghosttohugo.PostError
+	if !errors.As(err, &pe) || pe.Stage != ghosttohugo.StageTemplate {
+		t.Logf("expected a template stage error, got %v", err)
+		t.Fail()
+	}
+
+	db := newFakeDB(t, func(q string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		if strings.Contains(q, "FROM posts_meta") {
+			return postsMetaColumns, nil, nil
+		}
+
+		badDate := fakePostRow("2", "bad-date", "<p>2</p>", "2024-01-02 00:00:00")
+		badDate[16] = "not a date"
+
+		badRow := fakePostRow("3", "bad-row", "<p>3</p>", "2024-01-02 00:00:00")
+		badRow[10] = "not a bool"
+
+		return postColumns, [][]driver.Value{
+			fakePostRow("1", "ok", "<p>1</p>", "2024-01-02 00:00:00"),
+			badDate,
+			badRow,
+		}, nil
+	})
+
+	posts, err := c.LoadPosts(context.Background(), db)
+
+	var errs ghosttohugo.PostErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Logf("expected 2 post errors, got %v", err)
+		t.FailNow()
+	}
+
+	if len(posts) != 1 || posts[0].Slug != "ok" {
+		t.Logf("unexpected posts: %+v", posts)
+		t.Fail()
+	}
+
+	if errs[0].Slug != "bad-date" || errs[0].Stage != ghosttohugo.StageProcess {
+		t.Logf("unexpected first error: %+v", errs[0])
+		t.Fail()
+	}
+
+	if errs[1].PostID != "3" || errs[1].Stage != ghosttohugo.StageScan {
+		t.Logf("unexpected second error: %+v", errs[1])
+		t.Fail()
+	}
+
+	if !strings.Contains(errs.Error(), "2 posts failed") {
+		t.Logf("unexpected message: %v", errs.Error())
+		t.Fail()
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	c := ghosttohugo.Config{OutputPath: dir, ForbidEmptyPosts: true}
+	c.ApplyDefaults()
+	c.Process()
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	_, err = c.ProcessGhostPost(ghosttohugo.GhostPost{
+		ID:             "1",
+		SqlCreatedAt:   "2024-01-01 00:00:00",
+		SqlPublishedAt: sql.NullString{String: "yesterday", Valid: true},
+	})
+
+	var de *ghosttohugo.DateParseError
+	if !errors.As(err, &de) || de.PostID != "1" || de.Field != "PublishedAt" || de.Value != "yesterday" {
+		t.Logf("expected a DateParseError for PublishedAt, got %v", err)
+		t.Fail()
+	}
+
+	_, _, err = c.RenderOne(ghosttohugo.GhostPost{ID: "2", Slug: "empty"})
+	if !errors.Is(err, ghosttohugo.ErrEmptyPost) {
+		t.Logf("expected ErrEmptyPost, got %v", err)
+		t.Fail()
+	}
+
+	err = os.WriteFile(path.Join(dir, "blocked"), nil, 0o644)
+	if err != nil {
+		t.Logf("failed to write file: %v", err.Error())
+		t.FailNow()
+	}
+
+	post := ghosttohugo.GhostPost{ID: "3", Slug: "blocked/post", HTML: sql.NullString{String: "<p>3</p>", Valid: true}}
+
+	_, _, err = c.RenderOne(post)
+
+	var we *ghosttohugo.WriteError
+	if !errors.As(err, &we) || we.PostID != "3" || we.Path != path.Join(dir, "blocked") {
+		t.Logf("expected a WriteError, got %v", err)
+		t.Fail()
+	}
+
+	c.Template = "{{ .Post.Missing }}"
+
+	err = c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	_, err = c.RenderString(post)
+
+	var te *ghosttohugo.TemplateError
+	if !errors.As(err, &te) || te.PostID != "3" {
+		t.Logf("expected a TemplateError, got %v", err)
+		t.Fail()
+	}
+
+	_, err = ghosttohugo.LoadConfig(path.Join(dir, "missing.json"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Logf("expected fs.ErrNotExist, got %v", err)
+		t.Fail()
+	}
+
+	err = os.WriteFile(path.Join(dir, "bad.json"), []byte("{"), 0o644)
+	if err != nil {
+		t.Logf("failed to write file: %v", err.Error())
+		t.FailNow()
+	}
+
+	_, err = ghosttohugo.LoadConfig(path.Join(dir, "bad.json"))
+
+	var se *json.SyntaxError
+	if !errors.As(err, &se) {
+		t.Logf("expected a json.SyntaxError, got %v", err)
+		t.Fail()
+	}
+}
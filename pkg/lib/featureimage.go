@@ -0,0 +1,157 @@
+package ghosttohugo
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// QUERY_POSTS_META selects the feature image alt text and caption that Ghost
+// stores in the posts_meta table. The rows should be parsed with
+// [GetPostMeta].
+const QUERY_POSTS_META = `
+SELECT
+post_id as PostID,
+feature_image_alt as FeatureImageAlt,
+feature_image_caption as FeatureImageCaption
+FROM posts_meta
+`
+
+// PostMeta is a row from Ghost's posts_meta table, limited to the fields that
+// are used when rendering.
+type PostMeta struct {
+	FeatureImageAlt     sql.NullString
+	FeatureImageCaption sql.NullString
+}
+
+// GetPostMeta parses an SQL row-yielding iterator from [QUERY_POSTS_META] and
+// returns the post ID and its metadata.
+func GetPostMeta(rows *sql.Rows) (string, PostMeta, error) {
+	var postID string
+	var m PostMeta
+
+	err := rows.Scan(&postID, &m.FeatureImageAlt, &m.FeatureImageCaption)
+	if err != nil {
+		return postID, m, fmt.Errorf("failed to marshal row into post meta: %w", err)
+	}
+
+	return postID, m, nil
+}
+
+// SetMeta copies m's fields onto the post.
+func (p *GhostPost) SetMeta(m PostMeta) {
+	p.FeatureImageAlt = m.FeatureImageAlt
+	p.FeatureImageCaption = m.FeatureImageCaption
+}
+
+// Default front matter keys for feature images, see [FrontMatterConfig].
+// "images" is what Hugo's built-in templates use for Open Graph and Twitter
+// cards.
+const (
+	DefaultFrontMatterFeatureImage        = "images"
+	DefaultFrontMatterFeatureImageAlt     = "featureImageAlt"
+	DefaultFrontMatterFeatureImageCaption = "featureImageCaption"
+)
+
+// FrontMatterFeatureImageNone disables feature images in the default
+// template's front matter when used as [FrontMatterConfig.FeatureImage].
+const FrontMatterFeatureImageNone = "-"
+
+// FeatureImage is a post's feature image, ready to be rendered.
+type FeatureImage struct {
+	// The image URL, rewritten to ImageBaseURL if it was uploaded to Ghost,
+	// and with __GHOST_URL__ and link replacements applied.
+	URL     string
+	Alt     string
+	Caption string
+	// True if the post's "show title and feature image" setting is off in
+	// Ghost.
+	Hidden bool
+}
+
+// FeatureImage returns the post's feature image, or nil if it doesn't have
+// one.
+func (c *Config) FeatureImage(p GhostPost) *FeatureImage {
+	if !p.FeatureImage.Valid || p.FeatureImage.String == "" {
+		return nil
+	}
+
+	return &FeatureImage{
+		URL:     c.rewriteURL(c.localImageURL(p.FeatureImage.String)),
+		Alt:     p.FeatureImageAlt.String,
+		Caption: p.FeatureImageCaption.String,
+		Hidden:  !p.ShowTitleAndFeatureImage,
+	}
+}
+
+// yamlString quotes s as a YAML double-quoted scalar.
+func yamlString(s string) string {
+	q, err := jsonString(s)
+	if err != nil {
+		// a string always marshals
+		panic(err)
+	}
+
+	return q
+}
+
+// featureImageFrontMatter renders the feature image as YAML front matter,
+// according to [FrontMatterConfig.FeatureImage]:
+//
+//   - "images" (the default) writes a list with a single image, and is
+//     written even if the image is hidden, since Hugo only uses it for
+//     metadata such as Open Graph tags.
+//   - A nested key such as "cover.image" writes the URL to image under cover,
+//     with alt, caption and hidden alongside it, as used by themes such as
+//     PaperMod.
+//   - Any other key, such as "featured_image", writes the URL as a string,
+//     and is omitted if the image is hidden, since themes display it
+//     unconditionally.
+//
+// Outside of nested keys, alt text and captions are written to
+// FeatureImageAlt and FeatureImageCaption.
+func (c *Config) featureImageFrontMatter(fi *FeatureImage) string {
+	key := c.FrontMatter.FeatureImage
+	if fi == nil || key == "" || key == FrontMatterFeatureImageNone {
+		return ""
+	}
+
+	var lines []string
+
+	parent, child, nested := strings.Cut(key, ".")
+	switch {
+	case nested:
+		lines = append(lines, parent+":", fmt.Sprintf("  %v: %v", child, yamlString(fi.URL)))
+		if fi.Alt != "" {
+			lines = append(lines, "  alt: "+yamlString(fi.Alt))
+		}
+
+		if fi.Caption != "" {
+			lines = append(lines, "  caption: "+yamlString(fi.Caption))
+		}
+
+		if fi.Hidden {
+			lines = append(lines, "  hidden: true")
+		}
+
+		return strings.Join(lines, "\n")
+	case key == DefaultFrontMatterFeatureImage:
+		lines = append(lines, key+":", "  - "+yamlString(fi.URL))
+	default:
+		if fi.Hidden {
+			return ""
+		}
+
+		lines = append(lines, fmt.Sprintf("%v: %v", key, yamlString(fi.URL)))
+	}
+
+	if fi.Alt != "" {
+		lines = append(lines, fmt.Sprintf("%v: %v", c.FrontMatter.FeatureImageAlt, yamlString(fi.Alt)))
+	}
+
+	if fi.Caption != "" {
+		lines = append(lines, fmt.Sprintf("%v: %v", c.FrontMatter.FeatureImageCaption, yamlString(fi.Caption)))
+	}
+
+	return strings.Join(lines, "\n")
+}
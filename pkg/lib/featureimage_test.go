@@ -0,0 +1,116 @@
+package ghosttohugo_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+func TestFeatureImage(t *testing.T) {
+	t.Parallel()
+
+	post := ghosttohugo.GhostPost{
+		Slug:                     "post",
+		HTML:                     sql.NullString{String: "<p>Body</p>", Valid: true},
+		FeatureImage:             sql.NullString{String: "__GHOST_URL__/content/images/cat.jpg", Valid: true},
+		FeatureImageAlt:          sql.NullString{String: `A "cat"`, Valid: true},
+		FeatureImageCaption:      sql.NullString{String: `Photo by <a href="https://example.com/me">me</a>`, Valid: true},
+		ShowTitleAndFeatureImage: true,
+	}
+
+	hidden := post
+	hidden.ShowTitleAndFeatureImage = false
+
+	none := post
+	none.FeatureImage = sql.NullString{}
+
+	tests := []struct {
+		key     string
+		p       ghosttohugo.GhostPost
+		want    string
+		notWant string
+	}{
+		{
+			"",
+			post,
+			`images:
+  - "https://nojs.example.com/content/images/cat.jpg"
+featureImageAlt: "A \"cat\""
+featureImageCaption: "Photo by <a href=\"https://example.com/me\">me</a>"
+`,
+			"",
+		},
+		{
+			// hidden images are still used for metadata
+			"",
+			hidden,
+			`images:
+  - "https://nojs.example.com/content/images/cat.jpg"
+`,
+			"hidden",
+		},
+		{
+			"cover.image",
+			hidden,
+			`cover:
+  image: "https://nojs.example.com/content/images/cat.jpg"
+  alt: "A \"cat\""
+  caption: "Photo by <a href=\"https://example.com/me\">me</a>"
+  hidden: true
+`,
+			"featureImageAlt",
+		},
+		{
+			"featured_image",
+			post,
+			`featured_image: "https://nojs.example.com/content/images/cat.jpg"
+featureImageAlt: "A \"cat\""
+`,
+			"",
+		},
+		{"featured_image", hidden, "slug: post\n", "cat.jpg"},
+		{ghosttohugo.FrontMatterFeatureImageNone, post, "slug: post\n", "cat.jpg"},
+		{"", none, "slug: post\n", "images"},
+	}
+
+	for i, test := range tests {
+		c := ghosttohugo.Config{
+			GhostURL:         "https://example.com",
+			LinkReplacements: map[string]string{"https://example.com": "https://nojs.example.com"},
+			FrontMatter:      ghosttohugo.FrontMatterConfig{FeatureImage: test.key},
+		}
+		c.ApplyDefaults()
+		c.Process()
+
+		err := c.ParseTemplate()
+		if err != nil {
+			t.Logf("test %v failed to parse template: %v", i, err.Error())
+			t.FailNow()
+		}
+
+		got, err := c.RenderString(test.p)
+		if err != nil {
+			t.Logf("test %v failed to render: %v", i, err.Error())
+			t.Fail()
+			continue
+		}
+
+		if !strings.Contains(got, test.want) || (test.notWant != "" && strings.Contains(got, test.notWant)) {
+			t.Logf("test %v failed: expected %q (and not %q) in:\n%v", i, test.want, test.notWant, got)
+			t.Fail()
+		}
+	}
+
+	// uploaded feature images are served from ImageBaseURL like other images
+	c := ghosttohugo.Config{GhostURL: "https://example.com", ImageBaseURL: "/images/"}
+	c.ApplyDefaults()
+	c.Process()
+
+	fi := c.FeatureImage(post)
+	if fi == nil || fi.URL != "/images/cat.jpg" {
+		t.Logf("expected the feature image to be rewritten to /images/cat.jpg, got %+v", fi)
+		t.Fail()
+	}
+}
@@ -0,0 +1,398 @@
+package ghosttohugo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// dateLayouts are the names that the date template function accepts in place
+// of a layout string.
+var dateLayouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"RFC822":      time.RFC822,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"DateTime":    time.DateTime,
+	"DateOnly":    time.DateOnly,
+	"TimeOnly":    time.TimeOnly,
+}
+
+// TemplateFuncs returns the functions that are available to every template,
+// in addition to text/template's builtins:
+//
+//   - date LAYOUT [TIMEZONE] TIME formats a time.Time, *time.Time,
+//     sql.NullTime or RFC 3339 string. LAYOUT is a Go layout or the name of
+//     one of the time package's layout constants, such as "RFC3339" or
+//     "DateOnly", and TIMEZONE is an IANA name such as "America/New_York".
+//   - yaml, toml and json quote a value so that it can be safely embedded
+//     in front matter, e.g. {{ .Post.Title | yaml }}.
+//   - truncate N [ELLIPSIS] STRING shortens STRING to at most N characters,
+//     breaking at a word boundary if possible and appending ELLIPSIS
+//     (default "…") if anything was removed.
+//   - plainify STRING strips HTML tags and decodes entities.
+//   - default DEFAULT VALUE returns VALUE, or DEFAULT if VALUE is empty,
+//     including an invalid sql.NullString.
+//   - join SEPARATOR LIST joins any slice; tags are joined by name.
+//   - slugify STRING converts STRING to a lowercase, hyphenated slug.
+//   - readingTime HTML estimates the minutes needed to read HTML the way
+//     Ghost does, see [ReadingTime].
+//   - sha256 STRING returns the hex-encoded SHA-256 digest of STRING.
+//
+// A new map is returned on every call, so it is safe to modify.
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"date":        templateDate,
+		"yaml":        templateYAML,
+		"toml":        templateTOML,
+		"json":        templateJSON,
+		"truncate":    templateTruncate,
+		"plainify":    templatePlainify,
+		"default":     templateDefault,
+		"join":        templateJoin,
+		"slugify":     templateSlugify,
+		"readingTime": templateReadingTime,
+		"sha256":      templateSHA256,
+	}
+}
+
+// AddTemplateFuncs makes fm available to templates, in addition to
+// [TemplateFuncs]. Functions with the same name as a built-in function
+// replace it. This must be called before [Config.ParseTemplate].
+func (c *Config) AddTemplateFuncs(fm template.FuncMap) {
+	if c.funcs == nil {
+		c.funcs = make(template.FuncMap, len(fm))
+	}
+
+	for k, v := range fm {
+		c.funcs[k] = v
+	}
+}
+
+// templateFuncs returns [TemplateFuncs] along with any functions added by
+// [Config.AddTemplateFuncs].
+func (c *Config) templateFuncs() template.FuncMap {
+	fm := TemplateFuncs()
+	for k, v := range c.funcs {
+		fm[k] = v
+	}
+
+	return fm
+}
+
+// toString converts common template values to a string: nullable SQL
+// strings become their value (or ""), tags become their name, and anything
+// else is formatted with fmt.
+func toString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case sql.NullString:
+		return v.String
+	case *sql.NullString:
+		if v == nil {
+			return ""
+		}
+
+		return v.String
+	case GhostTag:
+		return v.Name
+	case *GhostTag:
+		if v == nil {
+			return ""
+		}
+
+		return v.Name
+	case nil:
+		return ""
+	}
+
+	return fmt.Sprint(v)
+}
+
+// toTime converts the values that the date function accepts to a time.
+func toTime(v any) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v == nil {
+			return time.Time{}, nil
+		}
+
+		return *v, nil
+	case sql.NullTime:
+		return v.Time, nil
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return t, fmt.Errorf("failed to parse %q as an RFC 3339 time: %w", v, err)
+		}
+
+		return t, nil
+	case sql.NullString:
+		return toTime(v.String)
+	}
+
+	return time.Time{}, fmt.Errorf("date: unsupported time value of type %T", v)
+}
+
+func templateDate(layout string, args ...any) (string, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return "", fmt.Errorf("date: expected a layout, an optional timezone and a time, got %v argument(s)", len(args)+1)
+	}
+
+	t, err := toTime(args[len(args)-1])
+	if err != nil {
+		return "", err
+	}
+
+	if len(args) == 2 {
+		tz, ok := args[0].(string)
+		if !ok {
+			return "", fmt.Errorf("date: timezone must be a string, got %T", args[0])
+		}
+
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return "", fmt.Errorf("date: failed to load timezone %q: %w", tz, err)
+		}
+
+		t = t.In(loc)
+	}
+
+	if l, ok := dateLayouts[layout]; ok {
+		layout = l
+	}
+
+	return t.Format(layout), nil
+}
+
+// jsonString marshals v as JSON without escaping HTML characters, since the
+// output goes into front matter rather than a web page.
+func jsonString(v any) (string, error) {
+	var b bytes.Buffer
+
+	e := json.NewEncoder(&b)
+	e.SetEscapeHTML(false)
+
+	err := e.Encode(v)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// templateYAML quotes v as a YAML double-quoted scalar. JSON strings are
+// valid YAML, and unlike plain scalars they can't be misread as another type.
+func templateYAML(v any) (string, error) {
+	return jsonString(toString(v))
+}
+
+func templateTOML(v any) string {
+	return tomlString(toString(v))
+}
+
+func templateJSON(v any) (string, error) {
+	switch s := v.(type) {
+	case sql.NullString, *sql.NullString:
+		v = toString(s)
+	}
+
+	return jsonString(v)
+}
+
+func templateTruncate(n int, args ...any) (string, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return "", fmt.Errorf("truncate: expected a length, an optional ellipsis and a string, got %v argument(s)", len(args)+1)
+	}
+
+	ellipsis := "…"
+	if len(args) == 2 {
+		ellipsis = toString(args[0])
+	}
+
+	return truncate(toString(args[len(args)-1]), n, ellipsis), nil
+}
+
+// truncate shortens s to at most n runes, cutting at the last space if there
+// is one, and appends ellipsis if anything was removed.
+func truncate(s string, n int, ellipsis string) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	r := []rune(s)
+	t := string(r[:max(n, 0)])
+
+	// back up to the end of the previous word, unless the cut is already
+	// between two words
+	if !unicode.IsSpace(r[max(n, 0)]) {
+		i := strings.LastIndexFunc(t, unicode.IsSpace)
+		if i > 0 {
+			t = t[:i]
+		}
+	}
+
+	return strings.TrimRightFunc(t, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}) + ellipsis
+}
+
+var htmlTagRegexp = regexp.MustCompile(`(?s)<[^>]*>`)
+
+// plainify removes HTML tags from s and decodes its entities.
+func plainify(s string) string {
+	return html.UnescapeString(htmlTagRegexp.ReplaceAllString(s, ""))
+}
+
+func templatePlainify(v any) string {
+	return plainify(toString(v))
+}
+
+func templateDefault(def, v any) any {
+	switch s := v.(type) {
+	case sql.NullString:
+		if !s.Valid || s.String == "" {
+			return def
+		}
+
+		return s.String
+	case nil:
+		return def
+	}
+
+	r := reflect.ValueOf(v)
+	switch r.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		if r.Len() == 0 {
+			return def
+		}
+	case reflect.Pointer:
+		if r.IsNil() {
+			return def
+		}
+	default:
+		if r.IsZero() {
+			return def
+		}
+	}
+
+	return v
+}
+
+func templateJoin(sep string, v any) (string, error) {
+	switch l := v.(type) {
+	case []string:
+		return strings.Join(l, sep), nil
+	case nil:
+		return "", nil
+	}
+
+	r := reflect.ValueOf(v)
+	if r.Kind() != reflect.Slice && r.Kind() != reflect.Array {
+		return "", fmt.Errorf("join: expected a slice, got %T", v)
+	}
+
+	s := make([]string, r.Len())
+	for i := range s {
+		s[i] = toString(r.Index(i).Interface())
+	}
+
+	return strings.Join(s, sep), nil
+}
+
+// slugify lowercases s and replaces every run of characters other than
+// letters and digits with a single hyphen.
+func slugify(s string) string {
+	var b strings.Builder
+
+	hyphen := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+
+			hyphen = false
+			b.WriteRune(r)
+
+			continue
+		}
+
+		hyphen = true
+	}
+
+	return b.String()
+}
+
+func templateSlugify(v any) string {
+	return slugify(toString(v))
+}
+
+// Ghost's reading time assumptions, from @tryghost/helpers.
+const (
+	readingWordsPerMinute = 275
+	// The first image adds this many seconds, and each following image one
+	// second less, down to readingMinImageSeconds.
+	readingFirstImageSeconds = 12
+	readingMinImageSeconds   = 3
+)
+
+var (
+	imgTagRegexp = regexp.MustCompile(`(?is)<img\b[^>]*>`)
+	wordRegexp   = regexp.MustCompile(`[\p{L}\p{N}_]+`)
+)
+
+// countWords counts the words in HTML the way Ghost does: tags are replaced
+// with spaces, and every run of letters, digits and underscores is a word.
+func countWords(h string) int {
+	s := html.UnescapeString(htmlTagRegexp.ReplaceAllString(h, " "))
+	return len(wordRegexp.FindAllStringIndex(s, -1))
+}
+
+// countImages counts the <img> tags in HTML.
+func countImages(h string) int {
+	return len(imgTagRegexp.FindAllStringIndex(h, -1))
+}
+
+// ReadingTime estimates the whole minutes needed to read a post with the
+// given number of words and images, using Ghost's formula: 275 words per
+// minute, plus 12 seconds for the first image, 11 for the second, and so on
+// down to 3 seconds for the tenth image onwards. The result is rounded and
+// is never less than 1.
+func ReadingTime(words, images int) int {
+	seconds := float64(words) / readingWordsPerMinute * 60
+
+	for i := 0; i < images; i++ {
+		seconds += float64(max(readingFirstImageSeconds-i, readingMinImageSeconds))
+	}
+
+	return max(int(math.Round(seconds/60)), 1)
+}
+
+func templateReadingTime(v any) int {
+	h := toString(v)
+	return ReadingTime(countWords(h), countImages(h))
+}
+
+func templateSHA256(v any) string {
+	sum := sha256.Sum256([]byte(toString(v)))
+	return hex.EncodeToString(sum[:])
+}
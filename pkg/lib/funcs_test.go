@@ -0,0 +1,135 @@
+package ghosttohugo_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	t.Parallel()
+
+	published := time.Date(2024, 7, 1, 22, 30, 0, 0, time.UTC)
+
+	data := map[string]any{
+		"Time":    published,
+		"Title":   `Say "hi": it's <b>bold</b> & new`,
+		"Null":    sql.NullString{},
+		"Excerpt": sql.NullString{String: "An excerpt", Valid: true},
+		"Tags":    []ghosttohugo.GhostTag{{Name: "Go"}, {Name: "Hugo"}},
+		"HTML":    "<p>one two three</p><img src=a.png><img src=b.png>",
+		"Long":    "The quick brown fox jumps over the lazy dog",
+	}
+
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{`{{ date "2006-01-02" .Time }}`, "2024-07-01"},
+		{`{{ .Time | date "RFC3339" }}`, "2024-07-01T22:30:00Z"},
+		{`{{ date "2006-01-02 15:04 MST" "America/New_York" .Time }}`, "2024-07-01 18:30 EDT"},
+		{`{{ date "DateOnly" "2024-07-01T22:30:00Z" }}`, "2024-07-01"},
+		{`{{ .Title | yaml }}`, `"Say \"hi\": it's <b>bold</b> & new"`},
+		{`{{ .Title | toml }}`, `"Say \"hi\": it's <b>bold</b> & new"`},
+		{`{{ .Tags | json }}`, `[{"ID":"","Name":"Go","Slug":"","Visibility":""},{"ID":"","Name":"Hugo","Slug":"","Visibility":""}]`},
+		{`{{ .Excerpt | json }}`, `"An excerpt"`},
+		{`{{ .Long | truncate 20 }}`, "The quick brown fox…"},
+		{`{{ .Long | truncate 18 "..." }}`, "The quick brown..."},
+		{`{{ .Long | truncate 100 }}`, "The quick brown fox jumps over the lazy dog"},
+		{`{{ .Title | plainify }}`, `Say "hi": it's bold & new`},
+		{`{{ .Null | default "none" }}`, "none"},
+		{`{{ .Excerpt | default "none" }}`, "An excerpt"},
+		{`{{ "" | default "none" }}`, "none"},
+		{`{{ .Tags | join ", " }}`, "Go, Hugo"},
+		{`{{ .Title | plainify | slugify }}`, "say-hi-it-s-bold-new"},
+		{`{{ "Ünïcode  Title!" | slugify }}`, "ünïcode-title"},
+		{`{{ .HTML | readingTime }}`, "1"},
+		{`{{ "abc" | sha256 }}`, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	}
+
+	for i, test := range tests {
+		tmpl, err := template.New("test").Funcs(ghosttohugo.TemplateFuncs()).Parse(test.tmpl)
+		if err != nil {
+			t.Logf("test %v failed to parse: %v", i, err.Error())
+			t.Fail()
+			continue
+		}
+
+		var b strings.Builder
+
+		err = tmpl.Execute(&b, data)
+		if err != nil {
+			t.Logf("test %v failed to execute: %v", i, err.Error())
+			t.Fail()
+			continue
+		}
+
+		if b.String() != test.want {
+			t.Logf("test %v failed: got %v, want %v", i, b.String(), test.want)
+			t.Fail()
+		}
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		words, images, want int
+	}{
+		{0, 0, 1},
+		{275, 0, 1},
+		{550, 0, 2},
+		{1000, 0, 4},
+		// 12+11+10+9+8 = 50 seconds for images, plus 60 for words
+		{275, 5, 2},
+		// 12+11+...+3 = 75, then 3 seconds for each of the next 10 images
+		{0, 20, 2},
+	}
+
+	for i, test := range tests {
+		got := ghosttohugo.ReadingTime(test.words, test.images)
+		if got != test.want {
+			t.Logf("test %v failed: got %v, want %v", i, got, test.want)
+			t.Fail()
+		}
+	}
+}
+
+func TestAddTemplateFuncs(t *testing.T) {
+	t.Parallel()
+
+	c := ghosttohugo.Config{Template: `{{ shout .Post.Title }} {{ .Post.Slug | slugify }}`}
+	c.ApplyDefaults()
+	c.Process()
+	c.AddTemplateFuncs(template.FuncMap{
+		"shout": strings.ToUpper,
+		// replaces the built-in function
+		"slugify": func(s string) string { return "custom-" + s },
+	})
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	got, err := c.RenderString(ghosttohugo.GhostPost{
+		Title: "hello",
+		Slug:  "world",
+		HTML:  sql.NullString{Valid: true},
+	})
+	if err != nil {
+		t.Logf("failed to render: %v", err.Error())
+		t.FailNow()
+	}
+
+	if got != "HELLO custom-world" {
+		t.Logf("unexpected output: %q", got)
+		t.Fail()
+	}
+}
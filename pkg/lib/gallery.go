@@ -0,0 +1,167 @@
+package ghosttohugo
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// Values for [Config.GalleryMode].
+const (
+	// Gallery cards are left as they are. This is the default.
+	GalleryModeKeep = "keep"
+	// Gallery cards are replaced with [Config.GalleryShortcode], outside of
+	// the raw HTML shortcode, with the card's caption as a parameter and its
+	// images as a YAML list inside it:
+	//
+	//	{{< gallery caption="Our trip" >}}
+	//	- src: "/images/2024/01/a.jpg"
+	//	  alt: "A beach"
+	//	  width: 800
+	//	  height: 600
+	//	{{< /gallery >}}
+	//
+	// The shortcode can read the list with transform.Unmarshal .Inner.
+	GalleryModeShortcode = "shortcode"
+	// Gallery cards are replaced with a <figure> per image, inside a
+	// <figure class="kg-gallery"> that holds the card's caption, which
+	// needs no CSS or JavaScript to display.
+	GalleryModeFigures = "figures"
+)
+
+// DefaultGalleryShortcode is the default value of [Config.GalleryShortcode].
+const DefaultGalleryShortcode = "gallery"
+
+// galleryCardClass is the class of the figure that Ghost wraps galleries in.
+const galleryCardClass = "kg-gallery-card"
+
+// galleryImageAttributes are the attributes of a gallery's images that are
+// kept when it's converted to figures. Ghost's inline flex styles and
+// classes only work with its CSS.
+var galleryImageAttributes = map[string]bool{
+	"src":     true,
+	"alt":     true,
+	"title":   true,
+	"width":   true,
+	"height":  true,
+	"srcset":  true,
+	"sizes":   true,
+	"loading": true,
+}
+
+// parseGalleryCard returns the images in a gallery card's inner HTML, in
+// order, along with the card's caption as HTML.
+func parseGalleryCard(inner string) ([]xml.StartElement, string, error) {
+	x := xml.NewDecoder(strings.NewReader(inner))
+	x.Strict = false
+	x.AutoClose = xml.HTMLAutoClose
+	x.Entity = xml.HTMLEntity
+
+	var images []xml.StartElement
+
+	for {
+		t, err := x.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, "", fmt.Errorf("token parsing error: %w", err)
+		}
+
+		if st, ok := t.(xml.StartElement); ok && st.Name.Local == "img" {
+			images = append(images, xml.CopyToken(st).(xml.StartElement))
+		}
+	}
+
+	caption := ""
+	if m := figcaptionRegexp.FindStringSubmatch(inner); m != nil {
+		caption = strings.TrimSpace(m[1])
+	}
+
+	return images, caption, nil
+}
+
+// convertGallery is the [cardConverter] for gallery cards.
+func (c *Config) convertGallery(_ xml.StartElement, inner string, warn func(string, error)) (string, string, bool) {
+	images, caption, err := parseGalleryCard(inner)
+	if err != nil {
+		warn("failed to parse gallery card", err)
+		return "", "", false
+	}
+
+	if len(images) == 0 {
+		return "", "", false
+	}
+
+	if c.GalleryMode == GalleryModeFigures {
+		return galleryFigures(images, caption), "", true
+	}
+
+	var b strings.Builder
+	b.WriteString("{{< " + c.GalleryShortcode)
+
+	if caption != "" {
+		b.WriteString(" caption=" + shortcodeParam(caption))
+	}
+
+	b.WriteString(" >}}\n")
+
+	for _, img := range images {
+		img = c.processImage(img, warn)
+
+		fmt.Fprintf(&b, "- src: %v\n", yamlString(attr(img, "src")))
+
+		for _, name := range []string{"alt", "title"} {
+			if v := attr(img, name); v != "" {
+				fmt.Fprintf(&b, "  %v: %v\n", name, yamlString(v))
+			}
+		}
+
+		for _, name := range []string{"width", "height"} {
+			if v := attr(img, name); v != "" {
+				fmt.Fprintf(&b, "  %v: %v\n", name, v)
+			}
+		}
+	}
+
+	b.WriteString("{{< /" + c.GalleryShortcode + " >}}")
+
+	return "", b.String(), true
+}
+
+// galleryFigures renders a gallery's images as a sequence of figures. The
+// images are processed along with the rest of the post's HTML afterwards.
+func galleryFigures(images []xml.StartElement, caption string) string {
+	var b strings.Builder
+	b.WriteString(`<figure class="kg-gallery">`)
+
+	for _, img := range images {
+		b.WriteString("<figure><img")
+
+		for _, a := range img.Attr {
+			if galleryImageAttributes[a.Name.Local] {
+				fmt.Fprintf(&b, ` %v="%v"`, a.Name.Local, html.EscapeString(a.Value))
+			}
+		}
+
+		b.WriteString(">")
+
+		if title := attr(img, "title"); title != "" {
+			b.WriteString("<figcaption>" + html.EscapeString(title) + "</figcaption>")
+		}
+
+		b.WriteString("</figure>")
+	}
+
+	if caption != "" {
+		b.WriteString("<figcaption>" + caption + "</figcaption>")
+	}
+
+	b.WriteString("</figure>")
+
+	return b.String()
+}
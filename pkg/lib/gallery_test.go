@@ -0,0 +1,130 @@
+package ghosttohugo_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+const galleryCard = `<figure class="kg-card kg-gallery-card kg-width-wide kg-card-hascaption"><div class="kg-gallery-container">` +
+	`<div class="kg-gallery-row">` +
+	`<div class="kg-gallery-image"><img src="__GHOST_URL__/content/images/2024/01/a.png" width="2000" height="1500" loading="lazy" alt="A &quot;beach&quot;" style="flex: 1.33333 1 0%"></div>` +
+	`<div class="kg-gallery-image"><img src="__GHOST_URL__/content/images/2024/01/b.png" width="1000" height="1000" loading="lazy" alt="" title="Sunset" style="flex: 1 1 0%"></div>` +
+	`</div><div class="kg-gallery-row">` +
+	`<div class="kg-gallery-image"><img src="https://cdn.example.net/c.jpg" loading="lazy" alt="C" style="flex: 1 1 0%"></div>` +
+	`</div></div><figcaption>Our <em>trip</em></figcaption></figure>`
+
+func TestGallery(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "images", "2024", "01", "a.png"), 400, 300)
+	writePNG(t, filepath.Join(dir, "images", "2024", "01", "b.png"), 200, 200)
+
+	tests := []struct {
+		c       ghosttohugo.Config
+		want    []string
+		notWant []string
+	}{
+		{
+			ghosttohugo.Config{},
+			[]string{"kg-gallery-row", "flex: 1.33333"},
+			nil,
+		},
+		{
+			ghosttohugo.Config{
+				GalleryMode:      ghosttohugo.GalleryModeShortcode,
+				GhostContentPath: dir,
+				ImageBaseURL:     "images",
+			},
+			[]string{
+				"<p>before</p>{{</ rawhtml >}}\n" + `{{< gallery caption="Our <em>trip</em>" >}}` + "\n" +
+					"- src: \"images/2024/01/a.png\"\n  alt: \"A \\\"beach\\\"\"\n" +
+					"- src: \"images/2024/01/b.png\"\n  title: \"Sunset\"\n" +
+					"- src: \"https://cdn.example.net/c.jpg\"\n  alt: \"C\"\n" +
+					"{{< /gallery >}}\n{{< rawhtml >}}<p>after</p>",
+			},
+			[]string{"kg-gallery", "width"},
+		},
+		{
+			ghosttohugo.Config{
+				GalleryMode:      ghosttohugo.GalleryModeShortcode,
+				GalleryShortcode: "photos",
+				GhostContentPath: dir,
+				ImageDimensions:  true,
+			},
+			[]string{
+				`{{< photos caption="Our <em>trip</em>" >}}`,
+				"- src: \"https://example.com/content/images/2024/01/a.png\"\n  alt: \"A \\\"beach\\\"\"\n  width: 400\n  height: 300\n",
+				"  title: \"Sunset\"\n  width: 200\n  height: 200\n",
+				"{{< /photos >}}",
+			},
+			nil,
+		},
+		{
+			ghosttohugo.Config{
+				GalleryMode:  ghosttohugo.GalleryModeFigures,
+				ImageBaseURL: "/images/",
+			},
+			[]string{
+				`<p>before</p><figure class="kg-gallery">` +
+					`<figure><img src="/images/2024/01/a.png" width="" height="" loading="lazy" alt="A &#34;beach&#34;"></img></figure>` +
+					`<figure><img src="/images/2024/01/b.png" width="" height="" loading="lazy" alt="" title="Sunset"></img><figcaption>Sunset</figcaption></figure>` +
+					`<figure><img src="https://cdn.example.net/c.jpg" loading="lazy" alt="C"></img></figure>` +
+					`<figcaption>Our <em>trip</em></figcaption></figure><p>after</p>`,
+			},
+			[]string{"flex", "kg-gallery-row"},
+		},
+	}
+
+	for i, test := range tests {
+		c := test.c
+		c.GhostURL = "https://example.com"
+		c.ApplyDefaults()
+		c.Process()
+
+		err := c.Validate()
+		if err != nil {
+			t.Logf("test %v failed to validate: %v", i, err.Error())
+			t.FailNow()
+		}
+
+		err = c.ParseTemplate()
+		if err != nil {
+			t.Logf("test %v failed to parse template: %v", i, err.Error())
+			t.FailNow()
+		}
+
+		html := "<p>before</p>" + galleryCard + "<p>after</p>"
+
+		got, err := c.RenderString(ghosttohugo.GhostPost{Slug: "post", HTML: sql.NullString{String: html, Valid: true}})
+		if err != nil {
+			t.Logf("test %v failed to render: %v", i, err.Error())
+			t.Fail()
+			continue
+		}
+
+		for _, want := range test.want {
+			if !strings.Contains(got, want) {
+				t.Logf("test %v failed: expected %q in:\n%v", i, want, got)
+				t.Fail()
+			}
+		}
+
+		for _, notWant := range test.notWant {
+			if strings.Contains(got, notWant) {
+				t.Logf("test %v failed: didn't expect %q in:\n%v", i, notWant, got)
+				t.Fail()
+			}
+		}
+	}
+
+	c := ghosttohugo.Config{GalleryMode: "grid"}
+	if c.Validate() == nil {
+		t.Logf("expected an error for an invalid galleryMode")
+		t.Fail()
+	}
+}
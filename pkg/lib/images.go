@@ -0,0 +1,304 @@
+package ghosttohugo
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // registers the GIF decoder for image.DecodeConfig
+	_ "image/jpeg" // registers the JPEG decoder for image.DecodeConfig
+	_ "image/png"  // registers the PNG decoder for image.DecodeConfig
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Values for [Config.ImageSrcset].
+const (
+	// srcset is left pointing at Ghost's resized images. This is the
+	// default.
+	ImageSrcsetKeep = "keep"
+	// srcset and sizes are removed, so browsers always load src.
+	ImageSrcsetDrop = "drop"
+	// srcset URLs are rewritten to ImageBaseURL, like src. If
+	// GhostContentPath is set, sizes that don't exist there are removed.
+	ImageSrcsetRewrite = "rewrite"
+)
+
+// imageCardClass is the class of the figure that Ghost wraps images in.
+const imageCardClass = "kg-image-card"
+
+// contentImagesPath is where Ghost serves uploaded images from, relative to
+// its URL and to its content directory.
+const contentImagesPath = "/content/images/"
+
+// contentImage returns the path of an image URL relative to Ghost's
+// content/images directory, or false if it isn't an uploaded image.
+func (c *Config) contentImage(u string) (string, bool) {
+	for _, prefix := range []string{strings.TrimSuffix(c.GhostURL, "/") + contentImagesPath, ghostUrl + contentImagesPath, contentImagesPath} {
+		if rest, ok := strings.CutPrefix(u, prefix); ok && rest != "" {
+			return rest, true
+		}
+	}
+
+	return "", false
+}
+
+// localImagePath returns the path of an uploaded image's file in
+// GhostContentPath, or false if it doesn't have one.
+func (c *Config) localImagePath(u string) (string, bool) {
+	if c.GhostContentPath == "" {
+		return "", false
+	}
+
+	rest, ok := c.contentImage(u)
+	if !ok {
+		return "", false
+	}
+
+	if i := strings.IndexAny(rest, "?#"); i >= 0 {
+		rest = rest[:i]
+	}
+
+	rest = filepath.FromSlash(rest)
+	if !filepath.IsLocal(rest) {
+		return "", false
+	}
+
+	return filepath.Join(c.GhostContentPath, "images", rest), true
+}
+
+// localImageURL rewrites an uploaded image's URL to ImageBaseURL, if it's set.
+func (c *Config) localImageURL(u string) string {
+	if c.ImageBaseURL == "" {
+		return u
+	}
+
+	rest, ok := c.contentImage(u)
+	if !ok {
+		return u
+	}
+
+	return strings.TrimSuffix(c.ImageBaseURL, "/") + "/" + rest
+}
+
+// imageSize returns the intrinsic dimensions of an uploaded image, read from
+// its file in GhostContentPath. Only GIF, JPEG and PNG images are supported.
+func (c *Config) imageSize(u string) (int, int, error) {
+	p, ok := c.localImagePath(u)
+	if !ok {
+		return 0, 0, fmt.Errorf("%v is not an uploaded image in ghostContentPath", u)
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open image: %w", err)
+	}
+
+	defer f.Close()
+
+	ic, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to decode image %v: %w", p, err)
+	}
+
+	return ic.Width, ic.Height, nil
+}
+
+// rewriteSrcset rewrites each URL in a srcset to ImageBaseURL, dropping
+// candidates whose files don't exist in GhostContentPath, if it's set.
+func (c *Config) rewriteSrcset(srcset string) string {
+	var candidates []string
+
+	for _, candidate := range strings.Split(srcset, ",") {
+		f := strings.Fields(candidate)
+		if len(f) == 0 {
+			continue
+		}
+
+		if p, ok := c.localImagePath(f[0]); ok {
+			_, err := os.Stat(p)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+		}
+
+		f[0] = c.localImageURL(f[0])
+		candidates = append(candidates, strings.Join(f, " "))
+	}
+
+	return strings.Join(candidates, ", ")
+}
+
+// attr returns the value of an attribute of st.
+func attr(st xml.StartElement, name string) string {
+	for _, a := range st.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+
+	return ""
+}
+
+// processImage applies the image options to an <img> start tag: src is
+// rewritten to ImageBaseURL, srcset is handled according to ImageSrcset,
+// width and height are set to the image's intrinsic dimensions if
+// ImageDimensions is set and they can be read (and are otherwise blanked,
+// since Ghost assigns odd values to them), and loading="lazy" is added if
+// ImageLazy is set.
+func (c *Config) processImage(st xml.StartElement, warn func(msg string, err error)) xml.StartElement {
+	var w, h string
+	if c.ImageDimensions {
+		iw, ih, err := c.imageSize(attr(st, "src"))
+		if err != nil {
+			warn("failed to read image dimensions", err)
+		} else {
+			w, h = strconv.Itoa(iw), strconv.Itoa(ih)
+		}
+	}
+
+	srcset := attr(st, "srcset")
+	switch c.ImageSrcset {
+	case ImageSrcsetDrop:
+		srcset = ""
+	case ImageSrcsetRewrite:
+		srcset = c.rewriteSrcset(srcset)
+	}
+
+	attrs := make([]xml.Attr, 0, len(st.Attr)+1)
+	lazy := false
+
+	for _, a := range st.Attr {
+		switch a.Name.Local {
+		case "height":
+			a.Value = h
+		case "width":
+			a.Value = w
+		case "src":
+			a.Value = c.localImageURL(a.Value)
+		case "srcset":
+			a.Value = srcset
+		case "sizes":
+			if srcset == "" {
+				continue
+			}
+		case "loading":
+			if c.ImageLazy {
+				a.Value = "lazy"
+				lazy = true
+			}
+		}
+
+		if a.Name.Local == "srcset" && a.Value == "" {
+			continue
+		}
+
+		attrs = append(attrs, a)
+	}
+
+	if c.ImageLazy && !lazy {
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "loading"}, Value: "lazy"})
+	}
+
+	// dimensions that were read from the file are worth adding even if
+	// Ghost didn't set them
+	if w != "" && attr(st, "width") == "" && attr(st, "height") == "" {
+		attrs = append(attrs,
+			xml.Attr{Name: xml.Name{Local: "width"}, Value: w},
+			xml.Attr{Name: xml.Name{Local: "height"}, Value: h},
+		)
+	}
+
+	st.Attr = attrs
+
+	return st
+}
+
+// imageCard is the content of a Ghost image card.
+type imageCard struct {
+	Image xml.StartElement
+	// The URL that the image links to, if any.
+	Link string
+	// The card's caption, as HTML, if any.
+	Caption string
+}
+
+// parseImageCard reads an image card's inner HTML, returning false if it
+// doesn't contain an image.
+func parseImageCard(inner string) (imageCard, bool) {
+	var card imageCard
+
+	x := xml.NewDecoder(strings.NewReader(inner))
+	x.Strict = false
+	x.AutoClose = xml.HTMLAutoClose
+	x.Entity = xml.HTMLEntity
+
+	found := false
+	for !found {
+		t, err := x.Token()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return card, false
+			}
+
+			break
+		}
+
+		st, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch st.Name.Local {
+		case "a":
+			card.Link = attr(st, "href")
+		case "img":
+			card.Image = xml.CopyToken(st).(xml.StartElement)
+			found = true
+		}
+	}
+
+	if m := figcaptionRegexp.FindStringSubmatch(inner); m != nil {
+		card.Caption = strings.TrimSpace(m[1])
+	}
+
+	return card, found
+}
+
+// convertImage is the [cardConverter] for image cards, which replaces them
+// with ImageShortcode, e.g.
+// {{< figure src="/images/a.jpg" alt="A" caption="B" width="800" height="600" >}}.
+func (c *Config) convertImage(_ xml.StartElement, inner string, warn func(string, error)) (string, string, bool) {
+	card, ok := parseImageCard(inner)
+	if !ok {
+		return "", "", false
+	}
+
+	img := c.processImage(card.Image, warn)
+
+	var b strings.Builder
+	b.WriteString("{{< " + c.ImageShortcode)
+
+	params := []struct{ name, value string }{
+		{"src", attr(img, "src")},
+		{"alt", attr(img, "alt")},
+		{"caption", card.Caption},
+		{"link", c.rewriteURL(card.Link)},
+		{"width", attr(img, "width")},
+		{"height", attr(img, "height")},
+		{"loading", attr(img, "loading")},
+	}
+
+	for _, p := range params {
+		if p.value != "" {
+			b.WriteString(" " + p.name + "=" + shortcodeParam(p.value))
+		}
+	}
+
+	b.WriteString(" >}}")
+
+	return "", b.String(), true
+}
@@ -0,0 +1,185 @@
+package ghosttohugo_test
+
+import (
+	"database/sql"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+// writePNG writes a blank w x h PNG to p, creating its directory.
+func writePNG(t *testing.T, p string, w, h int) {
+	t.Helper()
+
+	err := os.MkdirAll(filepath.Dir(p), 0o755)
+	if err != nil {
+		t.Logf("failed to create image dir: %v", err.Error())
+		t.FailNow()
+	}
+
+	f, err := os.Create(p)
+	if err != nil {
+		t.Logf("failed to create image: %v", err.Error())
+		t.FailNow()
+	}
+
+	defer f.Close()
+
+	err = png.Encode(f, image.NewGray(image.Rect(0, 0, w, h)))
+	if err != nil {
+		t.Logf("failed to encode image: %v", err.Error())
+		t.FailNow()
+	}
+}
+
+func TestImages(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "images", "2024", "01", "cat.png"), 800, 600)
+	writePNG(t, filepath.Join(dir, "images", "size", "w600", "2024", "01", "cat.png"), 600, 450)
+
+	img := `<img src="__GHOST_URL__/content/images/2024/01/cat.png" class="kg-image" alt="Cat" loading="lazy" width="2000" height="1500" ` +
+		`srcset="__GHOST_URL__/content/images/size/w600/2024/01/cat.png 600w, __GHOST_URL__/content/images/size/w1000/2024/01/cat.png 1000w, __GHOST_URL__/content/images/2024/01/cat.png 800w" ` +
+		`sizes="(min-width: 720px) 720px">`
+	card := `<figure class="kg-card kg-image-card kg-card-hascaption">` + img + `<figcaption><span>A "cat"</span></figcaption></figure>`
+	missing := `<p><img src="/content/images/missing.png" alt="Missing"></p>`
+
+	tests := []struct {
+		c       ghosttohugo.Config
+		want    []string
+		notWant []string
+	}{
+		{
+			// the defaults only blank the dimensions
+			ghosttohugo.Config{},
+			[]string{`width="" height="" srcset="https://example.com/content/images/size/w600/2024/01/cat.png 600w, https://example.com/content/images/size/w1000`, `sizes=`},
+			nil,
+		},
+		{
+			ghosttohugo.Config{ImageSrcset: ghosttohugo.ImageSrcsetDrop},
+			[]string{`src="https://example.com/content/images/2024/01/cat.png"`},
+			[]string{"srcset", "sizes"},
+		},
+		{
+			ghosttohugo.Config{
+				GhostContentPath: dir,
+				ImageBaseURL:     "/images/",
+				ImageSrcset:      ghosttohugo.ImageSrcsetRewrite,
+				ImageDimensions:  true,
+				ImageLazy:        true,
+			},
+			[]string{
+				`src="/images/2024/01/cat.png" class="kg-image" alt="Cat" loading="lazy" width="800" height="600" srcset="/images/size/w600/2024/01/cat.png 600w, /images/2024/01/cat.png 800w" sizes="(min-width: 720px) 720px"`,
+				`<img src="/images/missing.png" alt="Missing" loading="lazy"></img>`,
+			},
+			[]string{"w1000", "example.com"},
+		},
+		{
+			ghosttohugo.Config{
+				GhostContentPath: dir,
+				ImageBaseURL:     "/images",
+				ImageDimensions:  true,
+				ImageShortcode:   "figure",
+			},
+			[]string{
+				"{{</ rawhtml >}}\n" + `{{< figure src="/images/2024/01/cat.png" alt="Cat" caption="<span>A \"cat\"</span>" width="800" height="600" loading="lazy" >}}` + "\n{{< rawhtml >}}",
+				`<img src="/images/missing.png" alt="Missing"></img>`,
+			},
+			[]string{"kg-image-card"},
+		},
+	}
+
+	for i, test := range tests {
+		c := test.c
+		c.GhostURL = "https://example.com"
+		c.Report = ghosttohugo.NewRunReport()
+		c.ApplyDefaults()
+		c.Process()
+
+		err := c.Validate()
+		if err != nil {
+			t.Logf("test %v failed to validate: %v", i, err.Error())
+			t.FailNow()
+		}
+
+		err = c.ParseTemplate()
+		if err != nil {
+			t.Logf("test %v failed to parse template: %v", i, err.Error())
+			t.FailNow()
+		}
+
+		got, err := c.RenderString(ghosttohugo.GhostPost{Slug: "post", HTML: sql.NullString{String: card + missing, Valid: true}})
+		if err != nil {
+			t.Logf("test %v failed to render: %v", i, err.Error())
+			t.Fail()
+			continue
+		}
+
+		for _, want := range test.want {
+			if !strings.Contains(got, want) {
+				t.Logf("test %v failed: expected %q in:\n%v", i, want, got)
+				t.Fail()
+			}
+		}
+
+		for _, notWant := range test.notWant {
+			if strings.Contains(got, notWant) {
+				t.Logf("test %v failed: didn't expect %q in:\n%v", i, notWant, got)
+				t.Fail()
+			}
+		}
+
+		// the missing image can't be measured
+		if c.ImageDimensions && len(c.Report.Warnings) != 1 {
+			t.Logf("test %v failed: expected one warning, got %+v", i, c.Report.Warnings)
+			t.Fail()
+		}
+	}
+
+	for _, c := range []ghosttohugo.Config{
+		{ImageSrcset: "resize"},
+		{ImageSrcset: ghosttohugo.ImageSrcsetRewrite},
+		{ImageDimensions: true},
+	} {
+		if c.Validate() == nil {
+			t.Logf("expected an error for %+v", c)
+			t.Fail()
+		}
+	}
+}
+
+func TestShortcodePlaceholders(t *testing.T) {
+	t.Parallel()
+
+	c := ghosttohugo.Config{GhostURL: "https://example.com", ImageShortcode: "figure"}
+	c.ApplyDefaults()
+	c.Process()
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	// a post can't forge the placeholder that marks where a shortcode goes
+	forged := `<p>before</p><!--ghost-to-hugo:shortcode:0--><p>after</p>`
+	card := `<figure class="kg-card kg-image-card"><img src="/content/images/cat.png" alt="Cat"></figure><p>end</p>`
+
+	got, err := c.RenderString(ghosttohugo.GhostPost{Slug: "post", HTML: sql.NullString{String: forged + card, Valid: true}})
+	if err != nil {
+		t.Logf("failed to render: %v", err.Error())
+		t.FailNow()
+	}
+
+	want := "<p>after</p>{{</ rawhtml >}}\n" + `{{< figure src="/content/images/cat.png" alt="Cat" >}}` + "\n{{< rawhtml >}}<p>end</p>"
+	if !strings.Contains(got, want) || strings.Count(got, "{{< figure") != 1 {
+		t.Logf("expected the shortcode only in place of the card, got:\n%v", got)
+		t.Fail()
+	}
+}
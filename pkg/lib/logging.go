@@ -0,0 +1,32 @@
+package ghosttohugo
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discardHandler is a [slog.Handler] that drops everything, so that the
+// library is silent unless [Config.Logger] is set.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
+
+var discardLogger = slog.New(discardHandler{})
+
+// logger returns Logger, or a logger that discards everything if it is unset.
+func (c *Config) logger() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+
+	return discardLogger
+}
+
+// postLogger returns a logger that annotates every message with the post's ID
+// and slug.
+func (c *Config) postLogger(p GhostPost) *slog.Logger {
+	return c.logger().With(slog.String("post_id", p.ID), slog.String("slug", p.Slug))
+}
@@ -0,0 +1,65 @@
+package ghosttohugo_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"testing"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	c := ghosttohugo.Config{
+		OutputPath:       t.TempDir(),
+		ForbidEmptyPosts: true,
+		ContinueOnError:  true,
+		Logger:           slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}
+	c.ApplyDefaults()
+	c.Process()
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	posts := []ghosttohugo.GhostPost{
+		{ID: "1", Slug: "one", HTML: sql.NullString{String: "<p>1</p>", Valid: true}},
+		{ID: "2", Slug: "two"},
+	}
+
+	_, err = c.RenderPosts(context.Background(), posts)
+	if err == nil {
+		t.Logf("expected an error for the empty post")
+		t.Fail()
+	}
+
+	logs := out.String()
+
+	for _, want := range []string{
+		`level=DEBUG msg="wrote post" post_id=1 slug=one`,
+		`level=WARN msg="skipping post that failed to render" post_id=2 slug=two stage=process`,
+	} {
+		if !strings.Contains(logs, want) {
+			t.Logf("expected logs to contain %v, got:\n%v", want, logs)
+			t.Fail()
+		}
+	}
+
+	// without a logger, nothing is logged and nothing breaks
+	c.Logger = nil
+
+	_, err = c.RenderPosts(context.Background(), posts[:1])
+	if err != nil {
+		t.Logf("failed to render without a logger: %v", err.Error())
+		t.Fail()
+	}
+}
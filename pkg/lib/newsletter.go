@@ -0,0 +1,92 @@
+package ghosttohugo
+
+import (
+	"database/sql"
+	"fmt"
+	"path"
+)
+
+// Newsletter is a row from Ghost's newsletters table.
+type Newsletter struct {
+	ID   string
+	Name string
+	Slug string
+}
+
+// All of the fields that map to [Newsletter].
+const QUERY_NEWSLETTERS_FIELDS = `
+id as ID,
+name as Name,
+slug as Slug
+`
+
+// Values for [Config.NewsletterMode].
+const (
+	// Email-only newsletters are treated like any other post, and are
+	// subject to the PostStatuses map. This is the default.
+	NewsletterModeDefault = ""
+	// Email-only newsletters are rendered to [Config.NewsletterSection]
+	// regardless of the PostStatuses map.
+	NewsletterModeArchive = "archive"
+	// Email-only newsletters are never rendered, regardless of the
+	// PostStatuses map.
+	NewsletterModeExclude = "exclude"
+)
+
+// DefaultNewsletterSection is the Hugo section that archived newsletters are
+// written to if [Config.NewsletterSection] is not set.
+const DefaultNewsletterSection = "newsletters"
+
+// IsNewsletter returns true if the post was sent as an email but never
+// published on the site.
+func (p GhostPost) IsNewsletter() bool {
+	if p.Status != GhostPostStatusSent {
+		return false
+	}
+
+	return p.NewsletterId.Valid ||
+		(p.EmailRecipientFilter != "" && p.EmailRecipientFilter != "none")
+}
+
+// GetNewsletter parses an SQL row-yielding iterator and returns a
+// [Newsletter] from it. The query should select [QUERY_NEWSLETTERS_FIELDS]
+// from the newsletters table.
+func GetNewsletter(rows *sql.Rows) (Newsletter, error) {
+	var n Newsletter
+
+	err := rows.Scan(&n.ID, &n.Name, &n.Slug)
+	if err != nil {
+		return n, fmt.Errorf("failed to marshal row into newsletter: %w", err)
+	}
+
+	return n, nil
+}
+
+// SetNewsletters makes the provided newsletters available to templates via
+// [PostTemplate.Newsletter], keyed on each post's NewsletterId.
+func (c *Config) SetNewsletters(n []Newsletter) {
+	c.newsletters = make(map[string]Newsletter, len(n))
+	for _, n := range n {
+		c.newsletters[n.ID] = n
+	}
+}
+
+// newsletter returns the newsletter that the post was sent with, or nil if
+// it is unknown.
+func (c *Config) newsletter(p GhostPost) *Newsletter {
+	if !p.NewsletterId.Valid {
+		return nil
+	}
+
+	n, ok := c.newsletters[p.NewsletterId.String]
+	if !ok {
+		return nil
+	}
+
+	return &n
+}
+
+// OutputFile returns the full path that the post will be written to.
+func (c *Config) OutputFile(p GhostPost) string {
+	return path.Join(c.OutputPath, c.section(p), fmt.Sprintf("%v.md", p.Slug))
+}
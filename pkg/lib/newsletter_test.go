@@ -0,0 +1,116 @@
+package ghosttohugo_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+func TestNewsletterMode(t *testing.T) {
+	t.Parallel()
+
+	newsletter := ghosttohugo.GhostPost{
+		Slug:         "weekly-1",
+		Type:         "post",
+		Status:       "sent",
+		Visibility:   "public",
+		NewsletterId: sql.NullString{String: "n1", Valid: true},
+	}
+
+	post := ghosttohugo.GhostPost{
+		Slug:       "hello",
+		Type:       "post",
+		Status:     "published",
+		Visibility: "public",
+	}
+
+	base := ghosttohugo.Config{
+		OutputPath:       "/out",
+		PostTypes:        map[string]bool{"post": true},
+		PostStatuses:     map[string]bool{"published": true, "sent": false},
+		PostVisibilities: map[string]bool{"public": true},
+	}
+	base.ApplyDefaults()
+
+	archive := base
+	archive.NewsletterMode = ghosttohugo.NewsletterModeArchive
+
+	exclude := base
+	exclude.NewsletterMode = ghosttohugo.NewsletterModeExclude
+	exclude.PostStatuses = map[string]bool{"published": true, "sent": true}
+
+	tests := []struct {
+		c     ghosttohugo.Config
+		p     ghosttohugo.GhostPost
+		valid bool
+		file  string
+	}{
+		{base, newsletter, false, "/out/weekly-1.md"},
+		{base, post, true, "/out/hello.md"},
+		{archive, newsletter, true, "/out/newsletters/weekly-1.md"},
+		{archive, post, true, "/out/hello.md"},
+		{exclude, newsletter, false, "/out/weekly-1.md"},
+		{exclude, post, true, "/out/hello.md"},
+	}
+
+	for i, test := range tests {
+		if got := test.c.IsValid(test.p); got != test.valid {
+			t.Logf("test %v failed: got IsValid=%v, want %v", i, got, test.valid)
+			t.Fail()
+		}
+
+		if got := test.c.OutputFile(test.p); got != test.file {
+			t.Logf("test %v failed: got OutputFile=%v, want %v", i, got, test.file)
+			t.Fail()
+		}
+	}
+
+	if post.IsNewsletter() {
+		t.Log("published post should not be a newsletter")
+		t.Fail()
+	}
+
+	if !archive.AuditPost(ghosttohugo.RenderedFile{Post: newsletter}).Allowed {
+		t.Log("archived newsletter should pass the audit")
+		t.Fail()
+	}
+
+	if base.AuditPost(ghosttohugo.RenderedFile{Post: newsletter}).Allowed {
+		t.Log("newsletter should fail the audit outside of archive mode")
+		t.Fail()
+	}
+}
+
+func TestRenderStringNewsletter(t *testing.T) {
+	t.Parallel()
+
+	c := ghosttohugo.Config{NewsletterMode: ghosttohugo.NewsletterModeArchive}
+	c.ApplyDefaults()
+	c.Process()
+	c.SetNewsletters([]ghosttohugo.Newsletter{{ID: "n1", Name: `The "Weekly"`, Slug: "weekly"}})
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	got, err := c.RenderString(ghosttohugo.GhostPost{
+		Slug:         "weekly-1",
+		Status:       "sent",
+		HTML:         sql.NullString{String: "<p>Hi</p>", Valid: true},
+		NewsletterId: sql.NullString{String: "n1", Valid: true},
+	})
+	if err != nil {
+		t.Logf("failed to render: %v", err.Error())
+		t.FailNow()
+	}
+
+	want := "slug: weekly-1\nnewsletter: \"The \\\"Weekly\\\"\"\nnewsletterSlug: weekly\nisPost: true\n"
+	if !strings.Contains(got, want) {
+		t.Logf("got %v, want it to contain %v", got, want)
+		t.Fail()
+	}
+}
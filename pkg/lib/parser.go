@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"encoding/xml"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 )
 
@@ -34,6 +34,15 @@ type el struct {
 // ProcessHTML removes all height and width tags from an input xml string, as well
 // as anything else needed in order to process the document.
 func (c *Config) ProcessHTML(s string) (string, error) {
+	return c.processHTML(s, func(msg string, err error) {
+		c.logger().Warn(msg, slog.Any("error", err))
+	})
+}
+
+// processHTML is [Config.ProcessHTML], passing problems that were worked
+// around, such as elements that can't be decoded, to warn so that they can be
+// attributed to a post.
+func (c *Config) processHTML(s string, warn func(msg string, err error)) (string, error) {
 	b := bytes.NewBuffer([]byte(s))
 	x := xml.NewDecoder(b)
 	x.Strict = false
@@ -47,7 +56,7 @@ func (c *Config) ProcessHTML(s string) (string, error) {
 		t, err := x.Token()
 		if err != nil { // EOF
 			if err.Error() != "EOF" {
-				return "", fmt.Errorf("token parsing error: %v", err.Error())
+				return "", fmt.Errorf("token parsing error: %w", err)
 			}
 			break
 		}
@@ -60,22 +69,12 @@ func (c *Config) ProcessHTML(s string) (string, error) {
 				var i any
 				err := x.DecodeElement(&i, &st)
 				if err != nil {
-					log.Printf("failed to decode img element: %v", err.Error())
+					warn("failed to decode img element", err)
 					continue
 				}
 
-				if len(st.Attr) != 0 {
-					for j := len(st.Attr) - 1; j >= 0; j-- {
-						switch st.Attr[j].Name.Local {
-						case "height":
-							// log.Printf("setting img height to empty")
-							st.Attr[j].Value = ""
-						case "width":
-							// log.Printf("setting img width to empty")
-							st.Attr[j].Value = ""
-						}
-					}
-				}
+				st = c.processImage(st, warn)
+
 				// write the modified token to the to-be-returned modified xml document
 				err = xe.EncodeToken(st)
 				if err != nil {
@@ -93,7 +92,7 @@ func (c *Config) ProcessHTML(s string) (string, error) {
 					var i el
 					err := x.DecodeElement(&i, &st)
 					if err != nil {
-						log.Printf("failed to decode img element: %v", err.Error())
+						warn("failed to decode a element", err)
 						continue
 					}
 
@@ -2,12 +2,17 @@ package ghosttohugo
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
 	"os"
 	"path"
 	"strings"
+	"sync/atomic"
 	"text/template"
 	"time"
 )
@@ -31,11 +36,47 @@ type FrontMatterConfig struct {
 	Draft string `json:"draft"`
 	// https://gohugo.io/content-management/urls/#slug
 	Slug string `json:"slug"`
+	// The string to use instead of 'summary' in front matter.
+	Summary string `json:"summary"`
+	// The string to use instead of 'description' in front matter.
+	Description string `json:"description"`
+	// Where the default template writes the feature image: "images" (the
+	// default), a nested key such as "cover.image", a plain key such as
+	// "featured_image", or "-" to leave it out. See [FeatureImage].
+	FeatureImage string `json:"featureImage"`
+	// The string to use instead of 'featureImageAlt' in front matter. Not
+	// used when FeatureImage is a nested key.
+	FeatureImageAlt string `json:"featureImageAlt"`
+	// The string to use instead of 'featureImageCaption' in front matter.
+	// Not used when FeatureImage is a nested key.
+	FeatureImageCaption string `json:"featureImageCaption"`
+	// The string to use instead of 'codeinjectionHead' in front matter, see
+	// [CodeInjectionParams].
+	CodeInjectionHead string `json:"codeInjectionHead"`
+	// The string to use instead of 'codeinjectionFoot' in front matter, see
+	// [CodeInjectionParams].
+	CodeInjectionFoot string `json:"codeInjectionFoot"`
 }
 
 type Config struct {
+	// If SetUnpublishedToNow is set to true, the last-used publication time
+	// is stored here in Unix nanoseconds, and each post's publication time is
+	// decremented by 1 second. It's accessed atomically so that posts can be
+	// processed concurrently, and is the first field so that it's 64-bit
+	// aligned on 32-bit platforms.
+	lastPublishOverride int64
+
 	// Connection string for the mysql database.
+	//
+	// Deprecated: use DatabaseConnectionString instead. This is still used if
+	// DatabaseConnectionString is empty.
 	MySQLConnectionString string `json:"mysqlConnectionString"`
+	// The database/sql driver name to use, such as "mysql" or "sqlite3".
+	// Defaults to "mysql". The driver itself must be imported by the program
+	// that uses this module.
+	DatabaseDriver string `json:"databaseDriver"`
+	// Connection string (data source name) for the database.
+	DatabaseConnectionString string `json:"databaseConnectionString"`
 	// Values to use for the front matter.
 	FrontMatter FrontMatterConfig `json:"frontMatter"`
 	// Your theme's shortcode that starts the output of raw html, such as:
@@ -56,6 +97,10 @@ type Config struct {
 	PostStatuses map[string]bool `json:"postStatuses"`
 	// Values are typically "public": true.
 	PostVisibilities map[string]bool `json:"postVisibilities"`
+	// Determines what happens to posts whose type, status or visibility
+	// isn't a key in PostTypes, PostStatuses or PostVisibilities. Values are
+	// "allow" (the default) or "reject".
+	UnknownValues string `json:"unknownValues"`
 	// If true, empty (null) posts will cause the program to halt.
 	ForbidEmptyPosts bool `json:"forbidEmptyPosts"`
 	// If true, posts without publication dates with be set to now.
@@ -68,6 +113,92 @@ type Config struct {
 	// LinkReplacements, but if you are doing something abnormal, you should set
 	// ReplaceLinks to true manually.)
 	LinkReplacements map[string]string `json:"linkReplacements"`
+	// Post IDs, UUIDs or slugs that are allowed to be published even though
+	// they are not public, such as a paid post that you intentionally want to
+	// mirror. See [Config.Verify].
+	PublishAllowlist []string `json:"publishAllowlist"`
+	// If set, a JSON audit report describing every rendered file and why it
+	// was allowed to be published will be written to this path.
+	AuditReportPath string `json:"auditReportPath"`
+	// Determines how email-only newsletters (posts with a status of "sent")
+	// are handled. Values are "" (treated like any other post), "archive"
+	// (rendered to NewsletterSection regardless of PostStatuses) or "exclude"
+	// (never rendered regardless of PostStatuses).
+	NewsletterMode string `json:"newsletterMode"`
+	// The Hugo section (subdirectory of OutputPath) that archived newsletters
+	// are written to. Defaults to "newsletters".
+	NewsletterSection string `json:"newsletterSection"`
+	// If set, Hugo configuration generated from Ghost's settings table (site
+	// title, params and menus) is written to this directory, such as a Hugo
+	// environment directory like config/production. Files that weren't
+	// generated are never overwritten. See [Config.WriteSiteConfig].
+	SiteConfigPath string `json:"siteConfigPath"`
+	// Path to Ghost's redirects.yaml or redirects.json file. Redirects that
+	// point to a rendered post are added to its front matter as aliases.
+	RedirectsPath string `json:"redirectsPath"`
+	// Path to a JSON object mapping old paths or slugs to their current slug
+	// or path, for slug history that isn't in Ghost's redirects file.
+	AliasMapPath string `json:"aliasMapPath"`
+	// If set, redirects that can't be expressed as aliases are written to
+	// this path as a Netlify-style _redirects file, such as
+	// "/path/to/site/static/_redirects".
+	RedirectsOutputPath string `json:"redirectsOutputPath"`
+	// Path to Ghost's routes.yaml. If set, posts are written to the Hugo
+	// section for the first collection whose filter matches them, and the
+	// collection's permalink is used for their URL. Filters that use tags
+	// require each post's Tags to be populated, see [QUERY_POST_TAGS].
+	RoutesPath string `json:"routesPath"`
+	// The Hugo section that posts in the "/" collection are written to when
+	// RoutesPath is set. Defaults to "posts".
+	RoutesRootSection string `json:"routesRootSection"`
+	// If true, nothing is written to disk: [Config.RenderOne] only renders,
+	// and [Config.RenderAll] writes a unified diff of what would change to
+	// DryRunOutput instead.
+	DryRun bool `json:"dryRun"`
+	// Where [Config.RenderAll] writes its diff when DryRun is set. Defaults
+	// to stdout.
+	DryRunOutput io.Writer `json:"-"`
+	// If set, the library records what happened during the export here, see
+	// [RunReport]. [LoadConfig] creates one if ReportPath or MetricsPath is
+	// set.
+	Report *RunReport `json:"-"`
+	// If set, [Config.RenderAll] writes [Config.Report] here as JSON.
+	ReportPath string `json:"reportPath"`
+	// If set, [Config.RenderAll] writes [Config.Report] here in the
+	// Prometheus text format, for the node exporter's textfile collector.
+	// The collector only reads files ending in .prom.
+	MetricsPath string `json:"metricsPath"`
+	// Where the library logs what it's doing, with post_id and slug
+	// attributes on messages about individual posts. Nothing is logged if
+	// this is nil.
+	Logger *slog.Logger `json:"-"`
+	// The number of posts to render concurrently. Defaults to the number of
+	// CPUs.
+	Workers int `json:"workers"`
+	// If true, posts that fail to load or render are skipped rather than
+	// stopping the export, and every failure is returned together as
+	// [PostErrors] once everything else is done.
+	ContinueOnError bool `json:"continueOnError"`
+	// If ContinueOnError is set, the export is stopped once more than this
+	// many posts have failed, and [ErrTooManyErrors] is returned. Failures
+	// to load and to render count towards the same limit, see
+	// [Config.RenderLoaded]. Zero means no limit.
+	MaxErrors int `json:"maxErrors"`
+	// How often [Watcher] polls for changes, as a duration such as "30s" or
+	// "5m". Defaults to one minute.
+	WatchInterval string `json:"watchInterval"`
+	// A command that [Watcher] and [WebhookServer] run after every export
+	// that changed something, such as ["hugo", "--minify"]. The first
+	// element is the program and the rest are its arguments.
+	PostExportCommand []string `json:"postExportCommand"`
+	// The secret that Ghost signs webhooks with, see [WebhookServer]. Every
+	// webhook is rejected if this is not set.
+	WebhookSecret string `json:"webhookSecret"`
+	// How long [WebhookServer] waits after the last webhook before exporting,
+	// as a duration such as "5s". Defaults to five seconds.
+	WebhookDebounce string `json:"webhookDebounce"`
+	// The address that the webhook server listens on, such as ":8080".
+	WebhookAddress string `json:"webhookAddress"`
 	// The template that will be rendered.
 	//
 	// The front matter will be placed at the top of every page. Usage looks
@@ -84,13 +215,97 @@ type Config struct {
 	//  {{ .PostHtml }}
 	//  `
 	Template string `json:"template"`
+	// If set, every .tmpl file in this directory (and in its partials/
+	// subdirectory) is parsed alongside Template, and each post is rendered
+	// with the file that best matches it, see [TemplateExt]. Template is
+	// still used for posts that no file matches.
+	TemplateDir string `json:"templateDir"`
+	// Like TemplateDir, but read from a file system such as an embed.FS.
+	// Takes precedence over TemplateDir.
+	TemplateFS fs.FS `json:"-"`
+	// The number of words of a post's plain text that are used as its
+	// excerpt if it doesn't have a custom excerpt. Defaults to 50.
+	ExcerptWords int `json:"excerptWords"`
+	// Determines what happens to each post's code injection (the head and
+	// foot HTML set in the post's settings in Ghost). Values are "strip"
+	// (the default), "params" or "inline", see [CodeInjectionParams] and
+	// [CodeInjectionInline].
+	CodeInjection string `json:"codeInjection"`
+	// If true, scripts are removed from code injection, other than JSON-LD
+	// structured data, along with frames, plugins, event handlers and
+	// javascript: URLs. Code injection that can't be parsed is dropped.
+	CodeInjectionNoScript bool `json:"codeInjectionNoScript"`
+	// If set, each post's HTML (and its code injection, unless it's
+	// stripped) is passed through an allowlist sanitizer before it's
+	// processed. Values are "nojs", which uses [NoJSPolicy], or "custom",
+	// which uses SanitizePolicy. What was removed is logged and added to
+	// Report.
+	Sanitizer string `json:"sanitizer"`
+	// The policy used if Sanitizer is "custom". [NoJSPolicy] is a good
+	// starting point.
+	SanitizePolicy SanitizePolicy `json:"sanitizePolicy"`
+	// Determines how embed cards are converted, keyed on provider: "youtube",
+	// "vimeo", "x" or "spotify". Values are "keep" (the default), "shortcode"
+	// or "link", see [EmbedShortcode] and [EmbedLink]. Embeds are converted
+	// before the HTML is sanitized, so they survive a sanitizer that removes
+	// iframes.
+	Embeds map[string]string `json:"embeds"`
+	// The path to Ghost's content directory (the one containing images/),
+	// so that uploaded images can be read, see ImageDimensions and
+	// ImageSrcset.
+	GhostContentPath string `json:"ghostContentPath"`
+	// If set, the URLs of uploaded images (those under /content/images/)
+	// are rewritten to start with this instead, e.g. "/images/" if Ghost's
+	// content/images directory is copied to the Hugo site's static/images.
+	ImageBaseURL string `json:"imageBaseUrl"`
+	// Determines what happens to the srcset attribute of images, which
+	// points at Ghost's resized copies. Values are "keep" (the default),
+	// "drop" or "rewrite", see [ImageSrcsetDrop] and [ImageSrcsetRewrite].
+	ImageSrcset string `json:"imageSrcset"`
+	// If true, the width and height of uploaded images are set to their
+	// intrinsic dimensions, read from GhostContentPath, instead of being
+	// blanked. This avoids layout shift as images load.
+	ImageDimensions bool `json:"imageDimensions"`
+	// If true, loading="lazy" is added to every image.
+	ImageLazy bool `json:"imageLazy"`
+	// If set, image cards are replaced with this shortcode, such as Hugo's
+	// built-in "figure", placed outside of the raw HTML shortcode. It's
+	// given src, alt, caption, link, width, height and loading parameters,
+	// whichever are set.
+	ImageShortcode string `json:"imageShortcode"`
+	// Determines how gallery cards are converted. Values are "keep" (the
+	// default), "shortcode" or "figures", see [GalleryModeShortcode] and
+	// [GalleryModeFigures]. Image URLs go through the same options as other
+	// images, such as ImageBaseURL.
+	GalleryMode string `json:"galleryMode"`
+	// The shortcode that gallery cards are replaced with if GalleryMode is
+	// "shortcode". Defaults to "gallery".
+	GalleryShortcode string `json:"galleryShortcode"`
+	// Determines how code blocks (<pre><code class="language-go">) are
+	// converted. Values are "keep" (the default), "fenced" or "highlight",
+	// see [CodeBlocksFenced] and [CodeBlocksHighlight]. Code card captions
+	// follow the converted block.
+	CodeBlocks string `json:"codeBlocks"`
+	// If true, converted code blocks get line numbers. Blocks with Prism's
+	// line-numbers class or data-start attribute get them regardless, and
+	// Prism's data-line attribute highlights lines.
+	CodeLineNumbers bool `json:"codeLineNumbers"`
 
 	// Parsed template - parsed once, reused later many times.
 	template *template.Template
 
-	// If SetUnpublishedToNow is set to true, the last-used time is stored here.
-	// Each post's publication time is decremented by 1 second.
-	lastPublishOverride time.Time
+	// Functions added with [Config.AddTemplateFuncs].
+	funcs template.FuncMap
+
+	// Newsletters keyed on their ID, see [Config.SetNewsletters].
+	newsletters map[string]Newsletter
+
+	// Redirects loaded from RedirectsPath and AliasMapPath, see
+	// [Config.SetRedirects].
+	redirects []Redirect
+
+	// Collections loaded from RoutesPath, see [Config.SetRoutes].
+	routes *Routes
 
 	// If true, <a href=""> tags will have each key replaced with its respective
 	// value. This is useful for swapping things like http://example.com with
@@ -136,6 +351,15 @@ type GhostPost struct {
 	NewsletterId             sql.NullString
 	ShowTitleAndFeatureImage bool
 
+	// Tags aren't part of the posts table, so these need to be populated
+	// separately if they're needed, see [QUERY_POST_TAGS].
+	Tags []GhostTag
+
+	// These come from the posts_meta table, so they need to be populated
+	// separately, see [QUERY_POSTS_META].
+	FeatureImageAlt     sql.NullString
+	FeatureImageCaption sql.NullString
+
 	// On the Go side, we need to parse these values before putting them into
 	// the [GhostPost] struct.
 	SqlCreatedAt string // time.Time
@@ -188,34 +412,52 @@ show_title_and_feature_image as ShowTitleAndFeatureImage
 
 const GhostPostStatusDraft = "draft"
 
+// nextPublishOverride returns the publication time for the next post without
+// one, which is a second before the previous one, starting from now.
+func (c *Config) nextPublishOverride() time.Time {
+	for {
+		last := atomic.LoadInt64(&c.lastPublishOverride)
+
+		next := last
+		if next == 0 {
+			next = time.Now().UnixNano()
+		}
+
+		next -= int64(time.Second)
+
+		if atomic.CompareAndSwapInt64(&c.lastPublishOverride, last, next) {
+			return time.Unix(0, next)
+		}
+	}
+}
+
 // ProcessGhostPost is called by [GetGhostPost] and fills in/processes fields
 // that are required in order for this module to fulfill its intended purpose.
+// A [*DateParseError] is returned if any of the datetimes are invalid.
 func (c *Config) ProcessGhostPost(post GhostPost) (GhostPost, error) {
 	var err error
 
 	post.CreatedAt, err = time.Parse("2006-01-02 15:04:05", post.SqlCreatedAt)
 	if err != nil {
-		return post, fmt.Errorf("failed to parse CreatedAt datetime: %v", err.Error())
+		return post, &DateParseError{PostID: post.ID, Field: "CreatedAt", Value: post.SqlCreatedAt, Err: err}
 	}
 
 	if post.SqlUpdatedAt.Valid {
 		post.UpdatedAt, err = time.Parse("2006-01-02 15:04:05", post.SqlUpdatedAt.String)
 		if err != nil {
-			return post, fmt.Errorf("failed to parse UpdatedAt datetime: %v", err.Error())
+			return post, &DateParseError{PostID: post.ID, Field: "UpdatedAt", Value: post.SqlUpdatedAt.String, Err: err}
 		}
 	}
 
 	if post.SqlPublishedAt.Valid {
 		post.PublishedAt, err = time.Parse("2006-01-02 15:04:05", post.SqlPublishedAt.String)
 		if err != nil {
-			return post, fmt.Errorf("failed to parse PublishedAt datetime: %v", err.Error())
+			return post, &DateParseError{PostID: post.ID, Field: "PublishedAt", Value: post.SqlPublishedAt.String, Err: err}
 		}
 	} else if !post.SqlPublishedAt.Valid && c.SetUnpublishedToNow {
-		if c.lastPublishOverride.IsZero() {
-			c.lastPublishOverride = time.Now()
-		}
-		c.lastPublishOverride = c.lastPublishOverride.Add(time.Duration(-1 * time.Second))
-		post.PublishedAt = c.lastPublishOverride
+		post.PublishedAt = c.nextPublishOverride()
+
+		c.postLogger(post).Debug("post is unpublished, setting publication time", slog.Time("published_at", post.PublishedAt))
 	}
 
 	// determine if it is a draft or not (needed later)
@@ -249,29 +491,61 @@ func (c *Config) ProcessGhostPost(post GhostPost) (GhostPost, error) {
 //			// ...
 //		}
 //	}
+//
+// Errors are returned as a [*PostError] that records whether the row couldn't
+// be scanned or its fields couldn't be processed.
 func (c *Config) GetGhostPost(rows *sql.Rows) (GhostPost, error) {
+	start := time.Now()
+	post, err := scanGhostPost(rows)
+	c.Report.since(StageScan, start)
+	if err != nil {
+		return post, &PostError{PostID: post.ID, Slug: post.Slug, Stage: StageScan, Err: err}
+	}
+
+	start = time.Now()
+	processed, err := c.ProcessGhostPost(post)
+	c.Report.since(StageProcess, start)
+	if err != nil {
+		return processed, &PostError{PostID: post.ID, Slug: post.Slug, Stage: StageProcess, Err: err}
+	}
+
+	return processed, nil
+}
+
+// scanGhostPost reads a row from [QUERY_POSTS_FIELDS] without processing it.
+func scanGhostPost(rows *sql.Rows) (GhostPost, error) {
 	var post GhostPost
 
 	err := rows.Scan(&post.ID, &post.UUID, &post.Title, &post.Slug, &post.Mobiledoc, &post.Lexical, &post.HTML, &post.CommentID, &post.Plaintext, &post.FeatureImage, &post.Featured, &post.Type, &post.Status, &post.Locale, &post.Visibility, &post.EmailRecipientFilter, &post.SqlCreatedAt, &post.CreatedBy, &post.SqlUpdatedAt, &post.UpdatedBy, &post.SqlPublishedAt, &post.PublishedBy, &post.CustomExcerpt, &post.CodeinjectionHead, &post.CodeinjectionFoot, &post.CustomTemplate, &post.CanonicalUrl, &post.NewsletterId, &post.ShowTitleAndFeatureImage)
 	if err != nil {
-		return post, fmt.Errorf("failed to marshal row into interface: %v", err.Error())
+		return post, fmt.Errorf("failed to marshal row into interface: %w", err)
 	}
 
-	return c.ProcessGhostPost(post)
+	return post, nil
 }
 
 const parsedTemplateName = "template"
 
-// ParseTemplate parses the user-configured template. This should only need to
-// be run once.
+// ParseTemplate parses the user-configured template, along with the files in
+// TemplateDir or TemplateFS if either is set. This should only need to be run
+// once. Templates can use [TemplateFuncs] and any functions added with
+// [Config.AddTemplateFuncs].
 func (conf *Config) ParseTemplate() error {
 	var err error
 
-	conf.template, err = template.New(parsedTemplateName).Parse(conf.Template)
+	conf.template, err = template.New(parsedTemplateName).Funcs(conf.templateFuncs()).Parse(conf.Template)
 	if err != nil {
 		return fmt.Errorf("failed to parse user-configured template: %w", err)
 	}
 
+	fsys := conf.templateFS()
+	if fsys != nil {
+		err = parseTemplateFiles(conf.template, fsys)
+		if err != nil {
+			return fmt.Errorf("failed to parse template files: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -286,42 +560,126 @@ type PostTemplate struct {
 	PostHTML          string
 	RawShortcodeStart string
 	RawShortcodeEnd   string
+	// The newsletter that the post was sent with, if known. See
+	// [Config.SetNewsletters].
+	Newsletter *Newsletter
+	// Old paths that redirect to this post, see [Config.Aliases].
+	Aliases []string
+	// The Hugo section that the post is written to, if any. See
+	// [Config.RoutesPath].
+	Section string
+	// An explicit URL for the post, only set if its routes.yaml permalink
+	// can't be expressed as a Hugo permalink.
+	URL string
+	// The post's custom excerpt, or the start of its plain text, see
+	// [Config.Excerpt].
+	Excerpt string
+	// The number of words in the post, counted the way Ghost does, including
+	// any in cards that were converted to shortcodes.
+	WordCount int
+	// The estimated minutes needed to read the post, matching Ghost's
+	// reading time, see [ReadingTime].
+	ReadingTime int
+	// The src of the first image in the post, if any, even if it's in a card
+	// that was converted to a shortcode.
+	FirstImage string
+	// Every heading in PostHTML, in order.
+	TableOfContents []Heading
+	// The post's feature image, or nil if it doesn't have one.
+	FeatureImage *FeatureImage
+	// FeatureImage rendered as YAML front matter according to
+	// [FrontMatterConfig.FeatureImage], without a trailing newline.
+	FeatureImageFrontMatter string
+	// The post's code injection, unless [Config.CodeInjection] is "strip".
+	CodeInjectionHead string
+	CodeInjectionFoot string
+	// The code injection rendered as YAML front matter if
+	// [Config.CodeInjection] is "params", without a trailing newline.
+	CodeInjectionFrontMatter string
 }
 
 const ghostUrl = "__GHOST_URL__"
 
 // Renders a Ghost post to Hugo markdown.
 func (c *Config) RenderString(post GhostPost) (string, error) {
+	s, _, err := c.renderString(post)
+	return s, err
+}
+
+// renderString is [Config.RenderString], but also returns the stage at which
+// rendering failed.
+func (c *Config) renderString(post GhostPost) (string, Stage, error) {
 	if !post.HTML.Valid {
 		if c.ForbidEmptyPosts {
-			return "", fmt.Errorf("post %v html is null, cannot render", post.ID)
+			return "", StageProcess, fmt.Errorf("post %v: %w", post.ID, ErrEmptyPost)
 		}
 
 		// return "", nil
 	}
 
+	start := time.Now()
 	h := strings.ReplaceAll(post.HTML.String, ghostUrl, c.GhostURL)
+	// the counts and first image are taken from the HTML as Ghost has it, so
+	// that cards converted to shortcodes aren't left out
+	orig := h
 
-	var err error
-	h, err = c.ProcessHTML(h)
+	warn := func(msg string, err error) {
+		c.postLogger(post).Warn(msg, slog.Any("error", err))
+		c.Report.addWarning(post, fmt.Sprintf("%v: %v", msg, err))
+	}
+
+	h, sc, err := c.convertCards(h, warn)
+	if err != nil {
+		c.Report.since(StageProcess, start)
+		return "", StageProcess, fmt.Errorf("failed to convert cards: %w", err)
+	}
+
+	h, head, foot, err := c.sanitize(post, h)
 	if err != nil {
-		return "", fmt.Errorf("failed to process html: %w", err)
+		c.Report.since(StageProcess, start)
+		return "", StageProcess, fmt.Errorf("failed to sanitize html: %w", err)
 	}
 
+	h, err = c.processHTML(h, warn)
+	c.Report.since(StageProcess, start)
+	if err != nil {
+		return "", StageProcess, fmt.Errorf("failed to process html: %w", err)
+	}
+
+	start = time.Now()
+	defer c.Report.since(StageTemplate, start)
+
+	t := c.postTemplate(post)
+	fi := c.FeatureImage(post)
+
 	b := bytes.NewBuffer([]byte{})
-	err = c.template.Execute(b, PostTemplate{
-		FrontMatterConfig: c.FrontMatter,
-		Post:              post,
-		PostDate:          post.PublishedAt.Format(time.RFC3339),
-		PostHTML:          h,
-		RawShortcodeStart: c.RawShortcodeStart,
-		RawShortcodeEnd:   c.RawShortcodeEnd,
+	err = t.Execute(b, PostTemplate{
+		FrontMatterConfig:        c.FrontMatter,
+		Post:                     post,
+		PostDate:                 post.PublishedAt.Format(time.RFC3339),
+		PostHTML:                 c.inlineCodeInjection(c.expandShortcodes(h, sc), head, foot),
+		RawShortcodeStart:        c.RawShortcodeStart,
+		RawShortcodeEnd:          c.RawShortcodeEnd,
+		Newsletter:               c.newsletter(post),
+		Aliases:                  c.Aliases(post),
+		Section:                  c.section(post),
+		URL:                      c.postURL(post),
+		Excerpt:                  c.Excerpt(post),
+		WordCount:                countWords(orig),
+		ReadingTime:              readingTime(post, orig),
+		FirstImage:               c.localImageURL(firstImage(orig)),
+		TableOfContents:          tableOfContents(h),
+		FeatureImage:             fi,
+		FeatureImageFrontMatter:  c.featureImageFrontMatter(fi),
+		CodeInjectionHead:        head,
+		CodeInjectionFoot:        foot,
+		CodeInjectionFrontMatter: c.codeInjectionFrontMatter(head, foot),
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to render post: %w", err)
+		return "", StageTemplate, &TemplateError{PostID: post.ID, Template: t.Name(), Err: err}
 	}
 
-	return b.String(), nil
+	return b.String(), "", nil
 }
 
 // Default values used in the config if not set.
@@ -334,6 +692,29 @@ const (
 {{ .FrontMatterConfig.Date }}: "{{ .PostDate }}"
 {{ .FrontMatterConfig.Draft }}: {{ .Post.IsDraft }}
 {{ .FrontMatterConfig.Slug }}: {{ .Post.Slug }}
+{{- with .Excerpt }}
+{{ $.FrontMatterConfig.Summary }}: {{ yaml . }}
+{{ $.FrontMatterConfig.Description }}: {{ yaml . }}
+{{- end }}
+{{- with .FeatureImageFrontMatter }}
+{{ . }}
+{{- end }}
+{{- with .CodeInjectionFrontMatter }}
+{{ . }}
+{{- end }}
+{{- with .Newsletter }}
+newsletter: {{ printf "%q" .Name }}
+newsletterSlug: {{ .Slug }}
+{{- end }}
+{{- with .URL }}
+url: {{ printf "%q" . }}
+{{- end }}
+{{- with .Aliases }}
+aliases:
+{{- range . }}
+  - {{ printf "%q" . }}
+{{- end }}
+{{- end }}
 isPost: true
 ---
 
@@ -365,6 +746,30 @@ func (c *Config) ApplyDefaults() {
 		c.PostVisibilities = map[string]bool{"public": true}
 	}
 
+	if c.UnknownValues == "" {
+		c.UnknownValues = UnknownValuesAllow
+	}
+
+	if c.CodeInjection == "" {
+		c.CodeInjection = CodeInjectionStrip
+	}
+
+	if c.ImageSrcset == "" {
+		c.ImageSrcset = ImageSrcsetKeep
+	}
+
+	if c.GalleryMode == "" {
+		c.GalleryMode = GalleryModeKeep
+	}
+
+	if c.GalleryShortcode == "" {
+		c.GalleryShortcode = DefaultGalleryShortcode
+	}
+
+	if c.CodeBlocks == "" {
+		c.CodeBlocks = CodeBlocksKeep
+	}
+
 	if c.RawShortcodeStart == "" {
 		c.RawShortcodeStart = DefaultRawShortcodeStart
 	}
@@ -373,16 +778,30 @@ func (c *Config) ApplyDefaults() {
 		c.RawShortcodeEnd = DefaultRawShortcodeEnd
 	}
 
+	if c.NewsletterSection == "" {
+		c.NewsletterSection = DefaultNewsletterSection
+	}
+
+	if c.RoutesRootSection == "" {
+		c.RoutesRootSection = DefaultRoutesRootSection
+	}
+
+	if c.DatabaseDriver == "" {
+		c.DatabaseDriver = DefaultDatabaseDriver
+	}
+
 	c.FrontMatter.ApplyDefaults()
 }
 
 // Default value that is used for front matter in lieu of a user-configured
 // value.
 const (
-	DefaultFrontMatterTitle = "title"
-	DefaultFrontMatterDate  = "date"
-	DefaultFrontMatterSlug  = "slug"
-	DefaultFrontMatterDraft = "draft"
+	DefaultFrontMatterTitle       = "title"
+	DefaultFrontMatterDate        = "date"
+	DefaultFrontMatterSlug        = "slug"
+	DefaultFrontMatterDraft       = "draft"
+	DefaultFrontMatterSummary     = "summary"
+	DefaultFrontMatterDescription = "description"
 )
 
 // ApplyDefaults applies sensible defaults to the front matter config if left
@@ -401,6 +820,27 @@ func (f *FrontMatterConfig) ApplyDefaults() {
 	if f.Slug == "" {
 		f.Slug = DefaultFrontMatterSlug
 	}
+	if f.Summary == "" {
+		f.Summary = DefaultFrontMatterSummary
+	}
+	if f.Description == "" {
+		f.Description = DefaultFrontMatterDescription
+	}
+	if f.FeatureImage == "" {
+		f.FeatureImage = DefaultFrontMatterFeatureImage
+	}
+	if f.FeatureImageAlt == "" {
+		f.FeatureImageAlt = DefaultFrontMatterFeatureImageAlt
+	}
+	if f.FeatureImageCaption == "" {
+		f.FeatureImageCaption = DefaultFrontMatterFeatureImageCaption
+	}
+	if f.CodeInjectionHead == "" {
+		f.CodeInjectionHead = DefaultFrontMatterCodeInjectionHead
+	}
+	if f.CodeInjectionFoot == "" {
+		f.CodeInjectionFoot = DefaultFrontMatterCodeInjectionFoot
+	}
 }
 
 // makeOutputDir ensures that the desired output directory exists.
@@ -428,23 +868,109 @@ func (c *Config) Process() {
 	c.ReplaceLinks = len(c.LinkReplacements) > 0
 }
 
+// Validate checks the config for values that can't be used. You shouldn't
+// normally need to execute this, because it's called automatically by
+// [LoadConfig].
+func (c *Config) Validate() error {
+	switch c.NewsletterMode {
+	case NewsletterModeDefault, NewsletterModeArchive, NewsletterModeExclude:
+	default:
+		return fmt.Errorf("unknown newsletterMode %q", c.NewsletterMode)
+	}
+
+	switch c.UnknownValues {
+	case "", UnknownValuesAllow, UnknownValuesReject:
+	default:
+		return fmt.Errorf("invalid unknownValues %q, expected \"allow\" or \"reject\"", c.UnknownValues)
+	}
+
+	switch c.CodeInjection {
+	case "", CodeInjectionStrip, CodeInjectionParams, CodeInjectionInline:
+	default:
+		return fmt.Errorf("invalid codeInjection %q, expected \"strip\", \"params\" or \"inline\"", c.CodeInjection)
+	}
+
+	switch c.Sanitizer {
+	case SanitizerNone, SanitizerNoJS, SanitizerCustom:
+	default:
+		return fmt.Errorf("invalid sanitizer %q, expected \"nojs\" or \"custom\"", c.Sanitizer)
+	}
+
+	switch c.ImageSrcset {
+	case "", ImageSrcsetKeep, ImageSrcsetDrop, ImageSrcsetRewrite:
+	default:
+		return fmt.Errorf("invalid imageSrcset %q, expected \"keep\", \"drop\" or \"rewrite\"", c.ImageSrcset)
+	}
+
+	if c.ImageSrcset == ImageSrcsetRewrite && c.ImageBaseURL == "" {
+		return fmt.Errorf("imageSrcset %q requires imageBaseUrl", c.ImageSrcset)
+	}
+
+	if c.ImageDimensions && c.GhostContentPath == "" {
+		return fmt.Errorf("imageDimensions requires ghostContentPath")
+	}
+
+	switch c.GalleryMode {
+	case "", GalleryModeKeep, GalleryModeShortcode, GalleryModeFigures:
+	default:
+		return fmt.Errorf("invalid galleryMode %q, expected \"keep\", \"shortcode\" or \"figures\"", c.GalleryMode)
+	}
+
+	switch c.CodeBlocks {
+	case "", CodeBlocksKeep, CodeBlocksFenced, CodeBlocksHighlight:
+	default:
+		return fmt.Errorf("invalid codeBlocks %q, expected \"keep\", \"fenced\" or \"highlight\"", c.CodeBlocks)
+	}
+
+	for provider, mode := range c.Embeds {
+		switch provider {
+		case EmbedProviderYouTube, EmbedProviderVimeo, EmbedProviderX, EmbedProviderSpotify:
+		default:
+			return fmt.Errorf("unknown embed provider %q, expected \"youtube\", \"vimeo\", \"x\" or \"spotify\"", provider)
+		}
+
+		switch mode {
+		case "", EmbedKeep, EmbedShortcode, EmbedLink:
+		default:
+			return fmt.Errorf("invalid embed mode %q for %v, expected \"keep\", \"shortcode\" or \"link\"", mode, provider)
+		}
+	}
+
+	_, err := c.watchInterval()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.webhookDebounce()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // LoadConfig reads from file f and applies sensible defaults to values not
 // specifically set by the user.
 func LoadConfig(f string) (Config, error) {
 	b, err := os.ReadFile(f)
 	if err != nil {
-		return Config{}, fmt.Errorf("failed to load config from %v: %v", f, err)
+		return Config{}, fmt.Errorf("failed to load config from %v: %w", f, err)
 	}
 
 	var c Config
 	err = json.Unmarshal(b, &c)
 	if err != nil {
-		return Config{}, fmt.Errorf("failed to unmarshal config from %v: %v", f, err)
+		return Config{}, fmt.Errorf("failed to unmarshal config from %v: %w", f, err)
 	}
 
 	c.ApplyDefaults()
 	c.Process()
 
+	err = c.Validate()
+	if err != nil {
+		return c, fmt.Errorf("invalid config %v: %w", f, err)
+	}
+
 	err = c.makeOutputDir()
 	if err != nil {
 		return c, fmt.Errorf("failed to make output dir when loading config: %w", err)
@@ -455,36 +981,182 @@ func LoadConfig(f string) (Config, error) {
 		return c, fmt.Errorf("failed to parse template when loading config: %w", err)
 	}
 
+	err = c.LoadRoutes()
+	if err != nil {
+		return c, fmt.Errorf("failed to load routes when loading config: %w", err)
+	}
+
+	err = c.LoadRedirects()
+	if err != nil {
+		return c, fmt.Errorf("failed to load redirects when loading config: %w", err)
+	}
+
+	if c.ReportPath != "" || c.MetricsPath != "" {
+		c.Report = NewRunReport()
+	}
+
 	return c, nil
 }
 
-// Renders all the markdown posts from Ghost to the target directory.
+// Renders all the markdown posts from Ghost to the target directory. Once
+// everything has been written, the output is checked with [Config.Verify];
+// if any non-public content was rendered, its files are removed and an error
+// is returned.
+//
+// If DryRun is set, nothing is written; instead, a unified diff of every file
+// that would change is written to DryRunOutput, followed by a summary.
+//
+// Posts are rendered concurrently, see [Config.RenderAllContext].
 func (c *Config) RenderAll(p []GhostPost) error {
-	for _, p := range p {
-		_, _, err := c.RenderOne(p)
+	return c.RenderAllContext(context.Background(), p)
+}
+
+// RenderAllContext is [Config.RenderAll] with cancellation. Posts are rendered
+// by up to Workers goroutines, see [Config.RenderPosts].
+//
+// If ContinueOnError is set, the posts that rendered successfully are still
+// verified and redirects are still written, and then [PostErrors] is
+// returned if anything failed.
+func (c *Config) RenderAllContext(ctx context.Context, p []GhostPost) error {
+	return c.renderAll(ctx, p, 0)
+}
+
+// RenderLoaded is [Config.RenderAllContext] for posts returned by
+// [Config.LoadPosts], given the error it returned. If some posts failed to
+// load, they count towards MaxErrors along with the posts that fail to
+// render, so that MaxErrors applies to the export as a whole; only the
+// rendering failures are returned, since the caller already has the others.
+// If loadErr isn't [PostErrors], or there were already too many failures, it
+// is returned and nothing is rendered.
+func (c *Config) RenderLoaded(ctx context.Context, p []GhostPost, loadErr error) error {
+	errs, ok := skippable(loadErr)
+	if !ok {
+		return loadErr
+	}
+
+	return c.renderAll(ctx, p, len(errs))
+}
+
+// renderAll implements [Config.RenderAllContext], where prior failures count
+// towards MaxErrors, see [Config.RenderLoaded].
+func (c *Config) renderAll(ctx context.Context, p []GhostPost, prior int) (err error) {
+	if c.DryRun {
+		return c.renderAllDryRun(ctx, p)
+	}
+
+	defer func() {
+		reportErr := c.WriteReport()
+		if err == nil {
+			err = reportErr
+		}
+	}()
+
+	files, renderErr := c.renderPosts(ctx, p, prior)
+
+	errs, ok := skippable(renderErr)
+	if !ok {
+		return renderErr
+	}
+
+	r, err := c.Verify(files)
+	if err != nil {
+		// don't leave non-public content behind for the site build
+		rmErr := c.removeUnsafe(r)
+		if rmErr != nil {
+			return fmt.Errorf("failed to verify rendered posts: %w, and failed to remove them: %w", err, rmErr)
+		}
+
+		return fmt.Errorf("failed to verify rendered posts: %w", err)
+	}
+
+	if c.RedirectsOutputPath != "" {
+		err = c.WriteRedirects(p)
 		if err != nil {
-			return fmt.Errorf("failed to render posts: %w", err)
+			return fmt.Errorf("failed to write redirects: %w", err)
 		}
 	}
 
+	c.logger().Info("rendered posts", slog.Int("rendered", len(files)), slog.Int("failed", len(errs)))
+
+	// only set if ContinueOnError is set and some posts failed
+	return renderErr
+}
+
+// renderAllDryRun is the DryRun implementation of [Config.RenderAll].
+func (c *Config) renderAllDryRun(ctx context.Context, p []GhostPost) error {
+	cs, err := c.DiffContext(ctx, p)
+	if err != nil {
+		return fmt.Errorf("failed to diff posts: %w", err)
+	}
+
+	w := c.DryRunOutput
+	if w == nil {
+		w = os.Stdout
+	}
+
+	err = cs.WriteDiff(w)
+	if err != nil {
+		return err
+	}
+
+	files := make([]RenderedFile, 0, len(p))
+	for _, p := range p {
+		files = append(files, RenderedFile{Path: c.OutputFile(p), Post: p})
+	}
+
+	_, err = c.Verify(files)
+	if err != nil {
+		return fmt.Errorf("failed to verify rendered posts: %w", err)
+	}
+
 	return nil
 }
 
 // Renders all the markdown posts from Ghost to the target directory. Returns
 // the number of bytes written and  the full file path that was written to.
+//
+// If DryRun is set, the post is rendered but not written.
+//
+// Errors are returned as a [*PostError] that records the stage that failed.
 func (c *Config) RenderOne(p GhostPost) (int, string, error) {
-	b, err := c.RenderString(p)
+	n, f, stage, err := c.renderOne(p)
 	if err != nil {
-		return 0, "", fmt.Errorf("failed to render post %v: %w", p.UUID, err)
+		return n, f, &PostError{PostID: p.ID, Slug: p.Slug, Stage: stage, Err: err}
+	}
+
+	return n, f, nil
+}
+
+// renderOne is [Config.RenderOne], but also returns the stage at which
+// rendering failed.
+func (c *Config) renderOne(p GhostPost) (int, string, Stage, error) {
+	b, stage, err := c.renderString(p)
+	if err != nil {
+		return 0, "", stage, fmt.Errorf("failed to render post %v: %w", p.UUID, err)
+	}
+
+	f := c.OutputFile(p)
+	if c.DryRun {
+		return len(b), f, "", nil
+	}
+
+	start := time.Now()
+	defer c.Report.since(StageWrite, start)
+
+	err = os.MkdirAll(path.Dir(f), 0o755)
+	if err != nil {
+		return 0, "", StageWrite, &WriteError{PostID: p.ID, Path: path.Dir(f), Err: err}
 	}
 
-	f := path.Join(c.OutputPath, fmt.Sprintf("%v.md", p.Slug))
 	err = os.WriteFile(f, []byte(b), 0o644)
 	if err != nil {
-		return 0, "", fmt.Errorf("failed to write post %v to %v: %w", p.UUID, f, err)
+		return 0, "", StageWrite, &WriteError{PostID: p.ID, Path: f, Err: err}
 	}
 
-	return len(b), f, nil
+	c.postLogger(p).Debug("wrote post", slog.String("path", f), slog.Int("bytes", len(b)))
+	c.Report.addRendered(len(b))
+
+	return len(b), f, "", nil
 }
 
 // IsValid returns true or false if the post meets the criteria for being
@@ -493,29 +1165,12 @@ func (c *Config) RenderOne(p GhostPost) (int, string, error) {
 // perform any filtering at all.
 //
 // This function does not do any checks regarding the published/draft state.
+//
+// Email-only newsletters are subject to [Config.NewsletterMode] instead of the
+// PostStatuses map unless the mode is left unset.
+//
+// Values that aren't in a map are allowed unless UnknownValues is "reject".
+// Use [Config.Decide] to find out which rule rejected a post.
 func (c *Config) IsValid(p GhostPost) bool {
-	if len(c.PostTypes) == 0 || len(c.PostStatuses) == 0 ||
-		len(c.PostVisibilities) == 0 {
-		return false
-	}
-
-	for k, v := range c.PostTypes {
-		if p.Type == k && !v {
-			return false
-		}
-	}
-
-	for k, v := range c.PostStatuses {
-		if p.Status == k && !v {
-			return false
-		}
-	}
-
-	for k, v := range c.PostVisibilities {
-		if p.Visibility == k && !v {
-			return false
-		}
-	}
-
-	return true
+	return c.Decide(p).Valid
 }
@@ -0,0 +1,320 @@
+package ghosttohugo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Redirect is a single entry from Ghost's redirects file, or from a
+// user-provided alias map.
+type Redirect struct {
+	// A regular expression, in Ghost's redirects format, matching the old
+	// path, such as "^/old-slug/$" or "/old-slug/".
+	From string `json:"from"`
+	// The path or URL to redirect to.
+	To string `json:"to"`
+	// True for a 301 redirect, false for a 302 redirect.
+	Permanent bool `json:"permanent"`
+}
+
+// ParseRedirectsJSON parses Ghost's redirects.json format, which is a list of
+// objects with "from", "to" and "permanent" keys.
+func ParseRedirectsJSON(b []byte) ([]Redirect, error) {
+	var r []Redirect
+
+	err := json.Unmarshal(b, &r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse redirects json: %w", err)
+	}
+
+	return r, nil
+}
+
+// ParseRedirectsYAML parses Ghost's redirects.yaml format, which groups
+// "from: to" pairs under their HTTP status codes (301 or 302).
+func ParseRedirectsYAML(b []byte) ([]Redirect, error) {
+	n, err := parseYAML(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse redirects yaml: %w", err)
+	}
+
+	var r []Redirect
+
+	for _, status := range n.Keys {
+		var permanent bool
+		switch status {
+		case "301":
+			permanent = true
+		case "302":
+			permanent = false
+		default:
+			return nil, fmt.Errorf("unsupported redirect status %v in redirects yaml", status)
+		}
+
+		group := n.Map[status]
+		for _, from := range group.Keys {
+			to := group.Map[from]
+			if !to.Scalar {
+				return nil, fmt.Errorf("redirect from %v must map to a single path", from)
+			}
+
+			r = append(r, Redirect{From: from, To: to.Value, Permanent: permanent})
+		}
+	}
+
+	return r, nil
+}
+
+// LoadRedirects reads Ghost's redirects file from f. The format is determined
+// by the file's extension - .json is parsed as JSON and anything else is
+// parsed as YAML.
+func LoadRedirects(f string) ([]Redirect, error) {
+	b, err := os.ReadFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load redirects from %v: %w", f, err)
+	}
+
+	if strings.EqualFold(filepath.Ext(f), ".json") {
+		return ParseRedirectsJSON(b)
+	}
+
+	return ParseRedirectsYAML(b)
+}
+
+// LoadAliasMap reads a JSON object from f that maps old paths (or slugs) to
+// the post they now belong to. Values may be a slug, a path or a URL. This is
+// useful for recording slug history that isn't present in Ghost's redirects
+// file. Every entry is treated as a permanent redirect.
+//
+//	{"old-slug": "new-slug", "/2019/01/older-slug/": "/new-slug/"}
+func LoadAliasMap(f string) ([]Redirect, error) {
+	b, err := os.ReadFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load alias map from %v: %w", f, err)
+	}
+
+	var m map[string]string
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse alias map %v: %w", f, err)
+	}
+
+	r := make([]Redirect, 0, len(m))
+	for from, to := range m {
+		if !strings.Contains(to, "/") {
+			to = "/" + to + "/"
+		}
+
+		if !strings.Contains(from, "/") {
+			from = "/" + from + "/"
+		}
+
+		r = append(r, Redirect{From: from, To: to, Permanent: true})
+	}
+
+	sort.Slice(r, func(i, j int) bool { return r[i].From < r[j].From })
+
+	return r, nil
+}
+
+// LoadRedirects reads [Config.RedirectsPath] and [Config.AliasMapPath], if
+// set, and calls [Config.SetRedirects] with their combined contents. This is
+// called automatically by [LoadConfig].
+func (c *Config) LoadRedirects() error {
+	var r []Redirect
+
+	if c.RedirectsPath != "" {
+		rr, err := LoadRedirects(c.RedirectsPath)
+		if err != nil {
+			return err
+		}
+
+		r = append(r, rr...)
+	}
+
+	if c.AliasMapPath != "" {
+		rr, err := LoadAliasMap(c.AliasMapPath)
+		if err != nil {
+			return err
+		}
+
+		r = append(r, rr...)
+	}
+
+	c.SetRedirects(r)
+
+	return nil
+}
+
+// SetRedirects replaces the redirects that are used to build each post's
+// aliases and the rendered redirects file.
+func (c *Config) SetRedirects(r []Redirect) {
+	c.redirects = r
+}
+
+// ghostRegexEscapes is used to unescape characters that are commonly escaped
+// in Ghost's redirect regexes but are literal in a path.
+var ghostRegexEscapes = strings.NewReplacer(`\/`, `/`, `\.`, `.`, `\-`, `-`, `\_`, `_`)
+
+// redirectSource converts a redirect's "from" regex to a literal path if it
+// doesn't use any regex features, returning false otherwise. A trailing "/?"
+// is treated as an optional trailing slash.
+func redirectSource(from string) (string, bool) {
+	from = strings.TrimPrefix(from, "^")
+	from = strings.TrimSuffix(from, "$")
+	if strings.HasSuffix(from, "/?") {
+		from = strings.TrimSuffix(from, "?")
+	}
+
+	if strings.ContainsAny(from, `*+?()[]{}|^$`) {
+		return "", false
+	}
+
+	from = ghostRegexEscapes.Replace(from)
+	if strings.Contains(from, `\`) || !strings.HasPrefix(from, "/") {
+		return "", false
+	}
+
+	return from, true
+}
+
+// normalizeTarget turns a redirect target into a site-relative path with a
+// leading and trailing slash. It returns false if the target points to
+// another site.
+func (c *Config) normalizeTarget(to string) (string, bool) {
+	to = strings.ReplaceAll(to, ghostUrl, "")
+	if c.GhostURL != "" {
+		to = strings.TrimPrefix(to, strings.TrimSuffix(c.GhostURL, "/"))
+	}
+
+	u, err := url.Parse(to)
+	if err != nil || u.Host != "" || u.Scheme != "" {
+		return "", false
+	}
+
+	p := u.Path
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+
+	if !strings.HasSuffix(p, "/") && path.Ext(p) == "" {
+		p += "/"
+	}
+
+	return p, true
+}
+
+// Aliases returns the old paths that redirect to the post, suitable for use
+// in Hugo's "aliases" front matter. Only redirects whose source is a literal
+// path can be used as aliases; everything else is left for
+// [Config.RenderRedirects].
+func (c *Config) Aliases(p GhostPost) []string {
+	var aliases []string
+
+	target := c.PostPath(p)
+	for _, r := range c.redirects {
+		to, ok := c.normalizeTarget(r.To)
+		if !ok || to != target {
+			continue
+		}
+
+		from, ok := redirectSource(r.From)
+		if !ok || from == target {
+			continue
+		}
+
+		aliases = append(aliases, from)
+	}
+
+	return aliases
+}
+
+// splatRegex matches a capture group that can be converted to a splat.
+var splatRegex = regexp.MustCompile(`\(\.[*+]\)$`)
+
+// redirectRule converts a redirect to a Netlify-style _redirects rule,
+// returning false if it can't be expressed as one.
+func (c *Config) redirectRule(r Redirect) (string, bool) {
+	status := 302
+	if r.Permanent {
+		status = 301
+	}
+
+	to := c.rewriteURL(r.To)
+
+	from, ok := redirectSource(r.From)
+	if !ok {
+		// try to convert a trailing capture group, e.g. ^/a/(.*)$ -> /a/*
+		f := strings.TrimSuffix(strings.TrimPrefix(r.From, "^"), "$")
+		if !splatRegex.MatchString(f) || strings.Count(f, "(") != 1 {
+			return "", false
+		}
+
+		from, ok = redirectSource(splatRegex.ReplaceAllString(f, ""))
+		if !ok {
+			return "", false
+		}
+
+		from += "*"
+		to = strings.ReplaceAll(to, "$1", ":splat")
+	}
+
+	if strings.ContainsAny(from, " \t") || strings.ContainsAny(to, " \t") {
+		return "", false
+	}
+
+	return from + " " + to + " " + strconv.Itoa(status), true
+}
+
+// RenderRedirects renders a Netlify-style _redirects file containing every
+// redirect that couldn't be expressed as an alias on one of the provided
+// posts. Redirects that can't be expressed in the _redirects format are
+// included as comments so that they can be handled manually.
+func (c *Config) RenderRedirects(posts []GhostPost) string {
+	aliased := make(map[string]bool)
+	for _, p := range posts {
+		for _, a := range c.Aliases(p) {
+			aliased[a+"\x00"+c.PostPath(p)] = true
+		}
+	}
+
+	var b strings.Builder
+
+	for _, r := range c.redirects {
+		from, ok := redirectSource(r.From)
+		to, toOk := c.normalizeTarget(r.To)
+		if ok && toOk && aliased[from+"\x00"+to] {
+			continue
+		}
+
+		rule, ok := c.redirectRule(r)
+		if !ok {
+			fmt.Fprintf(&b, "# unsupported: %v -> %v\n", r.From, r.To)
+			continue
+		}
+
+		b.WriteString(rule)
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// WriteRedirects writes the output of [Config.RenderRedirects] to
+// [Config.RedirectsOutputPath].
+func (c *Config) WriteRedirects(posts []GhostPost) error {
+	err := os.WriteFile(c.RedirectsOutputPath, []byte(c.RenderRedirects(posts)), 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write redirects to %v: %w", c.RedirectsOutputPath, err)
+	}
+
+	return nil
+}
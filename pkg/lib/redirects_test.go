@@ -0,0 +1,142 @@
+package ghosttohugo_test
+
+import (
+	"database/sql"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+const testRedirectsYAML = `# redirects managed in Ghost admin
+301:
+  /old-slug/: /new-slug/
+  ^\/older-slug\/?$: __GHOST_URL__/new-slug/
+  "/quoted: key/": /new-slug/
+  ^/archive/(.*)$: /blog/$1
+  ^/(foo|bar)/$: /baz/
+
+302:
+  /temporary/: https://elsewhere.example.org/ # external
+`
+
+func TestParseRedirectsYAML(t *testing.T) {
+	t.Parallel()
+
+	got, err := ghosttohugo.ParseRedirectsYAML([]byte(testRedirectsYAML))
+	if err != nil {
+		t.Logf("failed to parse redirects: %v", err.Error())
+		t.FailNow()
+	}
+
+	want := []ghosttohugo.Redirect{
+		{From: "/old-slug/", To: "/new-slug/", Permanent: true},
+		{From: `^\/older-slug\/?$`, To: "__GHOST_URL__/new-slug/", Permanent: true},
+		{From: "/quoted: key/", To: "/new-slug/", Permanent: true},
+		{From: "^/archive/(.*)$", To: "/blog/$1", Permanent: true},
+		{From: "^/(foo|bar)/$", To: "/baz/", Permanent: true},
+		{From: "/temporary/", To: "https://elsewhere.example.org/", Permanent: false},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Logf("got %v, want %v", got, want)
+		t.Fail()
+	}
+
+	bad := []string{
+		"418:\n  /a/: /b/\n",
+		"301:\n  /a/:\n    nested: value\n",
+		"301:\n  /a/: /b/\n    /c/: /d/\n",
+		"301:\n\t/a/: /b/\n",
+	}
+
+	for i, b := range bad {
+		_, err := ghosttohugo.ParseRedirectsYAML([]byte(b))
+		if err == nil {
+			t.Logf("bad test %v: expected an error", i)
+			t.Fail()
+		}
+	}
+}
+
+func TestAliasesAndRedirects(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	redirectsFile := path.Join(dir, "redirects.json")
+	err := os.WriteFile(redirectsFile, []byte(`[
+		{"from": "^/old-slug/$", "to": "/new-slug/", "permanent": true},
+		{"from": "^/archive/(.*)$", "to": "https://example.com/blog/$1", "permanent": false},
+		{"from": "^/(foo|bar)/$", "to": "/baz/", "permanent": true}
+	]`), 0o644)
+	if err != nil {
+		t.Logf("failed to write redirects: %v", err.Error())
+		t.FailNow()
+	}
+
+	aliasFile := path.Join(dir, "aliases.json")
+	err = os.WriteFile(aliasFile, []byte(`{"first-slug": "new-slug", "/gone/": "/missing/"}`), 0o644)
+	if err != nil {
+		t.Logf("failed to write alias map: %v", err.Error())
+		t.FailNow()
+	}
+
+	c := ghosttohugo.Config{
+		GhostURL:         "https://example.com",
+		RedirectsPath:    redirectsFile,
+		AliasMapPath:     aliasFile,
+		LinkReplacements: map[string]string{"https://example.com": "https://nojs.example.com"},
+	}
+	c.ApplyDefaults()
+	c.Process()
+
+	err = c.LoadRedirects()
+	if err != nil {
+		t.Logf("failed to load redirects: %v", err.Error())
+		t.FailNow()
+	}
+
+	post := ghosttohugo.GhostPost{
+		Slug: "new-slug",
+		HTML: sql.NullString{String: "<p>Hi</p>", Valid: true},
+	}
+
+	aliases := c.Aliases(post)
+	want := []string{"/old-slug/", "/first-slug/"}
+	if !reflect.DeepEqual(aliases, want) {
+		t.Logf("got aliases %v, want %v", aliases, want)
+		t.Fail()
+	}
+
+	err = c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	got, err := c.RenderString(post)
+	if err != nil {
+		t.Logf("failed to render: %v", err.Error())
+		t.FailNow()
+	}
+
+	wantFrontMatter := "slug: new-slug\naliases:\n  - \"/old-slug/\"\n  - \"/first-slug/\"\nisPost: true\n"
+	if !strings.Contains(got, wantFrontMatter) {
+		t.Logf("got %v, want it to contain %v", got, wantFrontMatter)
+		t.Fail()
+	}
+
+	redirects := c.RenderRedirects([]ghosttohugo.GhostPost{post})
+	wantRedirects := `/archive/* https://nojs.example.com/blog/:splat 302
+# unsupported: ^/(foo|bar)/$ -> /baz/
+/gone/ /missing/ 301
+`
+	if redirects != wantRedirects {
+		t.Logf("got redirects %v, want %v", redirects, wantRedirects)
+		t.Fail()
+	}
+}
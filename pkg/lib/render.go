@@ -0,0 +1,166 @@
+package ghosttohugo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"sync"
+)
+
+// workers returns the number of posts to render concurrently.
+func (c *Config) workers() int {
+	if c.Workers > 0 {
+		return c.Workers
+	}
+
+	return runtime.GOMAXPROCS(0)
+}
+
+// forEach calls f for every index in [0, n) using up to workers goroutines.
+// Once f returns an error or ctx is cancelled, no further indexes are
+// started. If ctx was cancelled, its error is returned; otherwise the error
+// for the lowest failing index is returned, so that the result doesn't
+// depend on scheduling.
+func forEach(ctx context.Context, workers, n int, f func(i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, n)
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				errs[i] = f(i)
+				if errs[i] != nil {
+					cancel()
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case <-ctx.Done():
+			break feed
+		case indexes <- i:
+		}
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	// only reached if nothing failed, so any cancellation came from the caller
+	return context.Cause(ctx)
+}
+
+// RenderPosts renders and writes posts using up to [Config.Workers]
+// goroutines. The returned files are in the same order as the posts,
+// regardless of which finished first. Rendering stops at the first error or
+// when ctx is cancelled; posts that were already written are left in place.
+//
+// If ContinueOnError is set, failing posts are skipped instead and returned
+// as [PostErrors] along with the files for every post that succeeded, unless
+// more than MaxErrors fail.
+func (c *Config) RenderPosts(ctx context.Context, posts []GhostPost) ([]RenderedFile, error) {
+	return c.renderPosts(ctx, posts, 0)
+}
+
+// renderPosts is [Config.RenderPosts], where prior failures from loading the
+// posts count towards MaxErrors.
+func (c *Config) renderPosts(ctx context.Context, posts []GhostPost, prior int) ([]RenderedFile, error) {
+	files := make([]RenderedFile, len(posts))
+	failed := make([]*PostError, len(posts))
+
+	var mu sync.Mutex
+	var nfailed int
+
+	err := forEach(ctx, c.workers(), len(posts), func(i int) error {
+		p := posts[i]
+
+		_, f, err := c.RenderOne(p)
+		if err != nil {
+			var pe *PostError
+			if errors.As(err, &pe) {
+				c.Report.addFailure(pe)
+			}
+
+			if !c.ContinueOnError || pe == nil {
+				return err
+			}
+
+			failed[i] = pe
+
+			c.postLogger(p).Warn("skipping post that failed to render", slog.String("stage", string(failed[i].Stage)), slog.Any("error", failed[i].Err))
+
+			mu.Lock()
+			nfailed++
+			n := nfailed
+			mu.Unlock()
+
+			if c.tooManyErrors(prior + n) {
+				return ErrTooManyErrors
+			}
+
+			return nil
+		}
+
+		files[i] = RenderedFile{Path: f, Post: p}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrTooManyErrors) {
+		return nil, fmt.Errorf("failed to render posts: %w", err)
+	}
+
+	var errs PostErrors
+	rendered := make([]RenderedFile, 0, len(files))
+
+	for i := range posts {
+		switch {
+		case failed[i] != nil:
+			errs = append(errs, failed[i])
+		case files[i].Path != "":
+			rendered = append(rendered, files[i])
+		}
+	}
+
+	return rendered, c.postErrors(errs, prior)
+}
+
+// renderFailed handles a post that failed to render outside of
+// [Config.RenderPosts]. The failure is reported and, if ContinueOnError is
+// set, added to errs. A non-nil error is returned if rendering should stop:
+// ContinueOnError isn't set, or errs now has more than MaxErrors failures.
+func (c *Config) renderFailed(p GhostPost, err error, errs *PostErrors) error {
+	var pe *PostError
+	if errors.As(err, &pe) {
+		c.Report.addFailure(pe)
+	}
+
+	if !c.ContinueOnError || pe == nil {
+		return err
+	}
+
+	c.postLogger(p).Warn("skipping post that failed to render", slog.String("stage", string(pe.Stage)), slog.Any("error", pe.Err))
+
+	*errs = append(*errs, pe)
+	if c.tooManyErrors(len(*errs)) {
+		return c.postErrors(*errs, 0)
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package ghosttohugo_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+func TestRenderPosts(t *testing.T) {
+	t.Parallel()
+
+	c := ghosttohugo.Config{
+		OutputPath:       t.TempDir(),
+		Workers:          4,
+		ForbidEmptyPosts: true,
+	}
+	c.ApplyDefaults()
+	c.Process()
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	var posts []ghosttohugo.GhostPost
+	for i := 0; i < 50; i++ {
+		posts = append(posts, ghosttohugo.GhostPost{
+			ID:     fmt.Sprint(i),
+			UUID:   fmt.Sprintf("uuid-%v", i),
+			Slug:   fmt.Sprintf("post-%v", i),
+			Status: "published",
+			HTML:   sql.NullString{String: fmt.Sprintf("<p>%v</p>", i), Valid: true},
+		})
+	}
+
+	files, err := c.RenderPosts(context.Background(), posts)
+	if err != nil {
+		t.Logf("failed to render posts: %v", err.Error())
+		t.FailNow()
+	}
+
+	for i, f := range files {
+		if f.Post.ID != posts[i].ID || f.Path != path.Join(c.OutputPath, posts[i].Slug+".md") {
+			t.Logf("file %v out of order: %+v", i, f)
+			t.FailNow()
+		}
+
+		b, err := os.ReadFile(f.Path)
+		if err != nil || !strings.Contains(string(b), fmt.Sprintf("<p>%v</p>", i)) {
+			t.Logf("unexpected contents of %v: %v", f.Path, string(b))
+			t.Fail()
+		}
+	}
+
+	// the error for the first failing post is returned, regardless of which
+	// worker got to it first
+	bad := append([]ghosttohugo.GhostPost(nil), posts...)
+	bad[10].HTML.Valid = false
+	bad[40].HTML.Valid = false
+
+	_, err = c.RenderPosts(context.Background(), bad)
+	if err == nil || !strings.Contains(err.Error(), "uuid-10") {
+		t.Logf("expected an error for uuid-10, got %v", err)
+		t.Fail()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = c.RenderAllContext(ctx, posts)
+	if !errors.Is(err, context.Canceled) {
+		t.Logf("expected context.Canceled, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestProcessGhostPostConcurrent(t *testing.T) {
+	t.Parallel()
+
+	c := ghosttohugo.Config{SetUnpublishedToNow: true}
+
+	const n = 20
+
+	var wg sync.WaitGroup
+	times := make([]time.Time, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			p, err := c.ProcessGhostPost(ghosttohugo.GhostPost{SqlCreatedAt: "2024-01-01 00:00:00"})
+			if err != nil {
+				t.Logf("failed to process post: %v", err.Error())
+				t.Fail()
+			}
+
+			times[i] = p.PublishedAt
+		}(i)
+	}
+
+	wg.Wait()
+
+	// every post gets its own publication time
+	seen := make(map[time.Time]bool, n)
+	for _, pt := range times {
+		if seen[pt] {
+			t.Logf("duplicate publication time %v", pt)
+			t.Fail()
+		}
+
+		seen[pt] = true
+	}
+}
@@ -0,0 +1,360 @@
+package ghosttohugo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SkippedPost is a post that [Config.Select] rejected. Cause, Field and Value
+// are copied from its [Decision], and Reason describes it.
+type SkippedPost struct {
+	ID     string    `json:"id"`
+	Slug   string    `json:"slug"`
+	Cause  SkipCause `json:"cause"`
+	Field  string    `json:"field,omitempty"`
+	Value  string    `json:"value,omitempty"`
+	Reason string    `json:"reason"`
+}
+
+// ReportFailure is a post that failed to load or render.
+type ReportFailure struct {
+	ID    string `json:"id"`
+	Slug  string `json:"slug"`
+	Stage Stage  `json:"stage"`
+	Error string `json:"error"`
+}
+
+// ReportWarning is a problem that was worked around while processing a post's
+// HTML, such as an element that couldn't be decoded.
+type ReportWarning struct {
+	ID      string `json:"id"`
+	Slug    string `json:"slug"`
+	Message string `json:"message"`
+}
+
+// SanitizedPost is a post that [Config.Sanitizer] removed something from.
+// Removed counts what was removed, keyed on descriptions such as
+// "script element" or "onclick attribute".
+type SanitizedPost struct {
+	ID      string         `json:"id"`
+	Slug    string         `json:"slug"`
+	Removed map[string]int `json:"removed"`
+}
+
+// RunReport summarizes an export. Set [Config.Report] to a report from
+// [NewRunReport] and the library fills it in as posts are loaded, selected,
+// rendered and written. It is safe for concurrent use.
+type RunReport struct {
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+
+	// Every post passed to [Config.Select], broken down by field.
+	ByType       map[string]int `json:"byType"`
+	ByStatus     map[string]int `json:"byStatus"`
+	ByVisibility map[string]int `json:"byVisibility"`
+
+	Rendered     int             `json:"rendered"`
+	BytesWritten int64           `json:"bytesWritten"`
+	Skipped      []SkippedPost   `json:"skipped"`
+	Failures     []ReportFailure `json:"failures"`
+	Warnings     []ReportWarning `json:"warnings"`
+	Sanitized    []SanitizedPost `json:"sanitized"`
+
+	// Total time spent in each stage, summed across workers, so it can
+	// exceed the wall-clock time of the run.
+	StageSeconds map[Stage]float64 `json:"stageSeconds"`
+
+	mu sync.Mutex
+}
+
+// NewRunReport returns an empty report that starts now.
+func NewRunReport() *RunReport {
+	return &RunReport{
+		StartedAt:    time.Now(),
+		ByType:       make(map[string]int),
+		ByStatus:     make(map[string]int),
+		ByVisibility: make(map[string]int),
+		Skipped:      []SkippedPost{},
+		Failures:     []ReportFailure{},
+		Warnings:     []ReportWarning{},
+		Sanitized:    []SanitizedPost{},
+		StageSeconds: make(map[Stage]float64),
+	}
+}
+
+// The methods below are all safe to call on a nil report, so that the
+// library doesn't need to check whether reporting is enabled.
+
+func (r *RunReport) addPost(p GhostPost) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.ByType[p.Type]++
+	r.ByStatus[p.Status]++
+	r.ByVisibility[p.Visibility]++
+}
+
+func (r *RunReport) addSkipped(p GhostPost, d Decision) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Skipped = append(r.Skipped, SkippedPost{
+		ID:     p.ID,
+		Slug:   p.Slug,
+		Cause:  d.Cause,
+		Field:  d.Field,
+		Value:  d.Value,
+		Reason: d.String(),
+	})
+}
+
+func (r *RunReport) addRendered(bytes int) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Rendered++
+	r.BytesWritten += int64(bytes)
+}
+
+func (r *RunReport) addFailure(e *PostError) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Failures = append(r.Failures, ReportFailure{ID: e.PostID, Slug: e.Slug, Stage: e.Stage, Error: e.Err.Error()})
+}
+
+func (r *RunReport) addWarning(p GhostPost, msg string) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Warnings = append(r.Warnings, ReportWarning{ID: p.ID, Slug: p.Slug, Message: msg})
+}
+
+func (r *RunReport) addSanitized(p GhostPost, removed map[string]int) {
+	if r == nil || len(removed) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Sanitized = append(r.Sanitized, SanitizedPost{ID: p.ID, Slug: p.Slug, Removed: removed})
+}
+
+// since adds the time since start to the stage's total.
+func (r *RunReport) since(s Stage, start time.Time) {
+	if r == nil {
+		return
+	}
+
+	d := time.Since(start).Seconds()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.StageSeconds[s] += d
+}
+
+// Finish records the end of the run.
+func (r *RunReport) Finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.FinishedAt = time.Now()
+}
+
+// MarshalJSON marshals the report while holding its lock. Failures, warnings
+// and sanitized posts are sorted by post ID, since workers add them in any
+// order.
+func (r *RunReport) MarshalJSON() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sort.SliceStable(r.Failures, func(i, j int) bool { return r.Failures[i].ID < r.Failures[j].ID })
+	sort.SliceStable(r.Warnings, func(i, j int) bool { return r.Warnings[i].ID < r.Warnings[j].ID })
+	sort.SliceStable(r.Sanitized, func(i, j int) bool { return r.Sanitized[i].ID < r.Sanitized[j].ID })
+
+	type report RunReport
+	return json.Marshal((*report)(r))
+}
+
+// writeFileAtomic writes b to a temporary file next to f and renames it into
+// place, so that readers such as the node exporter never see a partial file.
+func writeFileAtomic(f string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(f), "."+filepath.Base(f)+".*")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Chmod(0o644)
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), f)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+
+	return err
+}
+
+// startRun replaces Report with a new one, if there is one, so that each
+// poll of a [Watcher] or export of a [WebhookServer] is reported on its own
+// rather than every run piling up in the same report. It returns the report
+// that was replaced.
+func (c *Config) startRun() *RunReport {
+	prev := c.Report
+	if prev != nil {
+		c.Report = NewRunReport()
+	}
+
+	return prev
+}
+
+// WriteReport finishes Report and writes it to ReportPath as JSON and to
+// MetricsPath in the Prometheus text format, whichever are set. It does
+// nothing if Report is nil.
+func (c *Config) WriteReport() error {
+	r := c.Report
+	if r == nil {
+		return nil
+	}
+
+	r.Finish()
+
+	if c.ReportPath != "" {
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal run report: %w", err)
+		}
+
+		err = os.WriteFile(c.ReportPath, append(b, '\n'), 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to write run report to %v: %w", c.ReportPath, err)
+		}
+	}
+
+	if c.MetricsPath != "" {
+		err := writeFileAtomic(c.MetricsPath, []byte(r.Metrics()))
+		if err != nil {
+			return fmt.Errorf("failed to write metrics to %v: %w", c.MetricsPath, err)
+		}
+	}
+
+	return nil
+}
+
+// metricsPrefix is prepended to the name of every metric.
+const metricsPrefix = "ghost_to_hugo_"
+
+// promLabel escapes a Prometheus label value.
+func promLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// writeMetric writes a metric's help and type lines followed by its samples,
+// which are sorted by label value.
+func writeMetric(b *strings.Builder, name, help, label string, samples map[string]float64) {
+	fmt.Fprintf(b, "# HELP %v%v %v\n# TYPE %v%v gauge\n", metricsPrefix, name, help, metricsPrefix, name)
+
+	keys := make([]string, 0, len(samples))
+	for k := range samples {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := strconv.FormatFloat(samples[k], 'f', -1, 64)
+		if label == "" {
+			fmt.Fprintf(b, "%v%v %v\n", metricsPrefix, name, v)
+		} else {
+			fmt.Fprintf(b, "%v%v{%v=\"%v\"} %v\n", metricsPrefix, name, label, promLabel(k), v)
+		}
+	}
+}
+
+// counts converts a map of counts to samples.
+func counts(m map[string]int) map[string]float64 {
+	s := make(map[string]float64, len(m))
+	for k, v := range m {
+		s[k] = float64(v)
+	}
+
+	return s
+}
+
+// Metrics returns the report in the Prometheus text exposition format, for
+// use with the node exporter's textfile collector.
+func (r *RunReport) Metrics() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var b strings.Builder
+
+	writeMetric(&b, "posts", "Posts in the last run by outcome.", "outcome", map[string]float64{
+		"rendered": float64(r.Rendered),
+		"skipped":  float64(len(r.Skipped)),
+		"failed":   float64(len(r.Failures)),
+	})
+	writeMetric(&b, "posts_by_type", "Posts in the last run by type.", "type", counts(r.ByType))
+	writeMetric(&b, "posts_by_status", "Posts in the last run by status.", "status", counts(r.ByStatus))
+	writeMetric(&b, "posts_by_visibility", "Posts in the last run by visibility.", "visibility", counts(r.ByVisibility))
+	writeMetric(&b, "bytes_written", "Bytes of markdown written in the last run.", "", map[string]float64{"": float64(r.BytesWritten)})
+	writeMetric(&b, "warnings", "HTML processing warnings in the last run.", "", map[string]float64{"": float64(len(r.Warnings))})
+
+	removed := make(map[string]float64)
+	for _, p := range r.Sanitized {
+		for k, v := range p.Removed {
+			removed[k] += float64(v)
+		}
+	}
+
+	writeMetric(&b, "sanitizer_removed", "Elements, attributes and URLs removed by the sanitizer in the last run.", "what", removed)
+
+	stages := make(map[string]float64, len(r.StageSeconds))
+	for s, d := range r.StageSeconds {
+		stages[string(s)] = d
+	}
+
+	writeMetric(&b, "stage_duration_seconds", "Time spent in each stage in the last run, summed across workers.", "stage", stages)
+	writeMetric(&b, "last_run_duration_seconds", "Wall-clock duration of the last run.", "", map[string]float64{"": r.FinishedAt.Sub(r.StartedAt).Seconds()})
+	writeMetric(&b, "last_run_timestamp_seconds", "When the last run finished, as a unix timestamp.", "", map[string]float64{"": float64(r.FinishedAt.Unix())})
+
+	return b.String()
+}
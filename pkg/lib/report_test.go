@@ -0,0 +1,135 @@
+package ghosttohugo_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+func TestRunReport(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	c := ghosttohugo.Config{
+		OutputPath:       dir,
+		ForbidEmptyPosts: true,
+		ContinueOnError:  true,
+		Report:           ghosttohugo.NewRunReport(),
+		ReportPath:       path.Join(dir, "report.json"),
+		MetricsPath:      path.Join(dir, "ghost_to_hugo.prom"),
+		PostVisibilities: map[string]bool{"public": true, "paid": false},
+	}
+	c.ApplyDefaults()
+	c.Process()
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	post := func(id, typ, status, visibility string) ghosttohugo.GhostPost {
+		return ghosttohugo.GhostPost{
+			ID:         id,
+			Slug:       "post-" + id,
+			Type:       typ,
+			Status:     status,
+			Visibility: visibility,
+			HTML:       sql.NullString{String: "<p>" + id + "</p>", Valid: true},
+		}
+	}
+
+	empty := post("4", "post", "published", "public")
+	empty.HTML.Valid = false
+
+	posts := c.Select([]ghosttohugo.GhostPost{
+		post("1", "post", "published", "public"),
+		post("2", "page", "published", "public"),
+		post("3", "post", "draft", "public"),
+		empty,
+		post("5", "post", "published", "paid"),
+	})
+
+	err = c.RenderAll(posts)
+	if err == nil {
+		t.Logf("expected an error for the empty post")
+		t.Fail()
+	}
+
+	b, err := os.ReadFile(c.ReportPath)
+	if err != nil {
+		t.Logf("failed to read report: %v", err.Error())
+		t.FailNow()
+	}
+
+	var r struct {
+		ByType       map[string]int
+		ByStatus     map[string]int
+		ByVisibility map[string]int
+		Rendered     int
+		BytesWritten int64
+		Skipped      []ghosttohugo.SkippedPost
+		Failures     []ghosttohugo.ReportFailure
+		StageSeconds map[string]float64
+	}
+
+	err = json.Unmarshal(b, &r)
+	if err != nil {
+		t.Logf("failed to parse report: %v", err.Error())
+		t.FailNow()
+	}
+
+	if r.ByType["post"] != 4 || r.ByType["page"] != 1 || r.ByStatus["draft"] != 1 || r.ByVisibility["paid"] != 1 {
+		t.Logf("unexpected counts: %+v", r)
+		t.Fail()
+	}
+
+	if r.Rendered != 2 || r.BytesWritten == 0 {
+		t.Logf("unexpected rendered count %v and bytes %v", r.Rendered, r.BytesWritten)
+		t.Fail()
+	}
+
+	if len(r.Skipped) != 2 || r.Skipped[0].ID != "3" || !strings.Contains(r.Skipped[0].Reason, "postStatuses") ||
+		r.Skipped[0].Cause != ghosttohugo.SkipDisabled || r.Skipped[0].Field != ghosttohugo.FieldPostStatuses ||
+		r.Skipped[1].ID != "5" || !strings.Contains(r.Skipped[1].Reason, "postVisibilities") {
+		t.Logf("unexpected skipped posts: %+v", r.Skipped)
+		t.Fail()
+	}
+
+	if len(r.Failures) != 1 || r.Failures[0].ID != "4" || r.Failures[0].Stage != ghosttohugo.StageProcess {
+		t.Logf("unexpected failures: %+v", r.Failures)
+		t.Fail()
+	}
+
+	if _, ok := r.StageSeconds["template"]; !ok {
+		t.Logf("expected template stage duration: %v", r.StageSeconds)
+		t.Fail()
+	}
+
+	m, err := os.ReadFile(c.MetricsPath)
+	if err != nil {
+		t.Logf("failed to read metrics: %v", err.Error())
+		t.FailNow()
+	}
+
+	for _, want := range []string{
+		"# TYPE ghost_to_hugo_posts gauge\n",
+		`ghost_to_hugo_posts{outcome="failed"} 1` + "\n",
+		`ghost_to_hugo_posts{outcome="rendered"} 2` + "\n",
+		`ghost_to_hugo_posts{outcome="skipped"} 2` + "\n",
+		`ghost_to_hugo_posts_by_visibility{visibility="paid"} 1` + "\n",
+		`ghost_to_hugo_stage_duration_seconds{stage="write"} `,
+		"ghost_to_hugo_last_run_timestamp_seconds ",
+	} {
+		if !strings.Contains(string(m), want) {
+			t.Logf("expected metrics to contain %q, got:\n%v", want, string(m))
+			t.Fail()
+		}
+	}
+}
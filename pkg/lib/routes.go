@@ -0,0 +1,371 @@
+package ghosttohugo
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultRoutesRootSection is the Hugo section that posts in the "/"
+// collection are written to if [Config.RoutesRootSection] is not set.
+const DefaultRoutesRootSection = "posts"
+
+// Collection is a single collection from Ghost's routes.yaml.
+type Collection struct {
+	// The collection's path, such as "/podcast/".
+	Path string
+	// Ghost's permalink template, such as "/podcast/{slug}/".
+	Permalink string
+	// Ghost's NQL filter, such as "tag:podcast". An empty filter matches
+	// every post.
+	Filter string
+	// The Hugo section (subdirectory of OutputPath) that posts in this
+	// collection are written to.
+	Section string
+
+	filter nqlFilter
+}
+
+// Routes is the subset of Ghost's routes.yaml that affects where posts live.
+type Routes struct {
+	// Collections in the order that they appear in routes.yaml. A post
+	// belongs to the first collection whose filter matches it.
+	Collections []Collection
+}
+
+// ParseRoutes parses Ghost's routes.yaml. rootSection is the Hugo section
+// used for the "/" collection.
+func ParseRoutes(b []byte, rootSection string) (Routes, error) {
+	var r Routes
+
+	n, err := parseYAML(b)
+	if err != nil {
+		return r, fmt.Errorf("failed to parse routes yaml: %w", err)
+	}
+
+	collections := n.Get("collections")
+	if collections == nil {
+		return r, nil
+	}
+
+	for _, p := range collections.Keys {
+		cn := collections.Map[p]
+
+		col := Collection{
+			Path:      p,
+			Permalink: cn.Get("permalink").String(),
+			Filter:    cn.Get("filter").String(),
+			Section:   strings.ReplaceAll(strings.Trim(p, "/"), "/", "-"),
+		}
+
+		if col.Section == "" {
+			col.Section = rootSection
+		}
+
+		if col.Permalink == "" {
+			return r, fmt.Errorf("collection %v has no permalink", p)
+		}
+
+		col.filter, err = parseNQL(col.Filter)
+		if err != nil {
+			return r, fmt.Errorf("failed to parse filter for collection %v: %w", p, err)
+		}
+
+		r.Collections = append(r.Collections, col)
+	}
+
+	return r, nil
+}
+
+// LoadRoutes reads [Config.RoutesPath], if set. This is called automatically
+// by [LoadConfig].
+func (c *Config) LoadRoutes() error {
+	if c.RoutesPath == "" {
+		return nil
+	}
+
+	b, err := os.ReadFile(c.RoutesPath)
+	if err != nil {
+		return fmt.Errorf("failed to load routes from %v: %w", c.RoutesPath, err)
+	}
+
+	r, err := ParseRoutes(b, c.RoutesRootSection)
+	if err != nil {
+		return err
+	}
+
+	c.SetRoutes(r)
+
+	return nil
+}
+
+// SetRoutes replaces the routes that are used to determine each post's
+// section and URL.
+func (c *Config) SetRoutes(r Routes) {
+	c.routes = &r
+}
+
+// Collection returns the collection that the post belongs to, or nil if no
+// routes are configured, the post is a page, or no collection matches.
+func (c *Config) Collection(p GhostPost) *Collection {
+	if c.routes == nil || p.Type == "page" {
+		return nil
+	}
+
+	for _, col := range c.routes.Collections {
+		if col.filter.matches(p) {
+			return &col
+		}
+	}
+
+	return nil
+}
+
+// section returns the Hugo section that the post is written to, or an empty
+// string if it's written to the root of OutputPath.
+func (c *Config) section(p GhostPost) string {
+	if c.NewsletterMode == NewsletterModeArchive && p.IsNewsletter() {
+		return c.NewsletterSection
+	}
+
+	if col := c.Collection(p); col != nil {
+		return col.Section
+	}
+
+	return ""
+}
+
+// ghostPermalinkTokens maps Ghost's permalink tokens to their Hugo
+// equivalents.
+var ghostPermalinkTokens = strings.NewReplacer(
+	"{slug}", ":slug",
+	"{year}", ":year",
+	"{month}", ":month",
+	"{day}", ":day",
+)
+
+// HugoPermalink converts the collection's Ghost permalink to a Hugo permalink,
+// returning false if it uses tokens (such as {id} or {primary_tag}) that Hugo
+// doesn't support.
+func (col Collection) HugoPermalink() (string, bool) {
+	p := ghostPermalinkTokens.Replace(col.Permalink)
+	if strings.ContainsAny(p, "{}") {
+		return "", false
+	}
+
+	return p, true
+}
+
+// PostPath returns the site-relative path that Ghost serves the post from,
+// such as "/my-post/", taking routes.yaml collections into account.
+func (c *Config) PostPath(p GhostPost) string {
+	col := c.Collection(p)
+	if col == nil {
+		return "/" + p.Slug + "/"
+	}
+
+	primaryTag := ""
+	if t := p.PrimaryTag(); t != nil {
+		primaryTag = t.Slug
+	}
+
+	return strings.NewReplacer(
+		"{id}", p.ID,
+		"{slug}", p.Slug,
+		"{year}", p.PublishedAt.Format("2006"),
+		"{month}", p.PublishedAt.Format("01"),
+		"{day}", p.PublishedAt.Format("02"),
+		"{primary_tag}", primaryTag,
+		"{primary_author}", "",
+	).Replace(col.Permalink)
+}
+
+// postURL returns an explicit URL for the post's front matter if its
+// collection's permalink can't be expressed as a Hugo permalink.
+func (c *Config) postURL(p GhostPost) string {
+	col := c.Collection(p)
+	if col == nil {
+		return ""
+	}
+
+	if _, ok := col.HugoPermalink(); ok {
+		return ""
+	}
+
+	return c.PostPath(p)
+}
+
+// RenderPermalinks renders Hugo's permalinks configuration for every
+// collection whose permalink can be expressed in Hugo, suitable for
+// hugo.toml. Posts in other collections get an explicit "url" in their front
+// matter instead.
+func (c *Config) RenderPermalinks() string {
+	if c.routes == nil {
+		return ""
+	}
+
+	var b strings.Builder
+
+	seen := make(map[string]bool)
+	for _, col := range c.routes.Collections {
+		p, ok := col.HugoPermalink()
+		if !ok || seen[col.Section] {
+			continue
+		}
+
+		if b.Len() == 0 {
+			b.WriteString("[permalinks]\n")
+		}
+
+		seen[col.Section] = true
+		fmt.Fprintf(&b, "  %v = %v\n", tomlString(col.Section), tomlString(p))
+	}
+
+	return b.String()
+}
+
+// nqlFilter is a parsed Ghost NQL filter, in disjunctive normal form: the
+// filter matches if every term in any one group matches.
+type nqlFilter [][]nqlTerm
+
+type nqlTerm struct {
+	key    string
+	values []string
+	negate bool
+}
+
+// parseNQL parses the subset of Ghost's NQL filter syntax that is commonly
+// used in routes.yaml, such as "tag:podcast", "tag:-[a,b]+featured:true" or
+// "primary_tag:news,tag:updates". Supported keys are tag, tags, primary_tag,
+// featured, type, status, visibility, slug and id.
+func parseNQL(s string) (nqlFilter, error) {
+	var f nqlFilter
+
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return f, nil
+	}
+
+	for _, or := range splitNQL(s, ',') {
+		var group []nqlTerm
+		for _, and := range splitNQL(or, '+') {
+			k, v, ok := strings.Cut(strings.TrimSpace(and), ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid filter term %q", and)
+			}
+
+			switch k {
+			case "tags":
+				k = "tag"
+			case "tag", "primary_tag", "featured", "type", "status", "visibility", "slug", "id":
+			default:
+				return nil, fmt.Errorf("unsupported filter key %q", k)
+			}
+
+			t := nqlTerm{key: k}
+			if strings.HasPrefix(v, "-") {
+				t.negate = true
+				v = v[1:]
+			}
+
+			if strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]") {
+				for _, v := range strings.Split(v[1:len(v)-1], ",") {
+					t.values = append(t.values, strings.Trim(strings.TrimSpace(v), `'"`))
+				}
+			} else {
+				t.values = []string{strings.Trim(v, `'"`)}
+			}
+
+			group = append(group, t)
+		}
+
+		f = append(f, group)
+	}
+
+	return f, nil
+}
+
+// splitNQL splits s on sep, ignoring separators inside square brackets.
+func splitNQL(s string, sep byte) []string {
+	var parts []string
+
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// matches returns true if the post satisfies the filter. An empty filter
+// matches everything.
+func (f nqlFilter) matches(p GhostPost) bool {
+	if len(f) == 0 {
+		return true
+	}
+
+	for _, group := range f {
+		ok := true
+		for _, t := range group {
+			if t.matches(p) == t.negate {
+				ok = false
+				break
+			}
+		}
+
+		if ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matches returns true if any of the term's values match the post, ignoring
+// negation.
+func (t nqlTerm) matches(p GhostPost) bool {
+	var have []string
+
+	switch t.key {
+	case "tag":
+		for _, tag := range p.Tags {
+			have = append(have, tag.Slug)
+		}
+	case "primary_tag":
+		if pt := p.PrimaryTag(); pt != nil {
+			have = append(have, pt.Slug)
+		}
+	case "featured":
+		have = append(have, fmt.Sprint(p.Featured))
+	case "type":
+		have = append(have, p.Type)
+	case "status":
+		have = append(have, p.Status)
+	case "visibility":
+		have = append(have, p.Visibility)
+	case "slug":
+		have = append(have, p.Slug)
+	case "id":
+		have = append(have, p.ID)
+	}
+
+	for _, h := range have {
+		for _, v := range t.values {
+			if h == v {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,159 @@
+package ghosttohugo_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+const testRoutesYAML = `routes:
+  /about/team/: team
+
+collections:
+  /podcast/:
+    permalink: /podcast/{slug}/
+    filter: tag:podcast
+    template: podcast
+  /news/:
+    permalink: /news/{primary_tag}/{slug}/
+    filter: "primary_tag:[updates,releases]+featured:true"
+  /:
+    permalink: /{year}/{slug}/
+    filter: tag:-hidden
+    template: index
+
+taxonomies:
+  tag: /tag/{slug}/
+  author: /author/{slug}/
+`
+
+func TestRoutes(t *testing.T) {
+	t.Parallel()
+
+	r, err := ghosttohugo.ParseRoutes([]byte(testRoutesYAML), ghosttohugo.DefaultRoutesRootSection)
+	if err != nil {
+		t.Logf("failed to parse routes: %v", err.Error())
+		t.FailNow()
+	}
+
+	if len(r.Collections) != 3 {
+		t.Logf("got %v collections, want 3", len(r.Collections))
+		t.FailNow()
+	}
+
+	c := ghosttohugo.Config{OutputPath: "/out"}
+	c.SetRoutes(r)
+
+	published := time.Date(2024, time.March, 5, 10, 0, 0, 0, time.UTC)
+
+	podcast := ghosttohugo.GhostTag{Slug: "podcast", Visibility: "public"}
+	updates := ghosttohugo.GhostTag{Slug: "updates", Visibility: "public"}
+	hidden := ghosttohugo.GhostTag{Slug: "hidden", Visibility: "public"}
+	internal := ghosttohugo.GhostTag{Slug: "#internal", Visibility: "internal"}
+
+	tests := []struct {
+		p       ghosttohugo.GhostPost
+		section string
+		path    string
+		file    string
+	}{
+		{
+			ghosttohugo.GhostPost{Slug: "ep-1", Type: "post", Tags: []ghosttohugo.GhostTag{internal, podcast}, PublishedAt: published},
+			"podcast",
+			"/podcast/ep-1/",
+			"/out/podcast/ep-1.md",
+		},
+		{
+			ghosttohugo.GhostPost{Slug: "v2", Type: "post", Featured: true, Tags: []ghosttohugo.GhostTag{internal, updates}, PublishedAt: published},
+			"news",
+			"/news/updates/v2/",
+			"/out/news/v2.md",
+		},
+		{
+			// not featured, so it falls through to the root collection
+			ghosttohugo.GhostPost{Slug: "v3", Type: "post", Tags: []ghosttohugo.GhostTag{updates}, PublishedAt: published},
+			"posts",
+			"/2024/v3/",
+			"/out/posts/v3.md",
+		},
+		{
+			// excluded from every collection
+			ghosttohugo.GhostPost{Slug: "secret", Type: "post", Tags: []ghosttohugo.GhostTag{hidden}, PublishedAt: published},
+			"",
+			"/secret/",
+			"/out/secret.md",
+		},
+		{
+			// pages are never part of a collection
+			ghosttohugo.GhostPost{Slug: "about", Type: "page", Tags: []ghosttohugo.GhostTag{podcast}, PublishedAt: published},
+			"",
+			"/about/",
+			"/out/about.md",
+		},
+	}
+
+	for i, test := range tests {
+		section := ""
+		if col := c.Collection(test.p); col != nil {
+			section = col.Section
+		}
+
+		if section != test.section {
+			t.Logf("test %v failed: got section %v, want %v", i, section, test.section)
+			t.Fail()
+		}
+
+		if got := c.PostPath(test.p); got != test.path {
+			t.Logf("test %v failed: got path %v, want %v", i, got, test.path)
+			t.Fail()
+		}
+
+		if got := c.OutputFile(test.p); got != test.file {
+			t.Logf("test %v failed: got file %v, want %v", i, got, test.file)
+			t.Fail()
+		}
+	}
+
+	want := `[permalinks]
+  "podcast" = "/podcast/:slug/"
+  "posts" = "/:year/:slug/"
+`
+	if got := c.RenderPermalinks(); got != want {
+		t.Logf("got permalinks %v, want %v", got, want)
+		t.Fail()
+	}
+
+	c.ApplyDefaults()
+	err = c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	got, err := c.RenderString(tests[1].p)
+	if err != nil {
+		t.Logf("failed to render: %v", err.Error())
+		t.FailNow()
+	}
+
+	if !strings.Contains(got, "slug: v2\nurl: \"/news/updates/v2/\"\n") {
+		t.Logf("expected an explicit url in front matter, got %v", got)
+		t.Fail()
+	}
+
+	bad := []string{
+		"collections:\n  /:\n    filter: tag:a\n",
+		"collections:\n  /:\n    permalink: /{slug}/\n    filter: author:someone\n",
+		"collections:\n  /:\n    permalink: /{slug}/\n    filter: tag\n",
+	}
+
+	for i, b := range bad {
+		_, err := ghosttohugo.ParseRoutes([]byte(b), "posts")
+		if err == nil {
+			t.Logf("bad test %v: expected an error", i)
+			t.Fail()
+		}
+	}
+}
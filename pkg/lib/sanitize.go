@@ -0,0 +1,511 @@
+package ghosttohugo
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Values for [Config.Sanitizer].
+const (
+	// HTML is not sanitized. This is the default.
+	SanitizerNone = ""
+	// HTML is sanitized with [NoJSPolicy].
+	SanitizerNoJS = "nojs"
+	// HTML is sanitized with [Config.SanitizePolicy].
+	SanitizerCustom = "custom"
+)
+
+// SanitizePolicy is an allowlist of the HTML that survives sanitization, see
+// [Config.SanitizeHTML]. Names are matched case-insensitively.
+type SanitizePolicy struct {
+	// Elements that are kept. Other elements are removed, but their content
+	// is kept unless they're listed in DropContent. "*" keeps every element
+	// that isn't listed in DropContent.
+	Tags []string `json:"tags"`
+	// Elements that are removed along with everything inside them, such as
+	// script and iframe.
+	DropContent []string `json:"dropContent"`
+	// Attributes that are kept on any allowed element. A trailing "*"
+	// matches any suffix, e.g. "data-*", so "*" matches every attribute.
+	// Event handlers such as onclick are only kept if they're listed
+	// explicitly.
+	Attributes []string `json:"attributes"`
+	// URL schemes that are allowed in attributes that hold URLs, such as
+	// href and src. Relative URLs are always allowed.
+	URLSchemes []string `json:"urlSchemes"`
+	// If true, <script type="application/ld+json"> is kept even if script
+	// is in DropContent, since structured data isn't executed.
+	AllowJSONLD bool `json:"allowJsonLd"`
+	// If true, images that are at most 1x1 pixels are removed, since they
+	// are almost always tracking pixels.
+	DropTrackingPixels bool `json:"dropTrackingPixels"`
+}
+
+// urlAttributes are the attributes whose values are checked against
+// [SanitizePolicy.URLSchemes].
+var urlAttributes = map[string]bool{
+	"href":       true,
+	"src":        true,
+	"srcset":     true,
+	"cite":       true,
+	"poster":     true,
+	"action":     true,
+	"formaction": true,
+	"background": true,
+	"xlink:href": true,
+	"data":       true,
+}
+
+// NoJSPolicy returns a strict policy for sites that must not run any
+// JavaScript: scripts, frames, plugins and form controls are removed along with
+// their content, event handler attributes are dropped, only http, https,
+// mailto and tel URLs are allowed, and tracking pixels are removed. Elements
+// such as noscript are unwrapped, so their fallback content is kept. JSON-LD
+// structured data is kept.
+//
+// A new policy is returned on every call, so it is safe to modify.
+func NoJSPolicy() SanitizePolicy {
+	return SanitizePolicy{
+		Tags: []string{
+			"a", "abbr", "address", "article", "aside", "audio", "b", "bdi", "bdo",
+			"blockquote", "br", "caption", "cite", "code", "col", "colgroup", "dd",
+			"del", "details", "dfn", "div", "dl", "dt", "em", "figcaption", "figure",
+			"footer", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hr", "i", "img",
+			"ins", "kbd", "li", "main", "mark", "nav", "ol", "p", "picture", "pre",
+			"q", "rp", "rt", "ruby", "s", "samp", "section", "small", "source",
+			"span", "strong", "style", "sub", "summary", "sup", "table", "tbody",
+			"td", "tfoot", "th", "thead", "time", "tr", "track", "u", "ul", "var",
+			"video", "wbr",
+		},
+		DropContent: []string{
+			"script", "iframe", "frame", "frameset", "object", "embed", "applet",
+			"form", "button", "input", "select", "textarea", "template", "canvas",
+			"svg", "math", "base", "link", "meta",
+		},
+		Attributes: []string{
+			"id", "class", "style", "title", "lang", "dir", "alt", "href", "rel",
+			"target", "src", "srcset", "sizes", "width", "height", "loading",
+			"decoding", "colspan", "rowspan", "scope", "headers", "datetime",
+			"cite", "start", "reversed", "type", "controls", "poster", "preload",
+			"muted", "loop", "playsinline", "media", "kind", "label", "srclang",
+			"open", "data-*", "aria-*", "role",
+		},
+		URLSchemes:         []string{"http", "https", "mailto", "tel"},
+		AllowJSONLD:        true,
+		DropTrackingPixels: true,
+	}
+}
+
+// codeInjectionPolicy is the policy for [Config.CodeInjectionNoScript]. Code
+// injection is mostly made up of elements such as link, meta and style that
+// no post policy would allow, so every element and attribute is kept except
+// scripts, anything that can load a document with its own scripts, event
+// handlers and URLs other than http, https, mailto and tel. JSON-LD
+// structured data is kept.
+func codeInjectionPolicy() SanitizePolicy {
+	return SanitizePolicy{
+		Tags: []string{"*"},
+		DropContent: []string{
+			"script", "iframe", "frame", "frameset", "object", "embed", "applet",
+			"base",
+		},
+		Attributes:  []string{"*"},
+		URLSchemes:  []string{"http", "https", "mailto", "tel"},
+		AllowJSONLD: true,
+	}
+}
+
+// sanitizer is a [SanitizePolicy] prepared for lookups.
+type sanitizer struct {
+	policy      SanitizePolicy
+	tags        map[string]bool
+	dropContent map[string]bool
+	attributes  map[string]bool
+	prefixes    []string
+	schemes     map[string]bool
+}
+
+func newSanitizer(p SanitizePolicy) *sanitizer {
+	set := func(l []string) map[string]bool {
+		m := make(map[string]bool, len(l))
+		for _, v := range l {
+			m[strings.ToLower(v)] = true
+		}
+
+		return m
+	}
+
+	s := &sanitizer{
+		policy:      p,
+		tags:        set(p.Tags),
+		dropContent: set(p.DropContent),
+		attributes:  make(map[string]bool),
+		schemes:     set(p.URLSchemes),
+	}
+
+	for _, a := range p.Attributes {
+		a = strings.ToLower(a)
+		if prefix, ok := strings.CutSuffix(a, "*"); ok {
+			s.prefixes = append(s.prefixes, prefix)
+			continue
+		}
+
+		s.attributes[a] = true
+	}
+
+	return s
+}
+
+// sanitizer returns the sanitizer for [Config.Sanitizer], or nil if HTML
+// isn't sanitized.
+func (c *Config) sanitizer() *sanitizer {
+	switch c.Sanitizer {
+	case SanitizerNoJS:
+		return newSanitizer(NoJSPolicy())
+	case SanitizerCustom:
+		return newSanitizer(c.SanitizePolicy)
+	}
+
+	return nil
+}
+
+// attrName returns the lowercased name of an attribute, including its
+// namespace prefix, if any.
+func attrName(n xml.Name) string {
+	if n.Space != "" {
+		return strings.ToLower(n.Space + ":" + n.Local)
+	}
+
+	return strings.ToLower(n.Local)
+}
+
+func (s *sanitizer) allowAttribute(name string) bool {
+	if s.attributes[name] {
+		return true
+	}
+
+	// event handlers must be listed explicitly
+	if strings.HasPrefix(name, "on") {
+		return false
+	}
+
+	for _, p := range s.prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// urlScheme returns the lowercased scheme of u, or "" if it's relative.
+// Whitespace and control characters are ignored, as they are by browsers, so
+// that "java\tscript:" is still recognized.
+func urlScheme(u string) string {
+	u = strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+
+		return r
+	}, u)
+
+	i := strings.IndexAny(u, ":/?#")
+	if i <= 0 || u[i] != ':' {
+		return ""
+	}
+
+	return strings.ToLower(u[:i])
+}
+
+// allowURL checks the URLs in an attribute's value, which for srcset is a
+// comma-separated list of URLs and descriptors.
+func (s *sanitizer) allowURL(name, value string) (string, bool) {
+	urls := []string{value}
+	if name == "srcset" {
+		urls = urls[:0]
+		for _, c := range strings.Split(value, ",") {
+			if f := strings.Fields(c); len(f) > 0 {
+				urls = append(urls, f[0])
+			}
+		}
+	}
+
+	for _, u := range urls {
+		scheme := urlScheme(u)
+		if scheme != "" && !s.schemes[scheme] {
+			return scheme, false
+		}
+	}
+
+	return "", true
+}
+
+// isTrackingPixel returns true if an image is at most 1x1 pixels.
+func isTrackingPixel(st xml.StartElement) bool {
+	var w, h string
+	for _, a := range st.Attr {
+		switch attrName(a.Name) {
+		case "width":
+			w = a.Value
+		case "height":
+			h = a.Value
+		}
+	}
+
+	tiny := func(v string) bool {
+		v = strings.TrimSuffix(strings.TrimSpace(v), "px")
+		return v == "0" || v == "1"
+	}
+
+	return tiny(w) && tiny(h)
+}
+
+// isJSONLD returns true if st is a JSON-LD script.
+func isJSONLD(st xml.StartElement) bool {
+	for _, a := range st.Attr {
+		if attrName(a.Name) == "type" && strings.EqualFold(strings.TrimSpace(a.Value), "application/ld+json") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sanitize returns st with every attribute that the policy doesn't allow
+// removed, counting each removal in removed.
+func (s *sanitizer) sanitize(st xml.StartElement, removed map[string]int) xml.StartElement {
+	attrs := st.Attr[:0:0]
+	for _, a := range st.Attr {
+		name := attrName(a.Name)
+		if !s.allowAttribute(name) {
+			removed[name+" attribute"]++
+			continue
+		}
+
+		if urlAttributes[name] {
+			if scheme, ok := s.allowURL(name, a.Value); !ok {
+				removed[scheme+": URL"]++
+				continue
+			}
+		}
+
+		attrs = append(attrs, a)
+	}
+
+	st.Attr = attrs
+
+	return st
+}
+
+// SanitizeHTML removes everything from s that [Config.Sanitizer] doesn't
+// allow, returning the sanitized HTML and a count of what was removed, keyed
+// on descriptions such as "script element", "onclick attribute",
+// "javascript: URL" or "tracking pixel". If Sanitizer is not set, s is
+// returned unchanged.
+func (c *Config) SanitizeHTML(s string) (string, map[string]int, error) {
+	san := c.sanitizer()
+	if san == nil {
+		return s, nil, nil
+	}
+
+	return san.sanitizeHTML(s)
+}
+
+func (s *sanitizer) sanitizeHTML(h string) (string, map[string]int, error) {
+	x := xml.NewDecoder(strings.NewReader(h))
+	x.Strict = false
+	x.AutoClose = xml.HTMLAutoClose
+	x.Entity = xml.HTMLEntity
+
+	var o bytes.Buffer
+	xe := xml.NewEncoder(&o)
+
+	removed := make(map[string]int)
+
+	// whether each open element was written, so that only their end tags
+	// are written
+	var open []bool
+	// how deep we are inside an element that's being dropped with its
+	// content
+	skip := 0
+	// the kept style or script element we're inside, whose text is written
+	// as-is so that CSS and JSON aren't escaped, see [escapeRawText]
+	raw := ""
+
+	for {
+		t, err := x.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return "", removed, fmt.Errorf("token parsing error: %w", err)
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			if skip > 0 {
+				skip++
+				continue
+			}
+
+			name := strings.ToLower(t.Name.Local)
+
+			switch {
+			case name == "script" && s.policy.AllowJSONLD && isJSONLD(t):
+			case s.dropContent[name]:
+				removed[name+" element"]++
+				skip = 1
+				continue
+			case name == "img" && s.policy.DropTrackingPixels && isTrackingPixel(t):
+				removed["tracking pixel"]++
+				skip = 1
+				continue
+			case !s.tags[name] && !s.tags["*"]:
+				removed[name+" element"]++
+				open = append(open, false)
+				continue
+			}
+
+			err = xe.EncodeToken(s.sanitize(t, removed))
+			if err != nil {
+				return "", removed, fmt.Errorf("failed to re-encode token: %w", err)
+			}
+
+			open = append(open, true)
+			raw = ""
+			if name == "style" || name == "script" {
+				raw = name
+			}
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+
+			if len(open) == 0 {
+				continue
+			}
+
+			written := open[len(open)-1]
+			open = open[:len(open)-1]
+
+			if !written {
+				continue
+			}
+
+			raw = ""
+
+			err = xe.EncodeToken(t)
+			if err != nil {
+				return "", removed, fmt.Errorf("failed to re-encode token: %w", err)
+			}
+		case xml.CharData:
+			if skip > 0 {
+				continue
+			}
+
+			if raw != "" {
+				err = xe.Flush()
+				if err == nil {
+					_, err = o.WriteString(escapeRawText(raw, string(t)))
+				}
+			} else {
+				err = xe.EncodeToken(t)
+			}
+
+			if err != nil {
+				return "", removed, fmt.Errorf("failed to re-encode token: %w", err)
+			}
+		default:
+			if skip > 0 {
+				continue
+			}
+
+			err = xe.EncodeToken(t)
+			if err != nil {
+				return "", removed, fmt.Errorf("failed to re-encode token: %w", err)
+			}
+		}
+	}
+
+	err := xe.Flush()
+	if err != nil {
+		return "", removed, fmt.Errorf("failed to flush token re-encoder: %w", err)
+	}
+
+	return o.String(), removed, nil
+}
+
+// escapeRawText escapes the text of a style or script element so that it
+// can't close the element early. The text has had its entities decoded, so
+// "&lt;/style&gt;" in the input would otherwise end the element and let
+// whatever follows through unsanitized. In CSS, "<\/" is the same as "</";
+// JSON-LD can only contain "<" in strings, where "\u003c" is the same as "<".
+func escapeRawText(element, s string) string {
+	if element == "script" {
+		return strings.ReplaceAll(s, "<", `\u003c`)
+	}
+
+	return strings.ReplaceAll(s, "</", `<\/`)
+}
+
+// sanitize returns the post's HTML h and its head and foot code injection
+// (see [Config.codeInjection]) after passing them through the sanitizer, and
+// logs and reports what was removed.
+func (c *Config) sanitize(p GhostPost, h string) (string, string, string, error) {
+	head, foot := c.codeInjection(p)
+
+	san := c.sanitizer()
+	if san == nil {
+		return h, head, foot, nil
+	}
+
+	total := make(map[string]int)
+	for _, s := range []*string{&h, &head, &foot} {
+		if *s == "" {
+			continue
+		}
+
+		out, removed, err := san.sanitizeHTML(*s)
+		if err != nil {
+			return "", "", "", err
+		}
+
+		for k, v := range removed {
+			total[k] += v
+		}
+
+		*s = out
+	}
+
+	if len(total) > 0 {
+		c.postLogger(p).Debug("sanitized html", "removed", describeRemoved(total))
+		c.Report.addSanitized(p, total)
+	}
+
+	return h, head, foot, nil
+}
+
+// describeRemoved formats a count of removals for logs, in a stable order.
+func describeRemoved(removed map[string]int) string {
+	keys := make([]string, 0, len(removed))
+	for k := range removed {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%v %v", removed[k], k)
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,206 @@
+package ghosttohugo_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input   string
+		want    string
+		removed map[string]int
+	}{
+		{
+			`<p onclick="steal()" class="x">Hi <a href="javascript:alert(1)">there</a></p>`,
+			`<p class="x">Hi <a>there</a></p>`,
+			map[string]int{"onclick attribute": 1, "javascript: URL": 1},
+		},
+		{
+			`<p>a</p><script>alert(1)</script><p>b</p>`,
+			`<p>a</p><p>b</p>`,
+			map[string]int{"script element": 1},
+		},
+		{
+			`<figure class="kg-embed-card"><iframe src="https://www.youtube.com/embed/x"><p>inside</p></iframe></figure>`,
+			`<figure class="kg-embed-card"></figure>`,
+			map[string]int{"iframe element": 1},
+		},
+		{
+			// noscript is unwrapped, so its fallback is kept
+			`<noscript><img src="/a.png" alt="a"></noscript><p>end</p>`,
+			`<img src="/a.png" alt="a"></img><p>end</p>`,
+			map[string]int{"noscript element": 1},
+		},
+		{
+			`<p>x<img src="https://t.example.com/p.gif" width="1" height="1"></p>`,
+			`<p>x</p>`,
+			map[string]int{"tracking pixel": 1},
+		},
+		{
+			`<a HREF=" Java&#09;Script:alert(1)" href2="x" data-id="1" aria-label="l">x</a>`,
+			`<a data-id="1" aria-label="l">x</a>`,
+			map[string]int{"javascript: URL": 1, "href2 attribute": 1},
+		},
+		{
+			`<img srcset="/a.png 1x, data:image/png;base64,xx 2x" alt="a"><p>end</p>`,
+			`<img alt="a"></img><p>end</p>`,
+			map[string]int{"data: URL": 1},
+		},
+		{
+			`<style>p > a { color: red }</style><script type="application/ld+json">{"@type":"Article"}</script>`,
+			`<style>p > a { color: red }</style><script type="application/ld+json">{"@type":"Article"}</script>`,
+			map[string]int{},
+		},
+		{
+			// decoded entities mustn't be able to close a raw text element
+			`<p>hi</p><style>&lt;/style&gt;&lt;script&gt;alert(1)&lt;/script&gt;&lt;style&gt;</style>`,
+			`<p>hi</p><style><\/style><script>alert(1)<\/script><style></style>`,
+			map[string]int{},
+		},
+		{
+			`<script type="application/ld+json">{"name":"&lt;/script&gt;&lt;script&gt;alert(1)&lt;/script&gt;"}</script>`,
+			`<script type="application/ld+json">{"name":"\u003c/script>\u003cscript>alert(1)\u003c/script>"}</script>`,
+			map[string]int{},
+		},
+		{
+			`<p><a href="mailto:me@example.com">mail</a> <a href="/rel">rel</a></p>`,
+			`<p><a href="mailto:me@example.com">mail</a> <a href="/rel">rel</a></p>`,
+			map[string]int{},
+		},
+	}
+
+	c := ghosttohugo.Config{Sanitizer: ghosttohugo.SanitizerNoJS}
+
+	for i, test := range tests {
+		got, removed, err := c.SanitizeHTML(test.input)
+		if err != nil {
+			t.Logf("test %v failed to sanitize: %v", i, err.Error())
+			t.Fail()
+			continue
+		}
+
+		if got != test.want {
+			t.Logf("test %v failed: got %q, expected %q", i, got, test.want)
+			t.Fail()
+		}
+
+		if len(removed) != len(test.removed) {
+			t.Logf("test %v failed: removed %v, expected %v", i, removed, test.removed)
+			t.Fail()
+			continue
+		}
+
+		for k, v := range test.removed {
+			if removed[k] != v {
+				t.Logf("test %v failed: removed %v, expected %v", i, removed, test.removed)
+				t.Fail()
+				break
+			}
+		}
+	}
+
+	// nothing happens without a sanitizer
+	c = ghosttohugo.Config{}
+	got, removed, err := c.SanitizeHTML(tests[1].input)
+	if err != nil || got != tests[1].input || removed != nil {
+		t.Logf("expected the input to be unchanged, got %q, %v, %v", got, removed, err)
+		t.Fail()
+	}
+}
+
+func TestSanitizeCustomPolicy(t *testing.T) {
+	t.Parallel()
+
+	p := ghosttohugo.NoJSPolicy()
+	p.DropContent = append(p.DropContent, "aside")
+	p.Attributes = append(p.Attributes, "onclick")
+	p.URLSchemes = append(p.URLSchemes, "gemini")
+	p.DropTrackingPixels = false
+
+	c := ghosttohugo.Config{Sanitizer: ghosttohugo.SanitizerCustom, SanitizePolicy: p}
+
+	got, removed, err := c.SanitizeHTML(`<aside>ad</aside><p onclick="x()" onmouseover="y()"><a href="gemini://example.com">g</a><img src="/p.gif" width="1" height="1"></p>`)
+	if err != nil {
+		t.Logf("failed to sanitize: %v", err.Error())
+		t.FailNow()
+	}
+
+	want := `<p onclick="x()"><a href="gemini://example.com">g</a><img src="/p.gif" width="1" height="1"></img></p>`
+	if got != want || len(removed) != 2 || removed["aside element"] != 1 || removed["onmouseover attribute"] != 1 {
+		t.Logf("got %q (removed %v), expected %q", got, removed, want)
+		t.Fail()
+	}
+
+	c.Sanitizer = "strict"
+	if c.Validate() == nil {
+		t.Logf("expected an error for an invalid sanitizer")
+		t.Fail()
+	}
+}
+
+func TestSanitizeRender(t *testing.T) {
+	t.Parallel()
+
+	post := ghosttohugo.GhostPost{
+		ID:                "1",
+		Slug:              "post",
+		HTML:              sql.NullString{String: `<p onclick="x()">Body</p><script>alert(1)</script>`, Valid: true},
+		CodeinjectionHead: sql.NullString{String: `<script src="x.js"></script><style>p { color: red }</style>`, Valid: true},
+	}
+
+	c := ghosttohugo.Config{
+		Sanitizer:     ghosttohugo.SanitizerNoJS,
+		CodeInjection: ghosttohugo.CodeInjectionParams,
+		Report:        ghosttohugo.NewRunReport(),
+	}
+	c.ApplyDefaults()
+	c.Process()
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	got, err := c.RenderString(post)
+	if err != nil {
+		t.Logf("failed to render: %v", err.Error())
+		t.FailNow()
+	}
+
+	for _, notWant := range []string{"onclick", "alert", "x.js"} {
+		if strings.Contains(got, notWant) {
+			t.Logf("didn't expect %q in:\n%v", notWant, got)
+			t.Fail()
+		}
+	}
+
+	if !strings.Contains(got, `codeinjectionHead: "<style>p { color: red }</style>"`) {
+		t.Logf("expected the style to be kept in:\n%v", got)
+		t.Fail()
+	}
+
+	b, err := json.Marshal(c.Report)
+	if err != nil {
+		t.Logf("failed to marshal report: %v", err.Error())
+		t.FailNow()
+	}
+
+	want := `"sanitized":[{"id":"1","slug":"post","removed":{"onclick attribute":1,"script element":2}}]`
+	if !strings.Contains(string(b), want) {
+		t.Logf("expected %v in report: %v", want, string(b))
+		t.Fail()
+	}
+
+	if m := c.Report.Metrics(); !strings.Contains(m, `ghost_to_hugo_sanitizer_removed{what="script element"} 2`) {
+		t.Logf("expected sanitizer metrics in:\n%v", m)
+		t.Fail()
+	}
+}
@@ -0,0 +1,147 @@
+package ghosttohugo
+
+import "fmt"
+
+// Values for [Config.UnknownValues].
+const (
+	// Posts whose type, status or visibility isn't in PostTypes,
+	// PostStatuses or PostVisibilities are allowed. This is the default.
+	UnknownValuesAllow = "allow"
+	// Posts whose type, status or visibility isn't in PostTypes,
+	// PostStatuses or PostVisibilities are rejected, so that only values
+	// that are explicitly mapped to true are exported.
+	UnknownValuesReject = "reject"
+)
+
+// SkipCause is the reason that [Config.Decide] rejected a post.
+type SkipCause string
+
+const (
+	// The post was not rejected.
+	SkipNone SkipCause = ""
+	// PostTypes, PostStatuses or PostVisibilities is empty, so every post
+	// is rejected.
+	SkipNoFilters SkipCause = "no-filters"
+	// The post is an email-only newsletter and NewsletterMode is "exclude".
+	SkipNewsletter SkipCause = "newsletter"
+	// The post's value is explicitly mapped to false.
+	SkipDisabled SkipCause = "disabled"
+	// The post's value isn't in the map and UnknownValues is "reject".
+	SkipUnknown SkipCause = "unknown"
+)
+
+// The config fields that [Config.Decide] checks, as they appear in the JSON
+// config.
+const (
+	FieldPostTypes        = "postTypes"
+	FieldPostStatuses     = "postStatuses"
+	FieldPostVisibilities = "postVisibilities"
+	FieldNewsletterMode   = "newsletterMode"
+)
+
+// Decision explains whether [Config.Decide] accepted a post, and if not, which
+// rule rejected it.
+type Decision struct {
+	Valid bool      `json:"valid"`
+	Cause SkipCause `json:"cause,omitempty"`
+	// The config field that rejected the post, such as "postStatuses".
+	Field string `json:"field,omitempty"`
+	// The post's value that was rejected, such as "draft".
+	Value string `json:"value,omitempty"`
+}
+
+// decisionNouns names the post field that each map in [Config] is keyed by.
+var decisionNouns = map[string]string{
+	FieldPostTypes:        "type",
+	FieldPostStatuses:     "status",
+	FieldPostVisibilities: "visibility",
+}
+
+// String describes the decision in a form suitable for logs, such as
+// `status "draft" is disabled in postStatuses`.
+func (d Decision) String() string {
+	switch d.Cause {
+	case SkipNone:
+		return "valid"
+	case SkipNoFilters:
+		return "postTypes, postStatuses or postVisibilities is empty"
+	case SkipNewsletter:
+		return "email-only newsletters are excluded by newsletterMode"
+	case SkipDisabled:
+		return fmt.Sprintf("%v %q is disabled in %v", decisionNouns[d.Field], d.Value, d.Field)
+	case SkipUnknown:
+		return fmt.Sprintf("%v %q is not in %v and unknownValues is %q", decisionNouns[d.Field], d.Value, d.Field, UnknownValuesReject)
+	}
+
+	return string(d.Cause)
+}
+
+// checkValue decides whether value is allowed by the map m, which is the
+// config field named field.
+func (c *Config) checkValue(field string, m map[string]bool, value string) Decision {
+	v, ok := m[value]
+	switch {
+	case ok && !v:
+		return Decision{Cause: SkipDisabled, Field: field, Value: value}
+	case !ok && c.UnknownValues == UnknownValuesReject:
+		return Decision{Cause: SkipUnknown, Field: field, Value: value}
+	}
+
+	return Decision{Valid: true}
+}
+
+// Decide is [Config.IsValid], but returns which rule rejected the post
+// instead of a bare bool. Types are checked before statuses, and statuses
+// before visibilities; only the first rule that rejects the post is
+// reported.
+func (c *Config) Decide(p GhostPost) Decision {
+	if len(c.PostTypes) == 0 || len(c.PostStatuses) == 0 ||
+		len(c.PostVisibilities) == 0 {
+		return Decision{Cause: SkipNoFilters}
+	}
+
+	checkStatus := true
+	if p.IsNewsletter() {
+		switch c.NewsletterMode {
+		case NewsletterModeExclude:
+			return Decision{Cause: SkipNewsletter, Field: FieldNewsletterMode, Value: c.NewsletterMode}
+		case NewsletterModeArchive:
+			checkStatus = false
+		}
+	}
+
+	d := c.checkValue(FieldPostTypes, c.PostTypes, p.Type)
+	if !d.Valid {
+		return d
+	}
+
+	if checkStatus {
+		d = c.checkValue(FieldPostStatuses, c.PostStatuses, p.Status)
+		if !d.Valid {
+			return d
+		}
+	}
+
+	return c.checkValue(FieldPostVisibilities, c.PostVisibilities, p.Visibility)
+}
+
+// Select returns the posts that [Config.IsValid] accepts. If Report is set,
+// every post is counted and each rejected post is recorded along with the
+// reason it was rejected.
+func (c *Config) Select(posts []GhostPost) []GhostPost {
+	var s []GhostPost
+	for _, p := range posts {
+		c.Report.addPost(p)
+
+		d := c.Decide(p)
+		if !d.Valid {
+			c.Report.addSkipped(p, d)
+			c.postLogger(p).Debug("skipping post", "reason", d.String())
+			continue
+		}
+
+		s = append(s, p)
+	}
+
+	return s
+}
@@ -0,0 +1,134 @@
+package ghosttohugo_test
+
+import (
+	"database/sql"
+	"testing"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+func TestDecide(t *testing.T) {
+	t.Parallel()
+
+	c := ghosttohugo.Config{}
+	c.ApplyDefaults()
+
+	reject := c
+	reject.UnknownValues = ghosttohugo.UnknownValuesReject
+
+	exclude := c
+	exclude.NewsletterMode = ghosttohugo.NewsletterModeExclude
+
+	archive := reject
+	archive.NewsletterMode = ghosttohugo.NewsletterModeArchive
+
+	post := ghosttohugo.GhostPost{Type: "post", Status: "published", Visibility: "public"}
+
+	with := func(f func(p *ghosttohugo.GhostPost)) ghosttohugo.GhostPost {
+		p := post
+		f(&p)
+		return p
+	}
+
+	newsletter := with(func(p *ghosttohugo.GhostPost) {
+		p.Status = ghosttohugo.GhostPostStatusSent
+		p.NewsletterId = sql.NullString{String: "n1", Valid: true}
+	})
+
+	tests := []struct {
+		c      ghosttohugo.Config
+		p      ghosttohugo.GhostPost
+		want   ghosttohugo.Decision
+		reason string
+	}{
+		{c, post, ghosttohugo.Decision{Valid: true}, "valid"},
+		{
+			ghosttohugo.Config{},
+			post,
+			ghosttohugo.Decision{Cause: ghosttohugo.SkipNoFilters},
+			"postTypes, postStatuses or postVisibilities is empty",
+		},
+		{
+			c,
+			with(func(p *ghosttohugo.GhostPost) { p.Status = "draft" }),
+			ghosttohugo.Decision{Cause: ghosttohugo.SkipDisabled, Field: ghosttohugo.FieldPostStatuses, Value: "draft"},
+			`status "draft" is disabled in postStatuses`,
+		},
+		{
+			// unknown values are allowed by default
+			c,
+			with(func(p *ghosttohugo.GhostPost) { p.Visibility = "paid" }),
+			ghosttohugo.Decision{Valid: true},
+			"valid",
+		},
+		{
+			reject,
+			with(func(p *ghosttohugo.GhostPost) { p.Visibility = "paid" }),
+			ghosttohugo.Decision{Cause: ghosttohugo.SkipUnknown, Field: ghosttohugo.FieldPostVisibilities, Value: "paid"},
+			`visibility "paid" is not in postVisibilities and unknownValues is "reject"`,
+		},
+		{
+			// types are checked first
+			reject,
+			with(func(p *ghosttohugo.GhostPost) { p.Type = "email"; p.Status = "draft" }),
+			ghosttohugo.Decision{Cause: ghosttohugo.SkipUnknown, Field: ghosttohugo.FieldPostTypes, Value: "email"},
+			`type "email" is not in postTypes and unknownValues is "reject"`,
+		},
+		{
+			exclude,
+			newsletter,
+			ghosttohugo.Decision{Cause: ghosttohugo.SkipNewsletter, Field: ghosttohugo.FieldNewsletterMode, Value: ghosttohugo.NewsletterModeExclude},
+			"email-only newsletters are excluded by newsletterMode",
+		},
+		{
+			// archived newsletters skip the status check, so "sent" being
+			// unknown doesn't matter
+			archive,
+			newsletter,
+			ghosttohugo.Decision{Valid: true},
+			"valid",
+		},
+		{
+			reject,
+			newsletter,
+			ghosttohugo.Decision{Cause: ghosttohugo.SkipUnknown, Field: ghosttohugo.FieldPostStatuses, Value: "sent"},
+			`status "sent" is not in postStatuses and unknownValues is "reject"`,
+		},
+	}
+
+	for i, test := range tests {
+		got := test.c.Decide(test.p)
+		if got != test.want {
+			t.Logf("test %v failed: got %+v, want %+v", i, got, test.want)
+			t.Fail()
+		}
+
+		if got.String() != test.reason {
+			t.Logf("test %v failed: got reason %q, want %q", i, got.String(), test.reason)
+			t.Fail()
+		}
+
+		if test.c.IsValid(test.p) != got.Valid {
+			t.Logf("test %v failed: IsValid disagrees with Decide", i)
+			t.Fail()
+		}
+	}
+}
+
+func TestValidateUnknownValues(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []string{"", ghosttohugo.UnknownValuesAllow, ghosttohugo.UnknownValuesReject} {
+		c := ghosttohugo.Config{UnknownValues: v}
+		if err := c.Validate(); err != nil {
+			t.Logf("unexpected error for %q: %v", v, err.Error())
+			t.Fail()
+		}
+	}
+
+	c := ghosttohugo.Config{UnknownValues: "deny"}
+	if err := c.Validate(); err == nil {
+		t.Logf("expected an error for an invalid unknownValues")
+		t.Fail()
+	}
+}
@@ -0,0 +1,298 @@
+package ghosttohugo
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// ErrSiteConfigExists is returned by [Config.WriteSiteConfig] instead of
+// overwriting a file that it didn't write itself.
+var ErrSiteConfigExists = errors.New("site config file was not generated by ghost-to-hugo")
+
+// siteConfigHeader starts every file written by [Config.WriteSiteConfig], and
+// marks it as safe to overwrite.
+const siteConfigHeader = "# Generated by ghost-to-hugo from Ghost's settings. Changes will be overwritten.\n"
+
+// Setting is a single key/value row from Ghost's settings table.
+type Setting struct {
+	Key   string
+	Value sql.NullString
+}
+
+// All of the fields that map to [Setting]. The key column is quoted because
+// "key" is a reserved word in mysql.
+const QUERY_SETTINGS_FIELDS = "`key` as SettingKey, value as Value"
+
+// GetSetting parses an SQL row-yielding iterator and returns a [Setting] from
+// it. The query should select [QUERY_SETTINGS_FIELDS] from the settings table.
+func GetSetting(rows *sql.Rows) (Setting, error) {
+	var s Setting
+
+	err := rows.Scan(&s.Key, &s.Value)
+	if err != nil {
+		return s, fmt.Errorf("failed to marshal row into setting: %w", err)
+	}
+
+	return s, nil
+}
+
+// NavigationItem is a single entry from Ghost's navigation or
+// secondary_navigation settings.
+type NavigationItem struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// SiteSettings holds the subset of Ghost's settings that are relevant to a
+// Hugo site's configuration.
+type SiteSettings struct {
+	Title               string
+	Description         string
+	Logo                string
+	Icon                string
+	CoverImage          string
+	AccentColor         string
+	Timezone            string
+	Locale              string
+	Facebook            string
+	Twitter             string
+	Navigation          []NavigationItem
+	SecondaryNavigation []NavigationItem
+}
+
+// ParseSiteSettings converts the rows read from Ghost's settings table into
+// [SiteSettings]. Unknown keys are ignored.
+func ParseSiteSettings(settings []Setting) (SiteSettings, error) {
+	var s SiteSettings
+
+	for _, setting := range settings {
+		if !setting.Value.Valid {
+			continue
+		}
+
+		v := setting.Value.String
+
+		switch setting.Key {
+		case "title":
+			s.Title = v
+		case "description":
+			s.Description = v
+		case "logo":
+			s.Logo = v
+		case "icon":
+			s.Icon = v
+		case "cover_image":
+			s.CoverImage = v
+		case "accent_color":
+			s.AccentColor = v
+		case "timezone":
+			s.Timezone = v
+		case "locale":
+			s.Locale = v
+		case "facebook":
+			s.Facebook = v
+		case "twitter":
+			s.Twitter = v
+		case "navigation":
+			err := json.Unmarshal([]byte(v), &s.Navigation)
+			if err != nil {
+				return s, fmt.Errorf("failed to parse navigation setting: %w", err)
+			}
+		case "secondary_navigation":
+			err := json.Unmarshal([]byte(v), &s.SecondaryNavigation)
+			if err != nil {
+				return s, fmt.Errorf("failed to parse secondary_navigation setting: %w", err)
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// rewriteURL replaces __GHOST_URL__ with the configured GhostURL and applies
+// link replacements, if enabled.
+func (c *Config) rewriteURL(u string) string {
+	u = strings.ReplaceAll(u, ghostUrl, c.GhostURL)
+	if c.ReplaceLinks {
+		u = c.replaceInLink(u)
+	}
+
+	return u
+}
+
+// tomlString quotes s as a TOML basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+// writeTOMLKeys writes each non-empty key/value pair, in order.
+func writeTOMLKeys(b *strings.Builder, indent string, kv [][2]string) {
+	for _, kv := range kv {
+		if kv[1] == "" {
+			continue
+		}
+
+		fmt.Fprintf(b, "%v%v = %v\n", indent, kv[0], tomlString(kv[1]))
+	}
+}
+
+// RenderSiteConfig renders a Hugo configuration fragment, suitable for
+// config/_default/hugo.toml, from Ghost's site settings.
+func (c *Config) RenderSiteConfig(s SiteSettings) string {
+	var b strings.Builder
+
+	writeTOMLKeys(&b, "", [][2]string{
+		{"title", s.Title},
+		{"languageCode", s.Locale},
+		{"timeZone", s.Timezone},
+	})
+
+	if permalinks := c.RenderPermalinks(); permalinks != "" {
+		b.WriteString("\n")
+		b.WriteString(permalinks)
+	}
+
+	return b.String()
+}
+
+// RenderSiteParams renders Hugo site params, suitable for
+// config/_default/params.toml, from Ghost's site settings. All URLs are
+// passed through GhostURL and link replacements.
+func (c *Config) RenderSiteParams(s SiteSettings) string {
+	var b strings.Builder
+
+	writeTOMLKeys(&b, "", [][2]string{
+		{"description", s.Description},
+		{"logo", c.rewriteURL(s.Logo)},
+		{"icon", c.rewriteURL(s.Icon)},
+		{"coverImage", c.rewriteURL(s.CoverImage)},
+		{"accentColor", s.AccentColor},
+	})
+
+	if s.Facebook != "" || s.Twitter != "" {
+		b.WriteString("\n[social]\n")
+		writeTOMLKeys(&b, "  ", [][2]string{
+			{"facebook", s.Facebook},
+			{"twitter", s.Twitter},
+		})
+	}
+
+	return b.String()
+}
+
+// Hugo menus that Ghost's navigation settings are rendered to.
+const (
+	SiteMenuPrimary   = "main"
+	SiteMenuSecondary = "footer"
+)
+
+// RenderMenus renders Hugo menu entries, suitable for
+// config/_default/menus.toml, from Ghost's primary and secondary navigation.
+// Ghost's primary navigation becomes the "main" menu, and its secondary
+// navigation becomes the "footer" menu.
+func (c *Config) RenderMenus(s SiteSettings) string {
+	var b strings.Builder
+
+	menus := []struct {
+		name  string
+		items []NavigationItem
+	}{
+		{SiteMenuPrimary, s.Navigation},
+		{SiteMenuSecondary, s.SecondaryNavigation},
+	}
+
+	for _, menu := range menus {
+		for i, item := range menu.items {
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+
+			fmt.Fprintf(&b, "[[%v]]\n", menu.name)
+			writeTOMLKeys(&b, "  ", [][2]string{
+				{"name", item.Label},
+				{"url", c.rewriteURL(item.URL)},
+			})
+			fmt.Fprintf(&b, "  weight = %v\n", (i+1)*10)
+		}
+	}
+
+	return b.String()
+}
+
+// WriteSiteConfig writes hugo.toml, params.toml and menus.toml to dir, such
+// as a Hugo environment directory like config/production, whose settings
+// Hugo merges over config/_default. Each file starts with a comment that
+// marks it as generated; if any of the files already exists without it,
+// nothing is written and [ErrSiteConfigExists] is returned, so that a
+// hand-written config is never overwritten.
+func (c *Config) WriteSiteConfig(s SiteSettings, dir string) error {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to make site config dir %v: %w", dir, err)
+	}
+
+	files := map[string]string{
+		"hugo.toml":   c.RenderSiteConfig(s),
+		"params.toml": c.RenderSiteParams(s),
+		"menus.toml":  c.RenderMenus(s),
+	}
+
+	for name := range files {
+		f := path.Join(dir, name)
+
+		b, err := os.ReadFile(f)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to read site config %v: %w", f, err)
+		}
+
+		if !bytes.HasPrefix(b, []byte(siteConfigHeader)) {
+			return fmt.Errorf("%w: refusing to overwrite %v", ErrSiteConfigExists, f)
+		}
+	}
+
+	for name, contents := range files {
+		f := path.Join(dir, name)
+		err = os.WriteFile(f, []byte(siteConfigHeader+contents), 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to write site config %v: %w", f, err)
+		}
+	}
+
+	return nil
+}
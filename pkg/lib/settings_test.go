@@ -0,0 +1,144 @@
+package ghosttohugo_test
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+func TestSiteSettings(t *testing.T) {
+	t.Parallel()
+
+	settings := []ghosttohugo.Setting{
+		{Key: "title", Value: sql.NullString{String: `My "Blog"`, Valid: true}},
+		{Key: "description", Value: sql.NullString{String: "Thoughts", Valid: true}},
+		{Key: "logo", Value: sql.NullString{String: "__GHOST_URL__/content/images/logo.png", Valid: true}},
+		{Key: "icon", Value: sql.NullString{Valid: false}},
+		{Key: "accent_color", Value: sql.NullString{String: "#ff1a75", Valid: true}},
+		{Key: "timezone", Value: sql.NullString{String: "Etc/UTC", Valid: true}},
+		{Key: "locale", Value: sql.NullString{String: "en", Valid: true}},
+		{Key: "twitter", Value: sql.NullString{String: "@example", Valid: true}},
+		{Key: "navigation", Value: sql.NullString{String: `[{"label":"Home","url":"__GHOST_URL__/"},{"label":"About","url":"https://example.com/about/"}]`, Valid: true}},
+		{Key: "secondary_navigation", Value: sql.NullString{String: `[{"label":"Sign up","url":"#/portal/"}]`, Valid: true}},
+		{Key: "members_signup_access", Value: sql.NullString{String: "all", Valid: true}},
+	}
+
+	s, err := ghosttohugo.ParseSiteSettings(settings)
+	if err != nil {
+		t.Logf("failed to parse settings: %v", err.Error())
+		t.FailNow()
+	}
+
+	c := ghosttohugo.Config{
+		GhostURL:         "https://example.com",
+		LinkReplacements: map[string]string{"https://example.com": "https://nojs.example.com"},
+	}
+	c.Process()
+
+	tests := []struct {
+		got  string
+		want string
+	}{
+		{
+			c.RenderSiteConfig(s),
+			`title = "My \"Blog\""
+languageCode = "en"
+timeZone = "Etc/UTC"
+`,
+		},
+		{
+			c.RenderSiteParams(s),
+			`description = "Thoughts"
+logo = "https://nojs.example.com/content/images/logo.png"
+accentColor = "#ff1a75"
+
+[social]
+  twitter = "@example"
+`,
+		},
+		{
+			c.RenderMenus(s),
+			`[[main]]
+  name = "Home"
+  url = "https://nojs.example.com/"
+  weight = 10
+
+[[main]]
+  name = "About"
+  url = "https://nojs.example.com/about/"
+  weight = 20
+
+[[footer]]
+  name = "Sign up"
+  url = "#/portal/"
+  weight = 10
+`,
+		},
+	}
+
+	for i, test := range tests {
+		if test.got != test.want {
+			t.Logf("test %v failed: got %v, want %v", i, test.got, test.want)
+			t.Fail()
+		}
+	}
+
+	_, err = ghosttohugo.ParseSiteSettings([]ghosttohugo.Setting{
+		{Key: "navigation", Value: sql.NullString{String: "{", Valid: true}},
+	})
+	if err == nil {
+		t.Log("expected an error for invalid navigation json")
+		t.Fail()
+	}
+
+	dir := path.Join(t.TempDir(), "config", "_default")
+	err = c.WriteSiteConfig(s, dir)
+	if err != nil {
+		t.Logf("failed to write site config: %v", err.Error())
+		t.FailNow()
+	}
+
+	for _, f := range []string{"hugo.toml", "params.toml", "menus.toml"} {
+		_, err := os.Stat(path.Join(dir, f))
+		if err != nil {
+			t.Logf("expected %v to exist: %v", f, err.Error())
+			t.Fail()
+		}
+	}
+
+	// generated files are overwritten
+	err = c.WriteSiteConfig(s, dir)
+	if err != nil {
+		t.Logf("failed to rewrite site config: %v", err.Error())
+		t.Fail()
+	}
+
+	// but hand-written ones aren't, and nothing else is written either
+	dir = t.TempDir()
+	err = os.WriteFile(path.Join(dir, "params.toml"), []byte("author = \"me\"\n"), 0o644)
+	if err != nil {
+		t.Logf("failed to write params: %v", err.Error())
+		t.FailNow()
+	}
+
+	err = c.WriteSiteConfig(s, dir)
+	if !errors.Is(err, ghosttohugo.ErrSiteConfigExists) {
+		t.Logf("expected ErrSiteConfigExists, got %v", err)
+		t.Fail()
+	}
+
+	b, _ := os.ReadFile(path.Join(dir, "params.toml"))
+	if string(b) != "author = \"me\"\n" {
+		t.Logf("hand-written params were overwritten: %v", string(b))
+		t.Fail()
+	}
+
+	if exists(path.Join(dir, "hugo.toml")) {
+		t.Logf("expected hugo.toml not to be written")
+		t.Fail()
+	}
+}
@@ -0,0 +1,66 @@
+package ghosttohugo
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// GhostTag is a row from Ghost's tags table.
+type GhostTag struct {
+	ID         string
+	Name       string
+	Slug       string
+	Visibility string
+}
+
+// QUERY_POST_TAGS selects every post's tags, ordered so that each post's tags
+// are in the order that they were assigned in Ghost. The rows should be
+// parsed with [GetPostTag].
+const QUERY_POST_TAGS = `
+SELECT
+posts_tags.post_id as PostID,
+tags.id as ID,
+tags.name as Name,
+tags.slug as Slug,
+tags.visibility as Visibility
+FROM posts_tags
+INNER JOIN tags ON tags.id = posts_tags.tag_id
+ORDER BY posts_tags.post_id, posts_tags.sort_order
+`
+
+// GetPostTag parses an SQL row-yielding iterator from [QUERY_POST_TAGS] and
+// returns the post ID and the tag from it.
+//
+// Usage:
+//
+//	tags := make(map[string][]ghosttohugo.GhostTag)
+//	for rows.Next() {
+//		postID, tag, err := ghosttohugo.GetPostTag(rows)
+//		// ...
+//		tags[postID] = append(tags[postID], tag)
+//	}
+//	// ...
+//	post.Tags = tags[post.ID]
+func GetPostTag(rows *sql.Rows) (string, GhostTag, error) {
+	var postID string
+	var t GhostTag
+
+	err := rows.Scan(&postID, &t.ID, &t.Name, &t.Slug, &t.Visibility)
+	if err != nil {
+		return postID, t, fmt.Errorf("failed to marshal row into tag: %w", err)
+	}
+
+	return postID, t, nil
+}
+
+// PrimaryTag returns the post's first public tag, which is what Ghost
+// considers the primary tag, or nil if it doesn't have one.
+func (p GhostPost) PrimaryTag() *GhostTag {
+	for _, t := range p.Tags {
+		if t.Visibility == "" || t.Visibility == GhostPostVisibilityPublic {
+			return &t
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package ghosttohugo
+
+import (
+	"io/fs"
+	"os"
+	"text/template"
+)
+
+// Template files are looked up in [Config.TemplateDir] by these names, in
+// order, and the first one that exists is used to render a post:
+//
+//  1. <custom_template>.tmpl, if the post has a custom template set in
+//     Ghost, such as custom-wide.tmpl
+//  2. tag-<slug>.tmpl, for the slug of the post's primary tag
+//  3. <type>.tmpl, such as post.tmpl or page.tmpl
+//  4. default.tmpl
+//
+// If none of them exist, [Config.Template] is used.
+const (
+	TemplateExt         = ".tmpl"
+	TemplateTagPrefix   = "tag-"
+	DefaultTemplateFile = "default" + TemplateExt
+)
+
+// templatePatterns are the files in TemplateDir that are parsed. Files in
+// partials/ are parsed by their base name like every other file, so a partial
+// at partials/header.tmpl is used with {{ template "header.tmpl" . }}.
+var templatePatterns = []string{"*" + TemplateExt, "partials/*" + TemplateExt}
+
+// templateFS returns TemplateFS, or TemplateDir as a file system, or nil if
+// neither is set.
+func (c *Config) templateFS() fs.FS {
+	if c.TemplateFS != nil {
+		return c.TemplateFS
+	}
+
+	if c.TemplateDir != "" {
+		return os.DirFS(c.TemplateDir)
+	}
+
+	return nil
+}
+
+// parseTemplateFiles adds every template file in fsys to t.
+func parseTemplateFiles(t *template.Template, fsys fs.FS) error {
+	for _, pattern := range templatePatterns {
+		// ParseFS fails if a pattern doesn't match anything, but partials
+		// are optional
+		m, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return err
+		}
+
+		if len(m) == 0 {
+			continue
+		}
+
+		_, err = t.ParseFS(fsys, pattern)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// TemplateName returns the name of the template that renders p: one of the
+// files in TemplateDir, or [Config.Template] if none of them apply.
+func (c *Config) TemplateName(p GhostPost) string {
+	return c.postTemplate(p).Name()
+}
+
+// postTemplate selects the template that renders p, see [TemplateExt].
+func (c *Config) postTemplate(p GhostPost) *template.Template {
+	var names []string
+
+	if p.CustomTemplate.Valid && p.CustomTemplate.String != "" {
+		names = append(names, p.CustomTemplate.String+TemplateExt)
+	}
+
+	if t := p.PrimaryTag(); t != nil {
+		names = append(names, TemplateTagPrefix+t.Slug+TemplateExt)
+	}
+
+	if p.Type != "" {
+		names = append(names, p.Type+TemplateExt)
+	}
+
+	names = append(names, DefaultTemplateFile)
+
+	for _, name := range names {
+		if t := c.template.Lookup(name); t != nil {
+			return t
+		}
+	}
+
+	return c.template
+}
@@ -0,0 +1,118 @@
+package ghosttohugo_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+func TestTemplateDir(t *testing.T) {
+	t.Parallel()
+
+	c := ghosttohugo.Config{TemplateDir: "testdata/templates"}
+	c.ApplyDefaults()
+	c.Process()
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse templates: %v", err.Error())
+		t.FailNow()
+	}
+
+	post := func(typ, custom string, tags ...ghosttohugo.GhostTag) ghosttohugo.GhostPost {
+		return ghosttohugo.GhostPost{
+			Title:          "Title",
+			Slug:           "slug",
+			Type:           typ,
+			HTML:           sql.NullString{Valid: true},
+			CustomTemplate: sql.NullString{String: custom, Valid: custom != ""},
+			Tags:           tags,
+		}
+	}
+
+	news := ghosttohugo.GhostTag{Slug: "news", Visibility: "public"}
+	internal := ghosttohugo.GhostTag{Slug: "news", Visibility: "internal"}
+	other := ghosttohugo.GhostTag{Slug: "other", Visibility: "public"}
+
+	tests := []struct {
+		p        ghosttohugo.GhostPost
+		template string
+		want     string
+	}{
+		{post("post", ""), "default.tmpl", "# Title\ndefault: slug\n"},
+		{post("page", ""), "page.tmpl", "# Title\npage: slug\n"},
+		{post("post", "", news), "tag-news.tmpl", "# Title\nnews: slug\n"},
+		// only the primary (first public) tag is considered
+		{post("post", "", other, news), "default.tmpl", "# Title\ndefault: slug\n"},
+		{post("post", "", internal), "default.tmpl", "# Title\ndefault: slug\n"},
+		{post("page", "custom-wide", news), "custom-wide.tmpl", "# Title\nwide: slug\n"},
+		// custom templates that don't exist fall through
+		{post("page", "custom-missing"), "page.tmpl", "# Title\npage: slug\n"},
+	}
+
+	for i, test := range tests {
+		if got := c.TemplateName(test.p); got != test.template {
+			t.Logf("test %v failed: got template %v, want %v", i, got, test.template)
+			t.Fail()
+		}
+
+		got, err := c.RenderString(test.p)
+		if err != nil {
+			t.Logf("test %v failed to render: %v", i, err.Error())
+			t.Fail()
+			continue
+		}
+
+		if got != test.want {
+			t.Logf("test %v failed: got %q, want %q", i, got, test.want)
+			t.Fail()
+		}
+	}
+}
+
+func TestTemplateFS(t *testing.T) {
+	t.Parallel()
+
+	c := ghosttohugo.Config{
+		Template: "fallback: {{ .Post.Slug }}",
+		TemplateFS: fstest.MapFS{
+			"page.tmpl": {Data: []byte(`page: {{ .Post.Slug | missing }}`)},
+		},
+	}
+	c.ApplyDefaults()
+	c.Process()
+	c.AddTemplateFuncs(map[string]any{"missing": func(string) (string, error) { return "", errors.New("oops") }})
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse templates: %v", err.Error())
+		t.FailNow()
+	}
+
+	// without default.tmpl, the configured template is the fallback
+	got, err := c.RenderString(ghosttohugo.GhostPost{Slug: "a", Type: "post", HTML: sql.NullString{Valid: true}})
+	if err != nil || got != "fallback: a" {
+		t.Logf("unexpected fallback output %q, err %v", got, err)
+		t.Fail()
+	}
+
+	// template errors name the file that failed
+	_, err = c.RenderString(ghosttohugo.GhostPost{Slug: "b", Type: "page", HTML: sql.NullString{Valid: true}})
+
+	var te *ghosttohugo.TemplateError
+	if !errors.As(err, &te) || te.Template != "page.tmpl" {
+		t.Logf("expected a TemplateError for page.tmpl, got %v", err)
+		t.Fail()
+	}
+
+	c.TemplateFS = fstest.MapFS{"broken.tmpl": {Data: []byte(`{{ .Post.Slug`)}}
+
+	err = c.ParseTemplate()
+	if err == nil {
+		t.Logf("expected an error for a broken template file")
+		t.Fail()
+	}
+}
@@ -0,0 +1,430 @@
+package ghosttohugo
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// DefaultWatchInterval is how often [Watcher] polls for changes if
+// [Config.WatchInterval] is not set.
+const DefaultWatchInterval = time.Minute
+
+// SourceState is a cheap summary of a [PostSource] that changes whenever any
+// post is created, edited or deleted. Since updated_at only has a resolution
+// of one second, an edit made in the same second as the previous one may not
+// change it, so [Watcher] also checks the posts updated in that second.
+type SourceState struct {
+	// The most recent updated_at of any post.
+	UpdatedAt time.Time
+	// The total number of posts.
+	Count int
+	// A hash of every post's ID, which is used to detect deletions even if a
+	// post was created in the meantime.
+	IDs string
+}
+
+// PostSource provides posts to a [Watcher] or [WebhookServer].
+type PostSource interface {
+	// State returns the current state of the source, which is polled to
+	// determine whether anything needs to be re-rendered.
+	State(ctx context.Context) (SourceState, error)
+	// Posts returns every post.
+	Posts(ctx context.Context) ([]GhostPost, error)
+	// PostsUpdatedSince returns every post that was updated at or after t.
+	PostsUpdatedSince(ctx context.Context, t time.Time) ([]GhostPost, error)
+	// Post returns the post with the given ID, or false if it doesn't exist.
+	Post(ctx context.Context, id string) (GhostPost, bool, error)
+}
+
+// DBSource is a [PostSource] backed by Ghost's database.
+type DBSource struct {
+	Config *Config
+	DB     *sql.DB
+}
+
+// ghostDateTime is the layout that Ghost uses for datetimes in the database.
+const ghostDateTime = "2006-01-02 15:04:05"
+
+// State returns MAX(updated_at) and COUNT(*) from the posts table, along with
+// a hash of the IDs of every post.
+func (s *DBSource) State(ctx context.Context) (SourceState, error) {
+	var st SourceState
+	var updatedAt sql.NullString
+
+	err := s.DB.QueryRowContext(ctx, "SELECT MAX(updated_at), COUNT(*) FROM posts").Scan(&updatedAt, &st.Count)
+	if err != nil {
+		return st, fmt.Errorf("failed to query posts state: %w", err)
+	}
+
+	if updatedAt.Valid {
+		st.UpdatedAt, err = time.Parse(ghostDateTime, updatedAt.String)
+		if err != nil {
+			return st, fmt.Errorf("failed to parse MAX(updated_at) datetime: %w", err)
+		}
+	}
+
+	rows, err := s.DB.QueryContext(ctx, "SELECT id FROM posts ORDER BY id")
+	if err != nil {
+		return st, fmt.Errorf("failed to query post ids: %w", err)
+	}
+
+	defer rows.Close()
+
+	h := sha256.New()
+	for rows.Next() {
+		var id string
+
+		err = rows.Scan(&id)
+		if err != nil {
+			return st, fmt.Errorf("failed to scan post id: %w", err)
+		}
+
+		fmt.Fprintln(h, id)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return st, fmt.Errorf("failed to iterate over post ids: %w", err)
+	}
+
+	st.IDs = hex.EncodeToString(h.Sum(nil))
+
+	return st, nil
+}
+
+// Posts returns every post, see [Config.LoadPosts].
+func (s *DBSource) Posts(ctx context.Context) ([]GhostPost, error) {
+	return s.Config.LoadPosts(ctx, s.DB)
+}
+
+// PostsUpdatedSince returns every post whose updated_at is at or after t.
+// updated_at only has a resolution of one second, so posts updated in the
+// same second as t are included in case they were edited again.
+func (s *DBSource) PostsUpdatedSince(ctx context.Context, t time.Time) ([]GhostPost, error) {
+	return s.Config.queryPosts(ctx, s.DB, "updated_at >= ?", t.UTC().Format(ghostDateTime))
+}
+
+// watchInterval parses WatchInterval, returning the default if it is unset.
+func (c *Config) watchInterval() (time.Duration, error) {
+	if c.WatchInterval == "" {
+		return DefaultWatchInterval, nil
+	}
+
+	d, err := time.ParseDuration(c.WatchInterval)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse watchInterval %v: %w", c.WatchInterval, err)
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("watchInterval must be positive, got %v", c.WatchInterval)
+	}
+
+	return d, nil
+}
+
+// Post returns the post with the given ID, or false if it doesn't exist.
+func (s *DBSource) Post(ctx context.Context, id string) (GhostPost, bool, error) {
+	posts, err := s.Config.queryPosts(ctx, s.DB, "id = ?", id)
+	if err != nil || len(posts) == 0 {
+		return GhostPost{}, false, err
+	}
+
+	return posts[0], true, nil
+}
+
+// Watcher polls a [PostSource] and re-renders posts as they change. The first
+// poll renders everything; after that, only posts that were updated since
+// the previous poll are re-rendered, unless any posts were created or
+// deleted, in which case everything is re-rendered so that deleted posts are
+// removed. Posts that are loaded again without having changed, such as those
+// updated in the same second as the previous poll, aren't re-rendered.
+type Watcher struct {
+	Config *Config
+	Source PostSource
+
+	// The state as of the last successful poll.
+	state *SourceState
+	// The file that each post was last rendered to, keyed on post ID.
+	files map[string]string
+	// The hash of each post as it was last seen, see [postSum].
+	sums map[string][sha256.Size]byte
+}
+
+// postSum hashes every field of p, so that a post that was loaded again can
+// be skipped if it hasn't changed.
+func postSum(p GhostPost) [sha256.Size]byte {
+	return sha256.Sum256([]byte(fmt.Sprintf("%+v", p)))
+}
+
+// Run polls for changes every [Config.WatchInterval] until ctx is cancelled.
+// A poll that is in progress when ctx is cancelled is allowed to finish, so
+// that the output directory is never left half-written. Errors from
+// individual polls are logged and retried on the next interval.
+func (w *Watcher) Run(ctx context.Context) error {
+	interval, err := w.Config.watchInterval()
+	if err != nil {
+		return err
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		_, err := w.Poll(context.WithoutCancel(ctx))
+		if err != nil {
+			w.Config.logger().Error("failed to poll for changes", slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// Poll checks the source for changes once and re-renders whatever changed,
+// returning true if anything was rendered or removed. If [Config.Report] is
+// set, each poll that changes anything or fails gets a new report, which is
+// written once the poll is done, see [Config.WriteReport].
+func (w *Watcher) Poll(ctx context.Context) (changed bool, err error) {
+	st, err := w.Source.State(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	prev := w.Config.startRun()
+	defer func() {
+		// keep reporting on the last poll that did something
+		if !changed && err == nil {
+			w.Config.Report = prev
+			return
+		}
+
+		reportErr := w.Config.WriteReport()
+		if err == nil {
+			err = reportErr
+		}
+	}()
+
+	// even if the state is unchanged, posts may have been edited again in
+	// the same second as the last poll, which renderSince checks for
+	if w.state == nil || w.state.Count != st.Count || w.state.IDs != st.IDs {
+		changed, err = w.renderAll(ctx)
+	} else {
+		changed, err = w.renderSince(ctx, w.state.UpdatedAt)
+	}
+
+	// posts that were skipped under ContinueOnError don't stop the rest of
+	// the poll, but are still returned
+	_, ok := skippable(err)
+	if !ok {
+		return changed, err
+	}
+
+	w.state = &st
+
+	if changed {
+		w.Config.logger().Info("re-rendered changed posts", slog.Time("updated_at", st.UpdatedAt), slog.Int("posts", st.Count))
+
+		cmdErr := w.Config.RunPostExportCommand()
+		if cmdErr != nil {
+			return changed, cmdErr
+		}
+	}
+
+	return changed, err
+}
+
+// renderAll renders every valid post and removes files for posts that were
+// previously rendered but no longer are. Posts that fail to load or render
+// under ContinueOnError keep their previous files, and are returned as
+// [PostErrors].
+func (w *Watcher) renderAll(ctx context.Context) (bool, error) {
+	posts, loadErr := w.Source.Posts(ctx)
+	loadErrs, ok := skippable(loadErr)
+	if !ok {
+		return false, loadErr
+	}
+
+	sums := make(map[string][sha256.Size]byte)
+	for _, p := range posts {
+		sums[p.ID] = postSum(p)
+	}
+
+	valid := w.Config.Select(posts)
+	files := make(map[string]string)
+	for _, p := range valid {
+		files[p.ID] = w.Config.OutputFile(p)
+	}
+
+	renderErr := w.Config.RenderLoaded(ctx, valid, loadErr)
+	renderErrs, ok := skippable(renderErr)
+	if !ok {
+		return false, renderErr
+	}
+
+	errs := append(loadErrs, renderErrs...)
+	for _, e := range errs {
+		f, ok := w.files[e.PostID]
+		if ok {
+			files[e.PostID] = f
+		} else {
+			delete(files, e.PostID)
+		}
+	}
+
+	for id, f := range w.files {
+		if files[id] != f {
+			err := removeFile(f)
+			if err != nil {
+				return true, err
+			}
+		}
+	}
+
+	w.files = files
+	w.sums = sums
+
+	return true, w.Config.postErrors(errs, 0)
+}
+
+// renderSince re-renders posts that were updated at or after t and have
+// changed since they were last seen, removing the files of any that are no
+// longer valid or whose output path changed. Posts that fail to load or
+// render under ContinueOnError keep their previous files, and are returned
+// as [PostErrors]. Files that fail [Config.Verify] are removed, and their
+// posts are rendered and verified again on the next poll.
+func (w *Watcher) renderSince(ctx context.Context, t time.Time) (bool, error) {
+	posts, err := w.Source.PostsUpdatedSince(ctx, t)
+	errs, ok := skippable(err)
+	if !ok {
+		return false, err
+	}
+
+	var updated []GhostPost
+	sums := make(map[string][sha256.Size]byte)
+	for _, p := range posts {
+		sum := postSum(p)
+		if w.sums[p.ID] != sum {
+			updated = append(updated, p)
+			sums[p.ID] = sum
+		}
+	}
+
+	valid := make(map[string]bool)
+	for _, p := range w.Config.Select(updated) {
+		valid[p.ID] = true
+	}
+
+	var rendered []RenderedFile
+	var changed bool
+
+	for _, p := range updated {
+		sum := sums[p.ID]
+		old, existed := w.files[p.ID]
+
+		if !valid[p.ID] {
+			w.sums[p.ID] = sum
+
+			if existed {
+				w.Config.postLogger(p).Info("removing post that is no longer selected", slog.String("path", old))
+
+				err = removeFile(old)
+				if err != nil {
+					return changed, err
+				}
+
+				delete(w.files, p.ID)
+				changed = true
+			}
+
+			continue
+		}
+
+		_, f, err := w.Config.RenderOne(p)
+		if err != nil {
+			err = w.Config.renderFailed(p, err, &errs)
+			if err != nil {
+				return changed, err
+			}
+
+			// skipped, so it isn't retried until it changes again
+			w.sums[p.ID] = sum
+
+			continue
+		}
+
+		changed = true
+		w.sums[p.ID] = sum
+		rendered = append(rendered, RenderedFile{Path: f, Post: p})
+
+		if existed && old != f {
+			err = removeFile(old)
+			if err != nil {
+				return changed, err
+			}
+		}
+
+		w.files[p.ID] = f
+	}
+
+	if len(rendered) > 0 {
+		r, err := w.Config.Verify(rendered)
+		if err != nil {
+			// remove the unsafe files and forget the posts, so that they're
+			// verified again on every poll until they're fixed or allowlisted
+			for _, e := range r.Entries {
+				if !e.Allowed {
+					delete(w.files, e.PostID)
+					delete(w.sums, e.PostID)
+				}
+			}
+
+			rmErr := w.Config.removeUnsafe(r)
+			if rmErr != nil {
+				return changed, fmt.Errorf("failed to verify rendered posts: %w, and failed to remove them: %w", err, rmErr)
+			}
+
+			return changed, fmt.Errorf("failed to verify rendered posts: %w", err)
+		}
+	}
+
+	return changed, w.Config.postErrors(errs, 0)
+}
+
+// removeFile removes f, ignoring files that don't exist.
+func removeFile(f string) error {
+	err := os.Remove(f)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove %v: %w", f, err)
+	}
+
+	return nil
+}
+
+// RunPostExportCommand runs PostExportCommand, if set, unless DryRun is set.
+// The command isn't tied to a context so that a build in progress during
+// shutdown can finish.
+func (c *Config) RunPostExportCommand() error {
+	if len(c.PostExportCommand) == 0 || c.DryRun {
+		return nil
+	}
+
+	cmd := exec.Command(c.PostExportCommand[0], c.PostExportCommand[1:]...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("post-export command %v failed: %w: %s", c.PostExportCommand, err, out)
+	}
+
+	return nil
+}
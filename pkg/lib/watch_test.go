@@ -0,0 +1,488 @@
+package ghosttohugo_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+// newWatchDB returns a fake database whose posts table is *rows, which may be
+// changed while holding mu.
+func newWatchDB(t *testing.T, mu *sync.Mutex, rows *[][]driver.Value) *sql.DB {
+	t.Helper()
+
+	return newFakeDB(t, func(q string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case strings.Contains(q, "MAX(updated_at)"):
+			var latest string
+			for _, r := range *rows {
+				latest = max(latest, r[18].(string))
+			}
+
+			return []string{"UpdatedAt", "Count"}, [][]driver.Value{{latest, int64(len(*rows))}}, nil
+		case strings.Contains(q, "SELECT id FROM posts"):
+			var ids [][]driver.Value
+			for _, r := range *rows {
+				ids = append(ids, []driver.Value{r[0]})
+			}
+
+			return []string{"ID"}, ids, nil
+		case strings.Contains(q, "WHERE updated_at >= ?"):
+			var since [][]driver.Value
+			for _, r := range *rows {
+				if r[18].(string) >= args[0].(string) {
+					since = append(since, r)
+				}
+			}
+
+			return postColumns, since, nil
+		case strings.Contains(q, "FROM posts_meta"):
+			return postsMetaColumns, nil, nil
+		case strings.Contains(q, "FROM posts"):
+			return postColumns, *rows, nil
+		}
+
+		return nil, nil, fmt.Errorf("unexpected query: %v", q)
+	})
+}
+
+func TestWatcher(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	rows := [][]driver.Value{
+		fakePostRow("1", "first", "<p>1</p>", "2024-01-02 00:00:00"),
+		fakePostRow("2", "second", "<p>2</p>", "2024-01-03 00:00:00"),
+	}
+
+	db := newWatchDB(t, &mu, &rows)
+
+	dir := t.TempDir()
+	built := path.Join(dir, "built")
+
+	c := ghosttohugo.Config{
+		OutputPath:        path.Join(dir, "content"),
+		PostExportCommand: []string{"sh", "-c", "echo >> " + built},
+	}
+	c.ApplyDefaults()
+	c.Process()
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	w := ghosttohugo.Watcher{Config: &c, Source: &ghosttohugo.DBSource{Config: &c, DB: db}}
+	ctx := context.Background()
+	first := path.Join(c.OutputPath, "first.md")
+	second := path.Join(c.OutputPath, "second.md")
+
+	poll := func(want bool) {
+		t.Helper()
+
+		changed, err := w.Poll(ctx)
+		if err != nil {
+			t.Logf("failed to poll: %v", err.Error())
+			t.FailNow()
+		}
+
+		if changed != want {
+			t.Logf("poll changed: got %v, want %v", changed, want)
+			t.FailNow()
+		}
+	}
+
+	// the first poll renders everything
+	poll(true)
+
+	if !exists(first) || !exists(second) {
+		t.Logf("expected both posts to be rendered")
+		t.FailNow()
+	}
+
+	// nothing changed
+	poll(false)
+
+	// only the updated post is re-rendered
+	err = os.WriteFile(first, []byte("untouched"), 0o644)
+	if err != nil {
+		t.Logf("failed to write file: %v", err.Error())
+		t.FailNow()
+	}
+
+	mu.Lock()
+	rows[1] = fakePostRow("2", "second", "<p>edited</p>", "2024-01-04 00:00:00")
+	mu.Unlock()
+
+	poll(true)
+
+	b, _ := os.ReadFile(first)
+	if string(b) != "untouched" {
+		t.Logf("unchanged post was re-rendered: %v", string(b))
+		t.Fail()
+	}
+
+	b, _ = os.ReadFile(second)
+	if !strings.Contains(string(b), "edited") {
+		t.Logf("updated post was not re-rendered: %v", string(b))
+		t.Fail()
+	}
+
+	// an edit in the same second as the last poll doesn't change the state,
+	// but is still picked up
+	mu.Lock()
+	rows[1] = fakePostRow("2", "second", "<p>again</p>", "2024-01-04 00:00:00")
+	mu.Unlock()
+
+	poll(true)
+
+	b, _ = os.ReadFile(second)
+	if !strings.Contains(string(b), "again") {
+		t.Logf("post edited in the same second was not re-rendered: %v", string(b))
+		t.Fail()
+	}
+
+	// and isn't re-rendered again
+	poll(false)
+
+	// a post that is unpublished is removed
+	mu.Lock()
+	rows[1] = fakePostRow("2", "second", "<p>edited</p>", "2024-01-05 00:00:00")
+	rows[1][12] = "draft"
+	mu.Unlock()
+
+	poll(true)
+
+	if exists(second) {
+		t.Logf("expected unpublished post to be removed")
+		t.Fail()
+	}
+
+	// a deleted post is removed after a full re-render, even if another
+	// post was created so the count is unchanged
+	third := path.Join(c.OutputPath, "third.md")
+
+	mu.Lock()
+	rows = [][]driver.Value{rows[1], fakePostRow("3", "third", "<p>3</p>", "2024-01-06 00:00:00")}
+	mu.Unlock()
+
+	poll(true)
+
+	if exists(first) || !exists(third) {
+		t.Logf("expected the deleted post to be removed and the new one rendered")
+		t.Fail()
+	}
+
+	mu.Lock()
+	rows = rows[:1]
+	mu.Unlock()
+
+	poll(true)
+
+	if exists(third) {
+		t.Logf("expected deleted post to be removed")
+		t.Fail()
+	}
+
+	b, err = os.ReadFile(built)
+	if err != nil {
+		t.Logf("post-export command did not run: %v", err.Error())
+		t.FailNow()
+	}
+
+	if n := strings.Count(string(b), "\n"); n != 6 {
+		t.Logf("post-export command ran %v times, want 6", n)
+		t.Fail()
+	}
+}
+
+func TestWatcherContinueOnError(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	rows := [][]driver.Value{
+		fakePostRow("1", "first", "<p>1</p>", "2024-01-02 00:00:00"),
+		fakePostRow("2", "second", "<p>2</p>", "2024-01-03 00:00:00"),
+		fakePostRow("3", "third", "<p>3</p>", "2024-01-04 00:00:00"),
+	}
+
+	db := newWatchDB(t, &mu, &rows)
+
+	dir := t.TempDir()
+
+	c := ghosttohugo.Config{
+		OutputPath:       dir,
+		ForbidEmptyPosts: true,
+		ContinueOnError:  true,
+	}
+	c.ApplyDefaults()
+	c.Process()
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	w := ghosttohugo.Watcher{Config: &c, Source: &ghosttohugo.DBSource{Config: &c, DB: db}}
+	ctx := context.Background()
+
+	changed, err := w.Poll(ctx)
+	if err != nil || !changed {
+		t.Logf("unexpected first poll: %v, %v", changed, err)
+		t.FailNow()
+	}
+
+	// a post that fails to render keeps its previous file
+	mu.Lock()
+	rows[1] = fakePostRow("2", "second", "", "2024-01-05 00:00:00")
+	rows[1][6] = nil
+	mu.Unlock()
+
+	_, err = w.Poll(ctx)
+
+	var errs ghosttohugo.PostErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].PostID != "2" {
+		t.Logf("expected post 2 to fail, got %v", err)
+		t.Fail()
+	}
+
+	if !exists(path.Join(dir, "second.md")) {
+		t.Logf("expected the failed post's file to be kept")
+		t.Fail()
+	}
+
+	// the poll still completed, so the failure isn't retried until the post
+	// changes again
+	changed, err = w.Poll(ctx)
+	if err != nil || changed {
+		t.Logf("unexpected poll after failure: %v, %v", changed, err)
+		t.Fail()
+	}
+
+	// posts that fail to load or render during a full re-render keep their
+	// files, while the deleted post is still removed
+	mu.Lock()
+	rows[2] = fakePostRow("3", "third", "<p>3</p>", "2024-01-06 00:00:00")
+	rows[2][16] = "yesterday"
+	rows = rows[1:]
+	mu.Unlock()
+
+	changed, err = w.Poll(ctx)
+	if !changed || !errors.As(err, &errs) || len(errs) != 2 || errs[0].PostID != "3" || errs[1].PostID != "2" {
+		t.Logf("expected posts 3 and 2 to fail, got %v, %v", changed, err)
+		t.Fail()
+	}
+
+	if exists(path.Join(dir, "first.md")) {
+		t.Logf("expected deleted post to be removed")
+		t.Fail()
+	}
+
+	if !exists(path.Join(dir, "second.md")) || !exists(path.Join(dir, "third.md")) {
+		t.Logf("expected the failed posts' files to be kept")
+		t.Fail()
+	}
+
+	// a shared budget: one failure to load and one to render is too many
+	c.MaxErrors = 1
+
+	mu.Lock()
+	rows = append(rows, fakePostRow("4", "fourth", "<p>4</p>", "2024-01-07 00:00:00"))
+	mu.Unlock()
+
+	_, err = w.Poll(ctx)
+	if !errors.Is(err, ghosttohugo.ErrTooManyErrors) {
+		t.Logf("expected ErrTooManyErrors, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestWatcherUnsafe(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	rows := [][]driver.Value{
+		fakePostRow("1", "first", "<p>1</p>", "2024-01-02 00:00:00"),
+		fakePostRow("2", "second", "<p>2</p>", "2024-01-03 00:00:00"),
+	}
+
+	db := newWatchDB(t, &mu, &rows)
+
+	dir := t.TempDir()
+
+	c := ghosttohugo.Config{OutputPath: dir}
+	c.ApplyDefaults()
+	c.Process()
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	w := ghosttohugo.Watcher{Config: &c, Source: &ghosttohugo.DBSource{Config: &c, DB: db}}
+	ctx := context.Background()
+
+	changed, err := w.Poll(ctx)
+	if err != nil || !changed {
+		t.Logf("unexpected first poll: %v, %v", changed, err)
+		t.FailNow()
+	}
+
+	// the post becomes paid, which the selection doesn't rule out
+	mu.Lock()
+	rows[0] = fakePostRow("1", "first", "<p>1</p>", "2024-01-04 00:00:00")
+	rows[0][14] = "paid"
+	mu.Unlock()
+
+	// every poll fails and leaves no file behind until the post is fixed
+	for i := 0; i < 2; i++ {
+		_, err = w.Poll(ctx)
+		if !errors.Is(err, ghosttohugo.ErrUnsafePublish) {
+			t.Logf("poll %v: expected ErrUnsafePublish, got %v", i, err)
+			t.Fail()
+		}
+
+		if exists(path.Join(dir, "first.md")) {
+			t.Logf("poll %v: expected the paid post's file to be removed", i)
+			t.Fail()
+		}
+	}
+
+	if !exists(path.Join(dir, "second.md")) {
+		t.Logf("expected the public post's file to be kept")
+		t.Fail()
+	}
+
+	c.PublishAllowlist = []string{"first"}
+
+	changed, err = w.Poll(ctx)
+	if err != nil || !changed || !exists(path.Join(dir, "first.md")) {
+		t.Logf("expected the allowlisted post to be rendered, got %v, %v", changed, err)
+		t.Fail()
+	}
+}
+
+func TestWatcherReport(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	rows := [][]driver.Value{
+		fakePostRow("1", "first", "<p>1</p>", "2024-01-02 00:00:00"),
+		fakePostRow("2", "second", "<p>2</p>", "2024-01-03 00:00:00"),
+	}
+	rows[1][12] = "draft"
+
+	db := newWatchDB(t, &mu, &rows)
+	dir := t.TempDir()
+
+	c := ghosttohugo.Config{
+		OutputPath: path.Join(dir, "content"),
+		ReportPath: path.Join(dir, "report.json"),
+		Report:     ghosttohugo.NewRunReport(),
+	}
+	c.ApplyDefaults()
+	c.Process()
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	w := ghosttohugo.Watcher{Config: &c, Source: &ghosttohugo.DBSource{Config: &c, DB: db}}
+
+	report := func() (*ghosttohugo.RunReport, string) {
+		t.Helper()
+
+		_, err := w.Poll(context.Background())
+		if err != nil {
+			t.Logf("failed to poll: %v", err.Error())
+			t.FailNow()
+		}
+
+		b, err := os.ReadFile(c.ReportPath)
+		if err != nil {
+			t.Logf("failed to read report: %v", err.Error())
+			t.FailNow()
+		}
+
+		r := &ghosttohugo.RunReport{}
+		err = json.Unmarshal(b, r)
+		if err != nil {
+			t.Logf("failed to parse report: %v", err.Error())
+			t.FailNow()
+		}
+
+		return r, string(b)
+	}
+
+	r, _ := report()
+	if r.Rendered != 1 || len(r.Skipped) != 1 || r.ByStatus["published"] != 1 || r.ByStatus["draft"] != 1 {
+		t.Logf("unexpected first report: %+v", r)
+		t.Fail()
+	}
+
+	// each poll is reported on its own
+	mu.Lock()
+	rows[0] = fakePostRow("1", "first", "<p>edited</p>", "2024-01-04 00:00:00")
+	mu.Unlock()
+
+	r, want := report()
+	if r.Rendered != 1 || len(r.Skipped) != 0 || r.ByStatus["published"] != 1 || r.ByStatus["draft"] != 0 {
+		t.Logf("unexpected second report: %+v", r)
+		t.Fail()
+	}
+
+	// a poll that does nothing leaves the last report in place
+	_, got := report()
+	if got != want {
+		t.Logf("report was rewritten by a poll that did nothing:\n%v", got)
+		t.Fail()
+	}
+}
+
+func TestWatcherRun(t *testing.T) {
+	t.Parallel()
+
+	db := newFakeDB(t, func(q string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		return nil, nil, fmt.Errorf("unavailable")
+	})
+
+	c := ghosttohugo.Config{WatchInterval: "1ms"}
+	c.ApplyDefaults()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// errors from polling are retried rather than returned, and a cancelled
+	// context stops the watcher cleanly
+	w := ghosttohugo.Watcher{Config: &c, Source: &ghosttohugo.DBSource{Config: &c, DB: db}}
+	err := w.Run(ctx)
+	if err != nil {
+		t.Logf("unexpected error: %v", err.Error())
+		t.Fail()
+	}
+
+	c.WatchInterval = "soon"
+	err = w.Run(context.Background())
+	if err == nil {
+		t.Logf("expected an error for an invalid interval")
+		t.Fail()
+	}
+}
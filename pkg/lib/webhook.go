@@ -0,0 +1,536 @@
+package ghosttohugo
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultWebhookDebounce is how long [WebhookServer] waits after the last
+	// webhook before re-rendering, if [Config.WebhookDebounce] is not set.
+	DefaultWebhookDebounce = 5 * time.Second
+
+	// WebhookSignatureHeader is the header that Ghost signs webhooks with.
+	WebhookSignatureHeader = "X-Ghost-Signature"
+
+	// webhookTolerance is how old a webhook's signature timestamp may be
+	// before it is rejected as a replay.
+	webhookTolerance = 5 * time.Minute
+
+	// webhookMaxBody limits the size of a webhook payload. Ghost includes the
+	// whole post, so this is generous.
+	webhookMaxBody = 32 << 20
+)
+
+var (
+	// ErrWebhookSignature is returned when a webhook's signature is missing,
+	// malformed, expired or doesn't match [Config.WebhookSecret].
+	ErrWebhookSignature = errors.New("invalid webhook signature")
+)
+
+// VerifyWebhookSignature checks a Ghost webhook signature header, which looks
+// like "sha256=<hex>, t=<unix milliseconds>", where the hex is an HMAC-SHA256
+// of the body followed by the timestamp. Signatures older than five minutes
+// are rejected to prevent replays.
+func VerifyWebhookSignature(secret string, body []byte, header string, now time.Time) error {
+	if secret == "" {
+		return fmt.Errorf("%w: no webhook secret is configured", ErrWebhookSignature)
+	}
+
+	var sig, ts string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "sha256":
+			sig = v
+		case "t":
+			ts = v
+		}
+	}
+
+	if sig == "" || ts == "" {
+		return fmt.Errorf("%w: malformed %v header", ErrWebhookSignature, WebhookSignatureHeader)
+	}
+
+	ms, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: malformed timestamp %v", ErrWebhookSignature, ts)
+	}
+
+	age := now.Sub(time.UnixMilli(ms))
+	if age > webhookTolerance || age < -webhookTolerance {
+		return fmt.Errorf("%w: timestamp %v is outside the allowed window", ErrWebhookSignature, ts)
+	}
+
+	want, err := hex.DecodeString(sig)
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature", ErrWebhookSignature)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	mac.Write([]byte(ts))
+
+	if !hmac.Equal(mac.Sum(nil), want) {
+		return ErrWebhookSignature
+	}
+
+	return nil
+}
+
+// SignWebhook returns a signature header for body, as Ghost would send it.
+// It's mainly useful for testing.
+func SignWebhook(secret string, body []byte, t time.Time) string {
+	ts := strconv.FormatInt(t.UnixMilli(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	mac.Write([]byte(ts))
+
+	return fmt.Sprintf("sha256=%v, t=%v", hex.EncodeToString(mac.Sum(nil)), ts)
+}
+
+// webhookPost is the subset of the post that Ghost sends in a webhook which
+// is needed to work out where it was rendered to.
+type webhookPost struct {
+	ID         string `json:"id"`
+	UUID       string `json:"uuid"`
+	Slug       string `json:"slug"`
+	Status     string `json:"status"`
+	Visibility string `json:"visibility"`
+	Tags       []struct {
+		ID         string `json:"id"`
+		Name       string `json:"name"`
+		Slug       string `json:"slug"`
+		Visibility string `json:"visibility"`
+	} `json:"tags"`
+}
+
+// webhookEvent is the body of a post.* or page.* webhook. For deletions,
+// current is empty; for edits, previous only contains the fields that
+// changed.
+type webhookEvent struct {
+	Current  webhookPost `json:"current"`
+	Previous webhookPost `json:"previous"`
+}
+
+// ParseWebhook parses the body of a Ghost post.* or page.* webhook and returns
+// the post as it was before the event, which is what's needed to find and
+// remove its old file. Only the ID, UUID, slug, type, status, visibility and
+// tags are populated.
+func ParseWebhook(body []byte) (GhostPost, error) {
+	var payload struct {
+		Post *webhookEvent `json:"post"`
+		Page *webhookEvent `json:"page"`
+	}
+
+	err := json.Unmarshal(body, &payload)
+	if err != nil {
+		return GhostPost{}, fmt.Errorf("failed to parse webhook: %w", err)
+	}
+
+	e, typ := payload.Post, "post"
+	if e == nil {
+		e, typ = payload.Page, "page"
+	}
+
+	if e == nil {
+		return GhostPost{}, fmt.Errorf("webhook has neither a post nor a page")
+	}
+
+	// start from the current post and overlay whatever changed
+	w := e.Current
+	if e.Previous.ID != "" {
+		w.ID = e.Previous.ID
+	}
+	if e.Previous.UUID != "" {
+		w.UUID = e.Previous.UUID
+	}
+	if e.Previous.Slug != "" {
+		w.Slug = e.Previous.Slug
+	}
+	if e.Previous.Status != "" {
+		w.Status = e.Previous.Status
+	}
+	if e.Previous.Visibility != "" {
+		w.Visibility = e.Previous.Visibility
+	}
+	if e.Previous.Tags != nil {
+		w.Tags = e.Previous.Tags
+	}
+
+	if w.ID == "" {
+		return GhostPost{}, fmt.Errorf("webhook %v has no id", typ)
+	}
+
+	p := GhostPost{
+		ID:         w.ID,
+		UUID:       w.UUID,
+		Slug:       w.Slug,
+		Type:       typ,
+		Status:     w.Status,
+		Visibility: w.Visibility,
+	}
+
+	for _, t := range w.Tags {
+		p.Tags = append(p.Tags, GhostTag{ID: t.ID, Name: t.Name, Slug: t.Slug, Visibility: t.Visibility})
+	}
+
+	return p, nil
+}
+
+// WebhookHealth is the response body of the health endpoint.
+type WebhookHealth struct {
+	Status     string     `json:"status"`
+	Pending    int        `json:"pending"`
+	LastExport *time.Time `json:"lastExport,omitempty"`
+	LastError  string     `json:"lastError,omitempty"`
+}
+
+// WebhookServer is an [http.Handler] that receives Ghost's post.published,
+// post.edited, post.unpublished and post.deleted webhooks (and their page.*
+// equivalents) and re-renders or removes just the affected posts.
+//
+// Webhooks are accepted on POST /webhook and must be signed with
+// [Config.WebhookSecret]; every webhook is rejected if no secret is set.
+// Affected posts are queued and processed once no further webhooks have
+// arrived for [Config.WebhookDebounce], so that a burst of edits only
+// triggers one export. GET /healthz reports the server's status.
+type WebhookServer struct {
+	Config *Config
+	Source PostSource
+
+	mu sync.Mutex
+	// The previous state of each queued post, keyed on post ID.
+	pending    map[string]GhostPost
+	timer      *time.Timer
+	lastExport time.Time
+	lastErr    error
+
+	// Held while exporting so that exports never overlap.
+	exportMu sync.Mutex
+}
+
+// webhookDebounce parses WebhookDebounce, returning the default if it is
+// unset.
+func (c *Config) webhookDebounce() (time.Duration, error) {
+	if c.WebhookDebounce == "" {
+		return DefaultWebhookDebounce, nil
+	}
+
+	d, err := time.ParseDuration(c.WebhookDebounce)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse webhookDebounce %v: %w", c.WebhookDebounce, err)
+	}
+
+	if d < 0 {
+		return 0, fmt.Errorf("webhookDebounce must not be negative, got %v", c.WebhookDebounce)
+	}
+
+	return d, nil
+}
+
+func (s *WebhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/webhook":
+		s.serveWebhook(w, r)
+	case "/healthz":
+		s.serveHealth(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *WebhookServer) serveWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, webhookMaxBody))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	err = VerifyWebhookSignature(s.Config.WebhookSecret, body, r.Header.Get(WebhookSignatureHeader), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	p, err := ParseWebhook(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.Config.postLogger(p).Info("received webhook", slog.String("type", p.Type))
+
+	err = s.enqueue(p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *WebhookServer) serveHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	h := WebhookHealth{Status: "ok", Pending: len(s.pending)}
+	if !s.lastExport.IsZero() {
+		t := s.lastExport
+		h.LastExport = &t
+	}
+	if s.lastErr != nil {
+		h.Status = "error"
+		h.LastError = s.lastErr.Error()
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if h.LastError != "" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	_ = json.NewEncoder(w).Encode(h)
+}
+
+// enqueue queues p to be exported once the debounce window has passed
+// without any further webhooks.
+func (s *WebhookServer) enqueue(p GhostPost) error {
+	d, err := s.Config.webhookDebounce()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pending == nil {
+		s.pending = make(map[string]GhostPost)
+	}
+
+	// keep the oldest known state, since that's where the file is
+	if _, ok := s.pending[p.ID]; !ok {
+		s.pending[p.ID] = p
+	}
+
+	s.schedule(d)
+
+	return nil
+}
+
+// schedule (re)starts the debounce timer, which flushes the queue after d.
+// s.mu must be held.
+func (s *WebhookServer) schedule(d time.Duration) {
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+
+	s.timer = time.AfterFunc(d, func() {
+		err := s.Flush(context.Background())
+		if err != nil {
+			s.Config.logger().Error("failed to export posts from webhooks", slog.Any("error", err))
+		}
+	})
+}
+
+// Flush immediately exports every queued post, without waiting for the
+// debounce window. It should be called when shutting down so that no
+// webhooks are lost.
+//
+// If the export fails, the posts are queued again and retried after the
+// debounce window. Posts that were skipped under ContinueOnError aren't
+// retried, as with any other export.
+//
+// If [Config.Report] is set, each export gets a new report, which is written
+// once the export is done, see [Config.WriteReport].
+func (s *WebhookServer) Flush(ctx context.Context) error {
+	s.exportMu.Lock()
+	defer s.exportMu.Unlock()
+
+	s.mu.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+
+	pending := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	s.Config.startRun()
+
+	exportErr := s.export(ctx, pending)
+
+	err := s.Config.WriteReport()
+	if exportErr != nil {
+		err = exportErr
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastExport = time.Now()
+	s.lastErr = err
+
+	if _, ok := skippable(exportErr); !ok {
+		if s.pending == nil {
+			s.pending = make(map[string]GhostPost)
+		}
+
+		// these are older than anything queued since, and it's the oldest
+		// state that says where the file is
+		for id, p := range pending {
+			s.pending[id] = p
+		}
+
+		d, dErr := s.Config.webhookDebounce()
+		if dErr == nil {
+			s.schedule(d)
+		}
+	}
+
+	return err
+}
+
+// export re-renders or removes each of the given posts, then rewrites the
+// redirects file if one is configured and runs the post-export command.
+// Posts that fail to load or render under ContinueOnError are left as they
+// are and returned as [PostErrors] once everything else is done.
+func (s *WebhookServer) export(ctx context.Context, pending map[string]GhostPost) error {
+	c := s.Config
+
+	ids := make([]string, 0, len(pending))
+	for id := range pending {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	var rendered []RenderedFile
+	var errs PostErrors
+
+	for _, id := range ids {
+		prev := pending[id]
+		old := ""
+		if prev.Slug != "" {
+			old = c.OutputFile(prev)
+		}
+
+		p, ok, err := s.Source.Post(ctx, id)
+		if err != nil {
+			failed, skip := skippable(err)
+			if !skip {
+				return fmt.Errorf("failed to load post %v: %w", id, err)
+			}
+
+			errs = append(errs, failed...)
+			if c.tooManyErrors(len(errs)) {
+				return c.postErrors(errs, 0)
+			}
+
+			continue
+		}
+
+		if !ok || len(c.Select([]GhostPost{p})) == 0 {
+			c.postLogger(prev).Info("removing post", slog.Bool("exists", ok))
+
+			if ok {
+				err = removeFile(c.OutputFile(p))
+				if err != nil {
+					return err
+				}
+			}
+
+			if old != "" {
+				err = removeFile(old)
+				if err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		_, f, err := c.RenderOne(p)
+		if err != nil {
+			err = c.renderFailed(p, err, &errs)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		rendered = append(rendered, RenderedFile{Path: f, Post: p})
+
+		if old != "" && old != f {
+			err = removeFile(old)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(rendered) > 0 {
+		_, err := c.Verify(rendered)
+		if err != nil {
+			return fmt.Errorf("failed to verify rendered posts: %w", err)
+		}
+	}
+
+	if c.RedirectsOutputPath != "" && !c.DryRun {
+		// posts that fail to load here have already been reported, or
+		// weren't affected by the webhooks
+		posts, err := s.Source.Posts(ctx)
+		if _, ok := skippable(err); !ok {
+			return err
+		}
+
+		var valid []GhostPost
+		for _, p := range posts {
+			if c.IsValid(p) {
+				valid = append(valid, p)
+			}
+		}
+
+		err = c.WriteRedirects(valid)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := c.RunPostExportCommand()
+	if err != nil {
+		return err
+	}
+
+	return c.postErrors(errs, 0)
+}
@@ -0,0 +1,385 @@
+package ghosttohugo_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	ghosttohugo "github.com/charles-m-knox/ghost-to-hugo/pkg/lib"
+)
+
+const testWebhookSecret = "s3cret"
+
+// newWebhookServer returns a webhook server whose database only contains the
+// renamed version of post 1.
+func newWebhookServer(t *testing.T, debounce string) (*ghosttohugo.WebhookServer, string) {
+	t.Helper()
+
+	rows := [][]driver.Value{
+		fakePostRow("1", "renamed", "<p>edited</p>", "2024-01-04 00:00:00"),
+	}
+
+	db := newFakeDB(t, func(q string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		switch {
+		case strings.Contains(q, "WHERE id = ?"):
+			var match [][]driver.Value
+			for _, r := range rows {
+				if r[0] == args[0] {
+					match = append(match, r)
+				}
+			}
+
+			return postColumns, match, nil
+		case strings.Contains(q, "FROM posts_meta"):
+			return postsMetaColumns, nil, nil
+		case strings.Contains(q, "FROM posts"):
+			return postColumns, rows, nil
+		}
+
+		return nil, nil, fmt.Errorf("unexpected query: %v", q)
+	})
+
+	dir := t.TempDir()
+
+	c := ghosttohugo.Config{
+		OutputPath:          dir,
+		WebhookSecret:       testWebhookSecret,
+		WebhookDebounce:     debounce,
+		RedirectsOutputPath: path.Join(dir, "_redirects"),
+	}
+	c.ApplyDefaults()
+	c.Process()
+
+	err := c.ParseTemplate()
+	if err != nil {
+		t.Logf("failed to parse template: %v", err.Error())
+		t.FailNow()
+	}
+
+	for _, f := range []string{"first.md", "second.md"} {
+		err = os.WriteFile(path.Join(dir, f), []byte("old\n"), 0o644)
+		if err != nil {
+			t.Logf("failed to write file: %v", err.Error())
+			t.FailNow()
+		}
+	}
+
+	return &ghosttohugo.WebhookServer{Config: &c, Source: &ghosttohugo.DBSource{Config: &c, DB: db}}, dir
+}
+
+// sendWebhook posts a fixture to the server, signed with secret.
+func sendWebhook(t *testing.T, s http.Handler, fixture, secret string) int {
+	t.Helper()
+
+	body, err := os.ReadFile(path.Join("testdata", fixture))
+	if err != nil {
+		t.Logf("failed to read fixture: %v", err.Error())
+		t.FailNow()
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
+	r.Header.Set(ghosttohugo.WebhookSignatureHeader, ghosttohugo.SignWebhook(secret, body, time.Now()))
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	return w.Code
+}
+
+func exists(f string) bool {
+	_, err := os.Stat(f)
+	return !errors.Is(err, fs.ErrNotExist)
+}
+
+func TestWebhookServer(t *testing.T) {
+	t.Parallel()
+
+	s, dir := newWebhookServer(t, "1h")
+
+	if code := sendWebhook(t, s, "webhook-post-edited.json", "wrong"); code != http.StatusUnauthorized {
+		t.Logf("bad signature: got status %v", code)
+		t.Fail()
+	}
+
+	if code := sendWebhook(t, s, "webhook-post-edited.json", testWebhookSecret); code != http.StatusAccepted {
+		t.Logf("edited: got status %v", code)
+		t.FailNow()
+	}
+
+	if code := sendWebhook(t, s, "webhook-post-deleted.json", testWebhookSecret); code != http.StatusAccepted {
+		t.Logf("deleted: got status %v", code)
+		t.FailNow()
+	}
+
+	health := func() ghosttohugo.WebhookHealth {
+		t.Helper()
+
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+		var h ghosttohugo.WebhookHealth
+		err := json.Unmarshal(w.Body.Bytes(), &h)
+		if err != nil || w.Code != http.StatusOK {
+			t.Logf("unexpected health response %v: %v", w.Code, w.Body.String())
+			t.FailNow()
+		}
+
+		return h
+	}
+
+	// nothing happens until the debounce window has passed
+	if h := health(); h.Pending != 2 || h.LastExport != nil {
+		t.Logf("unexpected health before flush: %+v", h)
+		t.Fail()
+	}
+
+	if !exists(path.Join(dir, "first.md")) {
+		t.Logf("post was removed before the debounce window passed")
+		t.Fail()
+	}
+
+	err := s.Flush(context.Background())
+	if err != nil {
+		t.Logf("failed to flush: %v", err.Error())
+		t.FailNow()
+	}
+
+	if h := health(); h.Pending != 0 || h.LastExport == nil || h.Status != "ok" {
+		t.Logf("unexpected health after flush: %+v", h)
+		t.Fail()
+	}
+
+	b, err := os.ReadFile(path.Join(dir, "renamed.md"))
+	if err != nil || !strings.Contains(string(b), "edited") {
+		t.Logf("renamed post was not rendered: %v", string(b))
+		t.Fail()
+	}
+
+	if exists(path.Join(dir, "first.md")) {
+		t.Logf("expected the renamed post's old file to be removed")
+		t.Fail()
+	}
+
+	if exists(path.Join(dir, "second.md")) {
+		t.Logf("expected the deleted post to be removed")
+		t.Fail()
+	}
+
+	if !exists(path.Join(dir, "_redirects")) {
+		t.Logf("expected the redirects file to be rewritten")
+		t.Fail()
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/webhook", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Logf("GET /webhook: got status %v", w.Code)
+		t.Fail()
+	}
+}
+
+func TestWebhookServerContinueOnError(t *testing.T) {
+	t.Parallel()
+
+	s, dir := newWebhookServer(t, "1h")
+	s.Config.ContinueOnError = true
+	s.Config.ReportPath = path.Join(dir, "report.json")
+	s.Config.Report = ghosttohugo.NewRunReport()
+
+	// a directory where the renamed post should be written
+	err := os.Mkdir(path.Join(dir, "renamed.md"), 0o755)
+	if err != nil {
+		t.Logf("failed to create dir: %v", err.Error())
+		t.FailNow()
+	}
+
+	for _, fixture := range []string{"webhook-post-edited.json", "webhook-post-deleted.json"} {
+		if code := sendWebhook(t, s, fixture, testWebhookSecret); code != http.StatusAccepted {
+			t.Logf("%v: got status %v", fixture, code)
+			t.FailNow()
+		}
+	}
+
+	err = s.Flush(context.Background())
+
+	var errs ghosttohugo.PostErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].PostID != "1" || errs[0].Stage != ghosttohugo.StageWrite {
+		t.Logf("expected post 1 to fail, got %v", err)
+		t.FailNow()
+	}
+
+	// the other post and the redirects are still exported
+	if !exists(path.Join(dir, "first.md")) {
+		t.Logf("expected the failed post's old file to be kept")
+		t.Fail()
+	}
+
+	if exists(path.Join(dir, "second.md")) {
+		t.Logf("expected the deleted post to be removed")
+		t.Fail()
+	}
+
+	if !exists(path.Join(dir, "_redirects")) {
+		t.Logf("expected the redirects file to be rewritten")
+		t.Fail()
+	}
+
+	// the export is reported on its own
+	b, err := os.ReadFile(s.Config.ReportPath)
+	if err != nil {
+		t.Logf("failed to read report: %v", err.Error())
+		t.FailNow()
+	}
+
+	var r ghosttohugo.RunReport
+	err = json.Unmarshal(b, &r)
+	if err != nil || len(r.Failures) != 1 || r.Failures[0].ID != "1" || r.ByStatus["published"] != 1 {
+		t.Logf("unexpected report: %v", string(b))
+		t.Fail()
+	}
+}
+
+func TestWebhookServerDebounce(t *testing.T) {
+	t.Parallel()
+
+	s, dir := newWebhookServer(t, "10ms")
+
+	if code := sendWebhook(t, s, "webhook-post-edited.json", testWebhookSecret); code != http.StatusAccepted {
+		t.Logf("edited: got status %v", code)
+		t.FailNow()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !exists(path.Join(dir, "renamed.md")) {
+		if time.Now().After(deadline) {
+			t.Logf("post was not rendered after the debounce window")
+			t.FailNow()
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// wait for the export to finish before the output directory is removed
+	err := s.Flush(context.Background())
+	if err != nil {
+		t.Logf("failed to flush: %v", err.Error())
+		t.Fail()
+	}
+}
+
+func TestWebhookServerRetry(t *testing.T) {
+	t.Parallel()
+
+	s, dir := newWebhookServer(t, "10ms")
+
+	// a directory where the renamed post should be written
+	blocked := path.Join(dir, "renamed.md")
+	err := os.Mkdir(blocked, 0o755)
+	if err != nil {
+		t.Logf("failed to create dir: %v", err.Error())
+		t.FailNow()
+	}
+
+	if code := sendWebhook(t, s, "webhook-post-edited.json", testWebhookSecret); code != http.StatusAccepted {
+		t.Logf("edited: got status %v", code)
+		t.FailNow()
+	}
+
+	err = s.Flush(context.Background())
+	if err == nil {
+		t.Logf("expected the export to fail")
+		t.FailNow()
+	}
+
+	// the post is queued again rather than lost
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	var h ghosttohugo.WebhookHealth
+	err = json.Unmarshal(w.Body.Bytes(), &h)
+	if err != nil || h.Pending != 1 || h.Status != "error" {
+		t.Logf("unexpected health after a failed export: %v", w.Body.String())
+		t.Fail()
+	}
+
+	err = os.Remove(blocked)
+	if err != nil {
+		t.Logf("failed to remove dir: %v", err.Error())
+		t.FailNow()
+	}
+
+	// and is exported once the debounce window passes again
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b, _ := os.ReadFile(blocked)
+		if strings.Contains(string(b), "edited") {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Logf("post was not rendered after the export was retried")
+			t.FailNow()
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if exists(path.Join(dir, "first.md")) {
+		t.Logf("expected the renamed post's old file to be removed")
+		t.Fail()
+	}
+
+	err = s.Flush(context.Background())
+	if err != nil {
+		t.Logf("failed to flush: %v", err.Error())
+		t.Fail()
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	t.Parallel()
+
+	body := []byte(`{"post":{}}`)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	header := ghosttohugo.SignWebhook(testWebhookSecret, body, now)
+
+	tests := map[string]struct {
+		secret string
+		body   []byte
+		header string
+		now    time.Time
+		valid  bool
+	}{
+		"valid":         {testWebhookSecret, body, header, now.Add(time.Minute), true},
+		"wrong secret":  {"other", body, header, now, false},
+		"no secret":     {"", body, header, now, false},
+		"tampered body": {testWebhookSecret, []byte(`{"page":{}}`), header, now, false},
+		"expired":       {testWebhookSecret, body, header, now.Add(time.Hour), false},
+		"malformed":     {testWebhookSecret, body, "sha256=abc", now, false},
+		"missing":       {testWebhookSecret, body, "", now, false},
+	}
+
+	for name, test := range tests {
+		err := ghosttohugo.VerifyWebhookSignature(test.secret, test.body, test.header, test.now)
+		if (err == nil) != test.valid {
+			t.Logf("test %v: got err %v, want valid %v", name, err, test.valid)
+			t.Fail()
+		}
+
+		if err != nil && !errors.Is(err, ghosttohugo.ErrWebhookSignature) {
+			t.Logf("test %v: expected ErrWebhookSignature, got %v", name, err)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package ghosttohugo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// yamlNode is a node in a parsed YAML document. A node is either a scalar, a
+// map or a list; an empty node (e.g. "key:" with nothing nested under it) is
+// none of them.
+//
+// This module intentionally avoids pulling in a full YAML library, so only
+// the subset of YAML that Ghost's redirects.yaml and routes.yaml files use is
+// supported: block maps, block sequences of scalars, plain/quoted scalars,
+// empty flow collections and comments.
+type yamlNode struct {
+	Value  string
+	Scalar bool
+	// Keys holds the map's keys in document order.
+	Keys []string
+	Map  map[string]*yamlNode
+	List []*yamlNode
+}
+
+// Get returns the child node for key k, or nil if there isn't one.
+func (n *yamlNode) Get(k string) *yamlNode {
+	if n == nil || n.Map == nil {
+		return nil
+	}
+
+	return n.Map[k]
+}
+
+// String returns the node's scalar value, or an empty string if it isn't a
+// scalar.
+func (n *yamlNode) String() string {
+	if n == nil {
+		return ""
+	}
+
+	return n.Value
+}
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// stripYAMLComment removes a trailing comment from s, ignoring # characters
+// that are inside quotes or not preceded by whitespace.
+func stripYAMLComment(s string) string {
+	var quote rune
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			if i == 0 || s[i-1] == ' ' || s[i-1] == ':' || s[i-1] == '-' {
+				quote = r
+			}
+		case r == '#':
+			if i == 0 || s[i-1] == ' ' || s[i-1] == '\t' {
+				return s[:i]
+			}
+		}
+	}
+
+	return s
+}
+
+// parseYAML parses the supported subset of YAML into a tree of nodes.
+func parseYAML(b []byte) (*yamlNode, error) {
+	var lines []yamlLine
+
+	for i, l := range strings.Split(strings.ReplaceAll(string(b), "\r\n", "\n"), "\n") {
+		if i == 0 {
+			l = strings.TrimPrefix(l, "\ufeff")
+		}
+
+		l = strings.TrimRight(stripYAMLComment(l), " \t")
+		t := strings.TrimLeft(l, " ")
+		if t == "" || t == "---" {
+			continue
+		}
+
+		if strings.HasPrefix(t, "\t") {
+			return nil, fmt.Errorf("yaml line %v: tabs are not allowed for indentation", i+1)
+		}
+
+		lines = append(lines, yamlLine{num: i + 1, indent: len(l) - len(t), text: t})
+	}
+
+	if len(lines) == 0 {
+		return &yamlNode{}, nil
+	}
+
+	n, next, err := parseYAMLBlock(lines, 0, lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+
+	if next < len(lines) {
+		return nil, fmt.Errorf("yaml line %v: unexpected indentation", lines[next].num)
+	}
+
+	return n, nil
+}
+
+func isYAMLListItem(s string) bool {
+	return s == "-" || strings.HasPrefix(s, "- ")
+}
+
+// parseYAMLBlock parses a map or sequence whose entries are all at the given
+// indentation, starting at lines[i]. It returns the index of the first line
+// that isn't part of the block.
+func parseYAMLBlock(lines []yamlLine, i, indent int) (*yamlNode, int, error) {
+	if isYAMLListItem(lines[i].text) {
+		return parseYAMLList(lines, i, indent)
+	}
+
+	n := &yamlNode{Map: make(map[string]*yamlNode)}
+
+	for i < len(lines) {
+		l := lines[i]
+		if l.indent < indent {
+			break
+		}
+
+		if l.indent > indent {
+			return nil, i, fmt.Errorf("yaml line %v: unexpected indentation", l.num)
+		}
+
+		if isYAMLListItem(l.text) {
+			return nil, i, fmt.Errorf("yaml line %v: unexpected list item in map", l.num)
+		}
+
+		k, v, ok := splitYAMLKey(l.text)
+		if !ok {
+			return nil, i, fmt.Errorf("yaml line %v: expected a key", l.num)
+		}
+
+		if _, exists := n.Map[k]; exists {
+			return nil, i, fmt.Errorf("yaml line %v: duplicate key %q", l.num, k)
+		}
+
+		i++
+
+		var child *yamlNode
+		switch {
+		case v != "":
+			var err error
+			child, err = parseYAMLInline(v)
+			if err != nil {
+				return nil, i, fmt.Errorf("yaml line %v: %w", l.num, err)
+			}
+		case i < len(lines) && lines[i].indent > indent:
+			var err error
+			child, i, err = parseYAMLBlock(lines, i, lines[i].indent)
+			if err != nil {
+				return nil, i, err
+			}
+		case i < len(lines) && lines[i].indent == indent && isYAMLListItem(lines[i].text):
+			// sequences are allowed to be at the same indentation as their key
+			var err error
+			child, i, err = parseYAMLList(lines, i, indent)
+			if err != nil {
+				return nil, i, err
+			}
+		default:
+			child = &yamlNode{}
+		}
+
+		n.Keys = append(n.Keys, k)
+		n.Map[k] = child
+	}
+
+	return n, i, nil
+}
+
+// parseYAMLList parses a sequence of scalars at the given indentation.
+func parseYAMLList(lines []yamlLine, i, indent int) (*yamlNode, int, error) {
+	n := &yamlNode{List: []*yamlNode{}}
+
+	for i < len(lines) {
+		l := lines[i]
+		if l.indent != indent || !isYAMLListItem(l.text) {
+			if l.indent > indent {
+				return nil, i, fmt.Errorf("yaml line %v: nested sequences are not supported", l.num)
+			}
+			break
+		}
+
+		child, err := parseYAMLInline(strings.TrimSpace(strings.TrimPrefix(l.text, "-")))
+		if err != nil {
+			return nil, i, fmt.Errorf("yaml line %v: %w", l.num, err)
+		}
+
+		n.List = append(n.List, child)
+		i++
+	}
+
+	return n, i, nil
+}
+
+// splitYAMLKey splits "key: value" into its key and value. The key may be
+// quoted, and a colon is only treated as a separator if it is followed by a
+// space or the end of the line, so that values like "tag:podcast" and keys
+// like "^/(.*)$" work as expected.
+func splitYAMLKey(s string) (string, string, bool) {
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'") {
+		end := strings.IndexByte(s[1:], s[0])
+		if end < 0 {
+			return "", "", false
+		}
+
+		rest := strings.TrimLeft(s[end+2:], " ")
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", false
+		}
+
+		k, err := unquoteYAML(s[:end+2])
+		if err != nil {
+			return "", "", false
+		}
+
+		return k, strings.TrimSpace(rest[1:]), true
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != ':' {
+			continue
+		}
+
+		if i == len(s)-1 || s[i+1] == ' ' {
+			return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]), true
+		}
+	}
+
+	return "", "", false
+}
+
+// parseYAMLInline parses a value that follows a key or list item marker.
+func parseYAMLInline(s string) (*yamlNode, error) {
+	switch s {
+	case "{}":
+		return &yamlNode{Map: make(map[string]*yamlNode)}, nil
+	case "[]":
+		return &yamlNode{List: []*yamlNode{}}, nil
+	case "~", "null":
+		return &yamlNode{}, nil
+	}
+
+	v, err := unquoteYAML(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &yamlNode{Value: v, Scalar: true}, nil
+}
+
+// unquoteYAML removes surrounding quotes from a scalar and processes the
+// escape sequences that are valid within it.
+func unquoteYAML(s string) (string, error) {
+	if len(s) < 2 {
+		return s, nil
+	}
+
+	switch {
+	case s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case s[0] == '"' && s[len(s)-1] == '"':
+		var b strings.Builder
+		in := s[1 : len(s)-1]
+		for i := 0; i < len(in); i++ {
+			if in[i] != '\\' {
+				b.WriteByte(in[i])
+				continue
+			}
+
+			i++
+			if i >= len(in) {
+				return "", fmt.Errorf("invalid escape in %v", s)
+			}
+
+			switch in[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '"', '\\', '/':
+				b.WriteByte(in[i])
+			default:
+				// keep unknown escapes verbatim, which is what regexes want
+				b.WriteByte('\\')
+				b.WriteByte(in[i])
+			}
+		}
+
+		return b.String(), nil
+	}
+
+	return s, nil
+}